@@ -0,0 +1,26 @@
+package rod
+
+// Annotated is a value prefixed with an annotation. Annotations are discarded
+// by the decoder unless enabled with Decoder.SetAnnotations.
+type Annotated struct {
+	// Annotation is the text of the annotation, excluding delimiters.
+	Annotation string
+	// Value is the annotated value. It must not be another Annotated.
+	Value any
+}
+
+// Returns the annotation of v, and whether v is annotated.
+func annotationOf(v any) (annotation string, ok bool) {
+	if v, ok := v.(Annotated); ok {
+		return v.Annotation, true
+	}
+	return "", false
+}
+
+// Returns v without its annotation.
+func unannotate(v any) any {
+	if v, ok := v.(Annotated); ok {
+		return v.Value
+	}
+	return v
+}
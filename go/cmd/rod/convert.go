@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/rodcbor"
+	"github.com/anaminus/rod/go/rodjson"
+	"github.com/anaminus/rod/go/rodmsgpack"
+	"github.com/anaminus/rod/go/rodproto"
+)
+
+var cmdConvert = &command{
+	name:  "convert",
+	args:  "[flags] [file]",
+	short: "convert a file between ROD and other formats",
+}
+
+var (
+	convertFrom = cmdConvert.flag.String("from", "rod", "format of the input: "+strings.Join(formatNames(true), ", "))
+	convertTo   = cmdConvert.flag.String("to", "rod", "format of the output: "+strings.Join(formatNames(false), ", "))
+)
+
+// A format to and from which values can be converted. A format without
+// decode or encode cannot be converted from or to, respectively.
+type dataFormat struct {
+	decode func(data []byte) (any, error)
+	encode func(v any) ([]byte, error)
+}
+
+var formats = map[string]dataFormat{
+	"rod":      {decodeROD, encodeROD},
+	"json":     {rodjson.ToROD, rodjson.FromROD},
+	"cbor":     {rodcbor.ToROD, rodcbor.FromROD},
+	"msgpack":  {rodmsgpack.ToROD, rodmsgpack.FromROD},
+	"protobuf": {rodproto.ToROD, nil},
+}
+
+// Returns the sorted names of formats that can be converted from if from is
+// true, or to otherwise.
+func formatNames(from bool) []string {
+	names := make([]string, 0, len(formats))
+	for name, f := range formats {
+		if from && f.decode != nil || !from && f.encode != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func decodeROD(data []byte) (v any, err error) {
+	d := rod.NewDecoder(bytes.NewReader(data))
+	d.SetAnnotations(true)
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func encodeROD(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := rod.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func init() {
+	cmdConvert.run = runConvert
+	register(cmdConvert)
+}
+
+// Converts a single document from one format to another. With no file, the
+// document is read from stdin.
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		cmdConvert.flag.Usage()
+		return 2
+	}
+	from, ok := formats[*convertFrom]
+	if !ok || from.decode == nil {
+		fmt.Fprintf(stderr, "rod convert: cannot convert from %s\n", *convertFrom)
+		return 2
+	}
+	to, ok := formats[*convertTo]
+	if !ok || to.encode == nil {
+		fmt.Fprintf(stderr, "rod convert: cannot convert to %s\n", *convertTo)
+		return 2
+	}
+	name := "-"
+	if len(args) == 1 {
+		name = args[0]
+	}
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "rod convert: %s\n", err)
+		return 2
+	}
+	v, err := from.decode(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod convert: %s: %s\n", name, err)
+		return 2
+	}
+	out, err := to.encode(v)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod convert: %s\n", err)
+		return 2
+	}
+	if _, err := stdout.Write(out); err != nil {
+		fmt.Fprintf(stderr, "rod convert: %s\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+var cmdDiff = &command{
+	name:  "diff",
+	args:  "[flags] <a> <b>",
+	short: "report semantic differences between two files",
+}
+
+var diffQuiet = cmdDiff.flag.Bool("q", false, "report only whether the files differ")
+
+func init() {
+	cmdDiff.run = runDiff
+	register(cmdDiff)
+}
+
+// Compares the decoded values of two files, ignoring formatting, comments, and
+// the order of entries. Exits with 1 if the files differ.
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 2 {
+		cmdDiff.flag.Usage()
+		return 2
+	}
+	if args[0] == "-" && args[1] == "-" {
+		fmt.Fprintln(stderr, "rod diff: cannot read both files from standard input")
+		return 2
+	}
+	a, err := decodeFile(args[0], stdin, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod diff: %s\n", err)
+		return 2
+	}
+	b, err := decodeFile(args[1], stdin, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod diff: %s\n", err)
+		return 2
+	}
+	diffs := rod.Diff(a, b)
+	if len(diffs) == 0 {
+		return 0
+	}
+	if *diffQuiet {
+		fmt.Fprintf(stdout, "%s and %s differ\n", args[0], args[1])
+	} else {
+		rod.WriteDiff(stdout, diffs)
+	}
+	return 1
+}
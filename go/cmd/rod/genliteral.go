@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+var cmdGenLiteral = &command{
+	name:  "gen-literal",
+	args:  "[flags] [file]",
+	short: "generate a Go literal from a file",
+}
+
+var (
+	genLiteralType = cmdGenLiteral.flag.String("type", "", "Go type of the literal, as declared in the package of -dir")
+	genLiteralDir  = cmdGenLiteral.flag.String("dir", ".", "directory of the package that declares -type")
+)
+
+func init() {
+	cmdGenLiteral.run = runGenLiteral
+	register(cmdGenLiteral)
+}
+
+// Writes a Go expression that evaluates to the value of a file, as rendered
+// by GoSyntax. With -type, the value is rendered as a value of the type. With
+// no file, the value is read from stdin.
+func runGenLiteral(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		cmdGenLiteral.flag.Usage()
+		return 2
+	}
+	name := "-"
+	if len(args) == 1 {
+		name = args[0]
+	}
+	v, err := decodeFile(name, stdin, true)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod gen-literal: %s\n", err)
+		return 2
+	}
+	var opts []rod.GoSyntaxOption
+	if *genLiteralType != "" {
+		opt, err := goType(*genLiteralDir, *genLiteralType)
+		if err != nil {
+			fmt.Fprintf(stderr, "rod gen-literal: %s\n", err)
+			return 2
+		}
+		opts = append(opts, opt)
+	}
+	src, err := rod.GoSyntax(v, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod gen-literal: %s: %s\n", name, err)
+		return 2
+	}
+	fmt.Fprintln(stdout, src)
+	return 0
+}
+
+// Returns an option that renders values as the type expression typ, evaluated
+// within the package in dir. Types of the package are unqualified, and types of
+// other packages are qualified by package name.
+func goType(dir, typ string) (rod.GoSyntaxOption, error) {
+	fset, files, err := parseFiles(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		// Errors elsewhere in the package need not prevent the type from
+		// being resolved.
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+	tv, err := types.Eval(fset, pkg, token.NoPos, typ)
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %w", typ, err)
+	}
+	if !tv.IsType() {
+		return nil, fmt.Errorf("%s is not a type", typ)
+	}
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+	return rod.AsType(tv.Type, qualifier), nil
+}
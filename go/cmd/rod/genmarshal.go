@@ -0,0 +1,604 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var cmdGenMarshal = &command{
+	name:  "gen-marshal",
+	args:  "[flags] [dir]",
+	short: "generate marshal methods for Go types",
+}
+
+var (
+	genMarshalType   = cmdGenMarshal.flag.String("type", "", "comma-separated names of the types; required")
+	genMarshalOutput = cmdGenMarshal.flag.String("o", "", "output file; defaults to <type>_rod.go in dir")
+)
+
+func init() {
+	cmdGenMarshal.run = runGenMarshal
+	register(cmdGenMarshal)
+}
+
+// Writes a Go source file declaring MarshalROD and UnmarshalROD methods for the
+// named types of the package in dir, and for each type of the package that
+// they refer to. With no dir, the current directory is used.
+func runGenMarshal(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 1 || *genMarshalType == "" {
+		cmdGenMarshal.flag.Usage()
+		return 2
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	names := strings.Split(*genMarshalType, ",")
+	output := *genMarshalOutput
+	if output == "" {
+		output = filepath.Join(dir, strings.ToLower(names[0])+"_rod.go")
+	}
+	pkg, specs, err := parsePackage(dir, output)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod gen-marshal: %s\n", err)
+		return 2
+	}
+	g := marshalGen{specs: specs, queued: map[string]bool{}, imports: map[string]bool{}}
+	for _, name := range names {
+		if specs[name] == nil {
+			fmt.Fprintf(stderr, "rod gen-marshal: type %s not found\n", name)
+			return 2
+		}
+		g.enqueue(name)
+	}
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		if err := g.generate(specs[name]); err != nil {
+			fmt.Fprintf(stderr, "rod gen-marshal: type %s: %s\n", name, err)
+			return 2
+		}
+	}
+	imports := make([]string, 0, len(g.imports)+1)
+	for path := range g.imports {
+		imports = append(imports, strconv.Quote(path))
+	}
+	sort.Strings(imports)
+	imports = append(imports, `rod "github.com/anaminus/rod/go"`)
+	if err := writeGoFile(output, stdout, "gen-marshal", pkg, imports, []byte(g.b.String())); err != nil {
+		fmt.Fprintf(stderr, "rod gen-marshal: %s\n", err)
+		return 2
+	}
+	return 0
+}
+
+// Parses the Go files of the package in dir, excluding tests, files excluded
+// by build constraints, and the file named by exclude.
+func parseFiles(dir, exclude string) (*token.FileSet, []*ast.File, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") || exclude != "" && filepath.Clean(name) == filepath.Clean(exclude) {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, filepath.Base(name)); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return fset, files, nil
+}
+
+// Parses the package in dir as by parseFiles. Returns the name of the package,
+// and its type declarations.
+func parsePackage(dir, exclude string) (pkg string, specs map[string]*ast.TypeSpec, err error) {
+	_, files, err := parseFiles(dir, exclude)
+	if err != nil {
+		return "", nil, err
+	}
+	specs = map[string]*ast.TypeSpec{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					specs[spec.Name.Name] = spec
+				}
+			}
+		}
+	}
+	return files[0].Name.Name, specs, nil
+}
+
+// Kinds of Go types supported by the generator.
+type goKind int
+
+const (
+	kBool goKind = iota
+	kInt
+	kUint
+	kFloat
+	kString
+	kBlob
+	kAny
+	kPointer
+	kSlice
+	kMap
+	kNamed // A type declared in the package.
+)
+
+type marshalGen struct {
+	specs   map[string]*ast.TypeSpec
+	queue   []string
+	queued  map[string]bool
+	imports map[string]bool
+	b       strings.Builder
+	// Counter for naming temporary variables.
+	n int
+}
+
+func (g *marshalGen) enqueue(name string) {
+	if !g.queued[name] {
+		g.queued[name] = true
+		g.queue = append(g.queue, name)
+	}
+}
+
+// Returns a new variable name with the given prefix.
+func (g *marshalGen) temp(prefix string) string {
+	g.n++
+	return prefix + strconv.Itoa(g.n)
+}
+
+func (g *marshalGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.b, format, args...)
+}
+
+// Returns the kind of t.
+func (g *marshalGen) kindOf(t ast.Expr) (goKind, error) {
+	switch t := t.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "bool":
+			return kBool, nil
+		case "int", "int8", "int16", "int32", "int64", "rune":
+			return kInt, nil
+		case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+			return kUint, nil
+		case "float32", "float64":
+			return kFloat, nil
+		case "string":
+			return kString, nil
+		case "any":
+			return kAny, nil
+		}
+		if g.specs[t.Name] != nil {
+			if g.specs[t.Name].TypeParams != nil {
+				return 0, fmt.Errorf("generic type %s is not supported", t.Name)
+			}
+			return kNamed, nil
+		}
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return kAny, nil
+		}
+	case *ast.StarExpr:
+		return kPointer, nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		if elem, ok := t.Elt.(*ast.Ident); ok && (elem.Name == "byte" || elem.Name == "uint8") {
+			return kBlob, nil
+		}
+		return kSlice, nil
+	case *ast.MapType:
+		return kMap, nil
+	}
+	return 0, fmt.Errorf("type %s is not supported", types.ExprString(t))
+}
+
+// Returns the kind of the underlying type of t, following declarations in the
+// package.
+func (g *marshalGen) underlyingKind(t ast.Expr) (goKind, error) {
+	for i := 0; ; i++ {
+		k, err := g.kindOf(t)
+		if err != nil || k != kNamed {
+			return k, err
+		}
+		if i > len(g.specs) {
+			return 0, fmt.Errorf("invalid recursive type %s", types.ExprString(t))
+		}
+		t = g.specs[t.(*ast.Ident).Name].Type
+	}
+}
+
+// Generates the methods of the type declared by spec.
+func (g *marshalGen) generate(spec *ast.TypeSpec) error {
+	name := spec.Name.Name
+	if spec.TypeParams != nil {
+		return errors.New("generic types are not supported")
+	}
+	if k, err := g.kindOf(spec.Type); err == nil && k == kNamed {
+		return fmt.Errorf("underlying type %s is not supported", types.ExprString(spec.Type))
+	}
+	if st, ok := spec.Type.(*ast.StructType); ok {
+		return g.generateStruct(name, st)
+	}
+	g.printf("// MarshalROD implements rod.Marshaler.\n")
+	g.printf("func (v %s) MarshalROD(e *rod.Encoder) error {\n", name)
+	if err := g.marshal(spec.Type, "v"); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n\n")
+	g.printf("// UnmarshalROD implements rod.Unmarshaler.\n")
+	g.printf("func (v *%s) UnmarshalROD(d *rod.Decoder) error {\n", name)
+	if err := g.unmarshal(spec.Type, "*v", name); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n\n")
+	return nil
+}
+
+// A struct field that is encoded.
+type marshalField struct {
+	goName    string
+	rodName   string
+	omitEmpty bool
+	typ       ast.Expr
+}
+
+// Returns the encoded fields of st, sorted by their encoded names.
+func structFields(st *ast.StructType) ([]marshalField, error) {
+	var fields []marshalField
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("embedded field %s is not supported", types.ExprString(f.Type))
+		}
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s).Get("rod")
+		}
+		if tag == "-" {
+			continue
+		}
+		rodName, opts, _ := strings.Cut(tag, ",")
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+			field := marshalField{
+				goName:    name.Name,
+				rodName:   rodName,
+				omitEmpty: opts == "omitempty",
+				typ:       f.Type,
+			}
+			if field.rodName == "" {
+				field.rodName = name.Name
+			}
+			fields = append(fields, field)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].rodName < fields[j].rodName
+	})
+	for i := 1; i < len(fields); i++ {
+		if fields[i].rodName == fields[i-1].rodName {
+			return nil, fmt.Errorf("field %s is declared more than once", fields[i].rodName)
+		}
+	}
+	return fields, nil
+}
+
+func (g *marshalGen) generateStruct(name string, st *ast.StructType) error {
+	fields, err := structFields(st)
+	if err != nil {
+		return err
+	}
+
+	g.printf("// MarshalROD implements rod.Marshaler.\n")
+	g.printf("func (v %s) MarshalROD(e *rod.Encoder) error {\n", name)
+	g.printf("if err := e.BeginStruct(); err != nil {\nreturn err\n}\n")
+	for _, f := range fields {
+		x := "v." + f.goName
+		var cond string
+		if f.omitEmpty {
+			if cond, err = g.nonEmpty(f.typ, x); err != nil {
+				return fmt.Errorf("field %s: %w", f.goName, err)
+			}
+		}
+		if cond != "" {
+			g.printf("if %s {\n", cond)
+		}
+		g.printf("if err := e.WriteField(%q); err != nil {\nreturn err\n}\n", f.rodName)
+		typ := f.typ
+		if star, ok := typ.(*ast.StarExpr); ok && cond != "" {
+			// The condition already excludes nil.
+			typ, x = star.X, "*"+x
+		}
+		if err := g.marshal(typ, x); err != nil {
+			return fmt.Errorf("field %s: %w", f.goName, err)
+		}
+		if cond != "" {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return e.EndStruct()\n}\n\n")
+
+	g.printf("// UnmarshalROD implements rod.Unmarshaler.\n")
+	g.printf("func (v *%s) UnmarshalROD(d *rod.Decoder) error {\n", name)
+	g.printf("*v = %s{}\n", name)
+	g.printf("if err := d.BeginStruct(); err != nil {\nreturn err\n}\n")
+	g.printf("for {\n")
+	g.printf("more, err := d.More()\nif err != nil {\nreturn err\n}\nif !more {\nreturn nil\n}\n")
+	g.printf("field, err := d.ReadField()\nif err != nil {\nreturn err\n}\n")
+	g.printf("switch field {\n")
+	for _, f := range fields {
+		g.printf("case %q:\n", f.rodName)
+		if err := g.unmarshal(f.typ, "v."+f.goName, types.ExprString(f.typ)); err != nil {
+			return fmt.Errorf("field %s: %w", f.goName, err)
+		}
+	}
+	g.printf("default:\nvar skip any\nif err := d.ReadValue(&skip); err != nil {\nreturn err\n}\n")
+	g.printf("}\n}\n}\n\n")
+	return nil
+}
+
+// Returns a condition reporting whether x, of type t, is not empty, or an
+// empty string if x is never empty.
+func (g *marshalGen) nonEmpty(t ast.Expr, x string) (string, error) {
+	k, err := g.underlyingKind(t)
+	if err != nil {
+		return "", err
+	}
+	switch k {
+	case kBool:
+		return x, nil
+	case kInt, kUint, kFloat:
+		return x + " != 0", nil
+	case kString:
+		return x + ` != ""`, nil
+	case kBlob, kSlice, kMap:
+		return "len(" + x + ") != 0", nil
+	case kAny, kPointer:
+		return x + " != nil", nil
+	}
+	return "", nil
+}
+
+// Writes statements that encode x, of type t.
+func (g *marshalGen) marshal(t ast.Expr, x string) error {
+	k, err := g.kindOf(t)
+	if err != nil {
+		return err
+	}
+	var call string
+	switch k {
+	case kBool:
+		call = "e.WriteBool(" + convert(t, "bool", x) + ")"
+	case kInt:
+		call = "e.WriteInt(" + convert(t, "int64", x) + ")"
+	case kUint:
+		call = "e.WriteUint(" + convert(t, "uint64", x) + ")"
+	case kFloat:
+		call = "e.WriteFloat(" + convert(t, "float64", x) + ")"
+	case kString:
+		call = "e.WriteString(" + convert(t, "string", x) + ")"
+	case kBlob:
+		call = "e.WriteBlob(" + x + ")"
+	case kAny:
+		call = "e.WriteValue(" + x + ")"
+	case kNamed:
+		g.enqueue(t.(*ast.Ident).Name)
+		if strings.HasPrefix(x, "*") {
+			x = "(" + x + ")"
+		}
+		call = x + ".MarshalROD(e)"
+	case kPointer:
+		g.printf("if %s == nil {\nif err := e.WriteNull(); err != nil {\nreturn err\n}\n} else {\n", x)
+		if err := g.marshal(t.(*ast.StarExpr).X, "*"+x); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case kSlice:
+		elem := g.temp("elem")
+		g.printf("if err := e.BeginArray(); err != nil {\nreturn err\n}\n")
+		g.printf("for _, %s := range %s {\n", elem, x)
+		if err := g.marshal(t.(*ast.ArrayType).Elt, elem); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		call = "e.EndArray()"
+	case kMap:
+		return g.marshalMap(t.(*ast.MapType), x)
+	}
+	g.printf("if err := %s; err != nil {\nreturn err\n}\n", call)
+	return nil
+}
+
+// Writes statements that encode the map x, with entries sorted by key.
+func (g *marshalGen) marshalMap(t *ast.MapType, x string) error {
+	kk, err := g.underlyingKind(t.Key)
+	if err != nil {
+		return err
+	}
+	var less string
+	switch kk {
+	case kBool:
+		less = "!%[1]s[i] && %[1]s[j]"
+	case kInt, kUint, kFloat, kString:
+		less = "%[1]s[i] < %[1]s[j]"
+	case kAny:
+		if vk, err := g.kindOf(t.Value); err != nil || vk != kAny {
+			return fmt.Errorf("map with key type any must have value type any")
+		}
+		g.printf("if err := e.WriteValue(map[any]any(%s)); err != nil {\nreturn err\n}\n", x)
+		return nil
+	default:
+		return fmt.Errorf("map key type %s is not supported", types.ExprString(t.Key))
+	}
+	g.imports["sort"] = true
+	keys := g.temp("keys")
+	key := g.temp("key")
+	g.printf("%s := make([]%s, 0, len(%s))\n", keys, types.ExprString(t.Key), x)
+	g.printf("for %s := range %s {\n%s = append(%s, %s)\n}\n", key, x, keys, keys, key)
+	g.printf("sort.Slice(%s, func(i, j int) bool {\nreturn "+less+"\n})\n", keys)
+	g.printf("if err := e.BeginMap(); err != nil {\nreturn err\n}\n")
+	g.printf("for _, %s := range %s {\n", key, keys)
+	if err := g.marshal(t.Key, key); err != nil {
+		return err
+	}
+	if err := g.marshal(t.Value, x+"["+key+"]"); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	g.printf("if err := e.EndMap(); err != nil {\nreturn err\n}\n")
+	return nil
+}
+
+// Bit sizes of integer types narrower than 64 bits.
+var intSizes = map[string]bool{
+	"int8": true, "int16": true, "int32": true, "rune": true, "int": true,
+	"uint8": true, "uint16": true, "uint32": true, "byte": true, "uint": true, "uintptr": true,
+}
+
+// Writes statements that decode a value of type t into the addressable
+// expression dst. A primitive is converted to the type named conv.
+func (g *marshalGen) unmarshal(t ast.Expr, dst, conv string) error {
+	k, err := g.kindOf(t)
+	if err != nil {
+		return err
+	}
+	var read string
+	switch k {
+	case kBool:
+		read = "ReadBool"
+	case kInt:
+		read = "ReadInt"
+	case kUint:
+		read = "ReadUint"
+	case kFloat:
+		read = "ReadFloat"
+	case kString:
+		read = "ReadString"
+	case kBlob:
+		read = "ReadBlob"
+	case kAny:
+		g.printf("if err := d.ReadValue(&%s); err != nil {\nreturn err\n}\n", dst)
+		return nil
+	case kNamed:
+		g.enqueue(t.(*ast.Ident).Name)
+		g.printf("if err := %s.UnmarshalROD(d); err != nil {\nreturn err\n}\n", dst)
+		return nil
+	case kPointer:
+		elem := t.(*ast.StarExpr).X
+		null, x := g.temp("null"), g.temp("x")
+		g.printf("if %s, err := d.ReadNull(); err != nil {\nreturn err\n} else if %s {\n%s = nil\n} else {\n", null, null, dst)
+		g.printf("var %s %s\n", x, types.ExprString(elem))
+		if err := g.unmarshal(elem, x, types.ExprString(elem)); err != nil {
+			return err
+		}
+		g.printf("%s = &%s\n}\n", dst, x)
+		return nil
+	case kSlice:
+		elem := t.(*ast.ArrayType).Elt
+		x, e := g.temp("x"), g.temp("elem")
+		g.printf("if err := d.BeginArray(); err != nil {\nreturn err\n}\n")
+		g.printf("%s := %s{}\n", x, conv)
+		g.beginEntries()
+		g.printf("var %s %s\n", e, types.ExprString(elem))
+		if err := g.unmarshal(elem, e, types.ExprString(elem)); err != nil {
+			return err
+		}
+		g.printf("%s = append(%s, %s)\n}\n", x, x, e)
+		g.printf("%s = %s\n", dst, x)
+		return nil
+	case kMap:
+		mt := t.(*ast.MapType)
+		x, key, value := g.temp("x"), g.temp("key"), g.temp("value")
+		g.printf("if err := d.BeginMap(); err != nil {\nreturn err\n}\n")
+		g.printf("%s := %s{}\n", x, conv)
+		g.beginEntries()
+		g.printf("var %s %s\n", key, types.ExprString(mt.Key))
+		if err := g.unmarshal(mt.Key, key, types.ExprString(mt.Key)); err != nil {
+			return err
+		}
+		g.printf("var %s %s\n", value, types.ExprString(mt.Value))
+		if err := g.unmarshal(mt.Value, value, types.ExprString(mt.Value)); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n", x, key, value)
+		g.printf("%s = %s\n", dst, x)
+		return nil
+	}
+	x := g.temp("x")
+	g.printf("%s, err := d.%s()\nif err != nil {\nreturn err\n}\n", x, read)
+	if id, ok := t.(*ast.Ident); ok && intSizes[id.Name] {
+		name := id.Name
+		g.imports["fmt"] = true
+		wide := "int64"
+		if k == kUint {
+			wide = "uint64"
+		}
+		g.printf("if %s(%s(%s)) != %s {\nreturn fmt.Errorf(\"%%d overflows %s\", %s)\n}\n", wide, name, x, x, name, x)
+	}
+	if read == "ReadBlob" && conv == "[]byte" || conv == readTypes[read] {
+		g.printf("%s = %s\n", dst, x)
+	} else {
+		g.printf("%s = %s(%s)\n", dst, conv, x)
+	}
+	return nil
+}
+
+// Types returned by the primitive methods of the Decoder.
+var readTypes = map[string]string{
+	"ReadBool":   "bool",
+	"ReadInt":    "int64",
+	"ReadUint":   "uint64",
+	"ReadFloat":  "float64",
+	"ReadString": "string",
+}
+
+// Returns the expression x, of type t, converted to the type named to, unless
+// t is already that type.
+func convert(t ast.Expr, to, x string) string {
+	if id, ok := t.(*ast.Ident); ok && id.Name == to {
+		return x
+	}
+	return to + "(" + x + ")"
+}
+
+// Writes the beginning of a loop over the entries of a composite.
+func (g *marshalGen) beginEntries() {
+	more := g.temp("more")
+	g.printf("for {\n%s, err := d.More()\nif err != nil {\nreturn err\n}\nif !%s {\nbreak\n}\n", more, more)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+var cmdInfer = &command{
+	name:  "infer",
+	args:  "[file...]",
+	short: "infer a schema from sample files",
+}
+
+func init() {
+	cmdInfer.run = runInfer
+	register(cmdInfer)
+}
+
+// Writes the tightest schema to which each file conforms. With no files, a
+// single document is read from stdin.
+func runInfer(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	docs := make([]any, len(args))
+	for i, name := range args {
+		v, err := decodeFile(name, stdin, true)
+		if err != nil {
+			fmt.Fprintf(stderr, "rod infer: %s\n", err)
+			return 2
+		}
+		docs[i] = v
+	}
+	if err := rod.NewEncoder(stdout).Encode(rod.InferSchema(docs...)); err != nil {
+		fmt.Fprintf(stderr, "rod infer: %s\n", err)
+		return 2
+	}
+	fmt.Fprintln(stdout)
+	return 0
+}
@@ -0,0 +1,138 @@
+// The rod command operates on ROD files.
+//
+// Usage:
+//
+//	rod <command> [arguments]
+//
+// The commands are:
+//
+//	convert       Convert a file between ROD and other formats.
+//	diff          Report semantic differences between two files.
+//	gen-go        Generate Go types from sample files or a schema.
+//	gen-literal   Generate a Go literal from a file.
+//	gen-marshal   Generate marshal methods for Go types.
+//	infer         Infer a schema from sample files.
+//	merge-driver  Merge files as a git merge driver.
+//	query         Select and transform values of a file.
+//
+// Run "rod help <command>" for the usage of a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// A subcommand of the rod command.
+type command struct {
+	// Name of the command.
+	name string
+	// Arguments following the name, for the usage line.
+	args string
+	// Short description shown in the command list.
+	short string
+	// Optional notes shown by "rod help" after the usage line.
+	long string
+	// Flags of the command.
+	flag flag.FlagSet
+	// Runs the command with the arguments remaining after flags, returning
+	// the exit code.
+	run func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands []*command
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: rod <command> [arguments]\n\nThe commands are:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%-14s%s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nRun \"rod help <command>\" for the usage of a command.\n")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Runs the command selected by args, returning the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	name, args := args[0], args[1:]
+	if name == "help" {
+		if len(args) == 0 {
+			usage(stdout)
+			return 0
+		}
+		c := findCommand(args[0])
+		if c == nil {
+			fmt.Fprintf(stderr, "rod help %s: unknown command\n", args[0])
+			return 2
+		}
+		c.flag.SetOutput(stdout)
+		c.flag.Usage()
+		return 0
+	}
+	c := findCommand(name)
+	if c == nil {
+		fmt.Fprintf(stderr, "rod %s: unknown command\n", name)
+		usage(stderr)
+		return 2
+	}
+	c.flag.SetOutput(stderr)
+	if err := c.flag.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	return c.run(c.flag.Args(), stdin, stdout, stderr)
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Registers c as a subcommand.
+func register(c *command) {
+	c.flag.Init(c.name, flag.ContinueOnError)
+	c.flag.Usage = func() {
+		fmt.Fprintf(c.flag.Output(), "usage: rod %s %s\n", c.name, c.args)
+		if c.long != "" {
+			fmt.Fprintf(c.flag.Output(), "\n%s\n", c.long)
+		}
+		c.flag.PrintDefaults()
+	}
+	commands = append(commands, c)
+}
+
+// Reads and decodes the named file. The name "-" reads from stdin. If
+// annotations is true, then annotations are decoded.
+func decodeFile(name string, stdin io.Reader, annotations bool) (v any, err error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	d := rod.NewDecoder(r)
+	d.SetAnnotations(annotations)
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
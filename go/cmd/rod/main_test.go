@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.rod")
+	b := filepath.Join(dir, "b.rod")
+	c := filepath.Join(dir, "c.rod")
+	os.WriteFile(a, []byte("{A: 1, B: [1, 2]}"), 0644)
+	os.WriteFile(b, []byte("# comment\n{\n\tB: [1, 2,],\n\tA: +1,\n}"), 0644)
+	os.WriteFile(c, []byte(`{A: "1", B: [1, 3], C: null}`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"diff", a, b}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+
+	if code := run([]string{"diff", "-", c}, strings.NewReader("{A: 1, B: [1, 2]}"), &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "! .A: int 1 -> string \"1\"\n~ .B[1]: 2 -> 3\n+ .C: null\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	stdout.Reset()
+	*diffQuiet = true
+	defer func() { *diffQuiet = false }()
+	if code := run([]string{"diff", a, c}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), a+" and "+c+" differ\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if code := run([]string{"diff", a}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for missing argument, got %d", code)
+	}
+	if code := run([]string{"unknown"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for unknown command, got %d", code)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.rod")
+	os.WriteFile(a, []byte("{Users: [{Name: \"b\", Age: 30}, {Name: \"a\", Age: 20}], Data: |00 01 02|, Note: <int32> 7}"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"query", "(.Users[] | select(.Age < 25)), .Data[1:], .Note", a}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "{\n\tAge: 20,\n\tName: \"a\",\n}\n|\n\t01 02                                            #..#\n|\n<int32> 7\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"query", "keys"}, strings.NewReader("(2: 1, \"a\": 2, 1: 3)"), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "[\n\t1,\n\t2,\n\t\"a\",\n]\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"query", "."}, strings.NewReader("(<int32> 1: \"a\", 2: \"b\")"), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "(\n\t<int32> 1: \"a\",\n\t2: \"b\",\n)\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if code := run([]string{"query", ".A |", a}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for invalid expression, got %d", code)
+	}
+	if code := run([]string{"query", ".Users.Name", a}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for evaluation error, got %d", code)
+	}
+}
+
+func TestMergeDriver(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.rod")
+	ours := filepath.Join(dir, "ours.rod")
+	theirs := filepath.Join(dir, "theirs.rod")
+	os.WriteFile(base, []byte("{A: 1, B: 1, C: 1}"), 0644)
+	os.WriteFile(ours, []byte("{A: 2, B: 1, C: 1}"), 0644)
+	os.WriteFile(theirs, []byte("{A: 1, B: 3, C: <note> 1}"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"merge-driver", base, ours, theirs}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	got, _ := os.ReadFile(ours)
+	if want := "{\n\tA: 2,\n\tB: 3,\n\tC: <note> 1,\n}\n"; string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	os.WriteFile(ours, []byte("{A: 2, B: 1}"), 0644)
+	os.WriteFile(theirs, []byte("{A: 3, B: 1, C: 2}"), 0644)
+	if code := run([]string{"merge-driver", base, ours, theirs}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	got, _ = os.ReadFile(ours)
+	want := "# <<<<<<< ours .A\n# 2\n# ||||||| base\n# 1\n# =======\n# 3\n# >>>>>>> theirs .A\n" +
+		"# <<<<<<< ours .C\n# (absent)\n# ||||||| base\n# 1\n# =======\n# 2\n# >>>>>>> theirs .C\n" +
+		"{\n\tA: 2,\n\tB: 1,\n}\n"
+	if string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	os.WriteFile(theirs, []byte("{A: "), 0644)
+	if code := run([]string{"merge-driver", base, ours, theirs}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for invalid file, got %d", code)
+	}
+	if got2, _ := os.ReadFile(ours); string(got2) != string(got) {
+		t.Errorf("expected ours to be untouched")
+	}
+
+	os.WriteFile(base, []byte("(<int32> 1: \"a\", 2: \"b\")"), 0644)
+	os.WriteFile(ours, []byte("(<int32> 1: \"a\", 2: \"c\")"), 0644)
+	os.WriteFile(theirs, []byte("(<int32> 1: \"x\", 2: \"b\")"), 0644)
+	if code := run([]string{"merge-driver", base, ours, theirs}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	got, _ = os.ReadFile(ours)
+	if want := "(\n\t<int32> 1: \"x\",\n\t2: \"c\",\n)\n"; string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.rod")
+	b := filepath.Join(dir, "b.rod")
+	os.WriteFile(a, []byte("{A: 1, B: <u8> 2}"), 0644)
+	os.WriteFile(b, []byte("{A: 1.5}"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"infer", a, b}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := `{
+	Fields: {
+		A: {
+			Max: 1.5,
+			Min: 1,
+			Type: [
+				"int",
+				"float",
+			],
+		},
+		B: {
+			Annotation: [
+				"u8",
+			],
+			Max: 2,
+			Min: 2,
+			Type: "int",
+		},
+	},
+	Required: [
+		"A",
+	],
+	Type: "struct",
+}
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if code := run([]string{"infer", filepath.Join(dir, "missing.rod")}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for missing file, got %d", code)
+	}
+}
+
+func TestGenGo(t *testing.T) {
+	dir := t.TempDir()
+	sample := filepath.Join(dir, "sample.rod")
+	os.WriteFile(sample, []byte(`{Scale: <float32> 1.5, Table: ("a": [1])}`), 0644)
+	out := filepath.Join(dir, "types.go")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"gen-go", "-type", "Sample", "-package", "sample", "-o", out, sample}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "// Code generated by rod gen-go. DO NOT EDIT.\n\n" +
+		"package sample\n\n" +
+		"type Sample struct {\n" +
+		"\tScale float32            `rod:\"Scale\"`\n" +
+		"\tTable map[string][]int64 `rod:\"Table\"`\n" +
+		"}\n"
+	if got, _ := os.ReadFile(out); string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	schema := filepath.Join(dir, "schema.rod")
+	os.WriteFile(schema, []byte(`{Type: "array", Elem: {Type: "string"}}`), 0644)
+	stdout.Reset()
+	if code := run([]string{"gen-go", "-schema", "-type", "Names", "-package", "p", "-o", "", schema}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got := stdout.String(); !strings.HasSuffix(got, "package p\n\ntype Names []string\n") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+
+	os.WriteFile(schema, []byte(`{Type: 1}`), 0644)
+	if code := run([]string{"gen-go", "-schema", schema}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for invalid schema, got %d", code)
+	}
+}
+
+func TestGenMarshal(t *testing.T) {
+	// The generated methods of marshaltest are up to date.
+	dir := filepath.Join("..", "..", "internal", "marshaltest")
+	out := filepath.Join(t.TempDir(), "sample_rod.go")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"gen-marshal", "-type=Sample", "-o", out, dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	got, _ := os.ReadFile(out)
+	want, _ := os.ReadFile(filepath.Join(dir, "sample_rod.go"))
+	if string(got) != string(want) {
+		t.Errorf("marshaltest/sample_rod.go is out of date; run go generate")
+	}
+
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "types.go"), []byte("package p\n\ntype A struct {\n\tC chan int\n}\n\ntype B struct {\n\tB\n}\n"), 0644)
+	// Files excluded by build constraints are ignored.
+	os.WriteFile(filepath.Join(src, "ignored.go"), []byte("//go:build ignore\n\npackage p\n\ntype X struct{}\n"), 0644)
+	for _, args := range [][]string{
+		{"gen-marshal", "-type=", "-o", "", src},
+		{"gen-marshal", "-type=X", "-o", "", src},
+		{"gen-marshal", "-type=A", "-o", "", src},
+		{"gen-marshal", "-type=B", "-o", "", src},
+	} {
+		stderr.Reset()
+		if code := run(args, nil, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+	}
+}
+
+func TestGenLiteral(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := strings.NewReader(`{A: [1, |ff|]}`)
+	if code := run([]string{"gen-literal", "-type", ""}, in, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "map[string]any{\n\t\"A\": []any{\n\t\tint64(1),\n\t\t[]byte{\n\t\t\t0xff,\n\t\t},\n\t},\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	dir := filepath.Join("..", "..", "internal", "marshaltest")
+	stdout.Reset()
+	in = strings.NewReader(`{Name: "x", Path: ["a"], Next: {Count: 1}}`)
+	if code := run([]string{"gen-literal", "-type", "Sample", "-dir", dir}, in, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want = "Sample{\n\tName: \"x\",\n\tNext: &Sample{\n\t\tCount: 1,\n\t},\n\tPath: Path{\n\t\t\"a\",\n\t},\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Types of other packages are qualified by package name.
+	lit := t.TempDir()
+	os.WriteFile(filepath.Join(lit, "lit.go"), []byte("package lit\n\nimport \"image/color\"\n\ntype T struct{ C color.Gray }\n"), 0644)
+	stdout.Reset()
+	in = strings.NewReader(`{C: {Y: 1}}`)
+	if code := run([]string{"gen-literal", "-type", "T", "-dir", lit}, in, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want = "T{\n\tC: color.Gray{\n\t\tY: 1,\n\t},\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	for _, args := range [][]string{
+		{"gen-literal", "-type", "Missing", "-dir", dir},
+		{"gen-literal", "-type", "Sample", "-dir", dir},
+		{"gen-literal", "-type", "", "a", "b"},
+	} {
+		in := strings.NewReader(`{Name: 1}`)
+		if code := run(args, in, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := strings.NewReader(`{"a": [1, 2.5], "b c": {"$annotation": "base64", "$value": "AP8="}}`)
+	if code := run([]string{"convert", "--from", "json", "--to", "rod"}, in, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := `(
+	"a": [
+		1,
+		2.5,
+	],
+	"b c": |
+		00 ff                                            #..#
+	|,
+)
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.rod")
+	os.WriteFile(file, []byte(`{A: <note> 1.0}`), 0644)
+	stdout.Reset()
+	if code := run([]string{"convert", "-from", "rod", "-to", "json", file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want = "{\n\t\"A\": {\n\t\t\"$annotation\": \"note\",\n\t\t\"$value\": 1.0\n\t}\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if code := run([]string{"convert", "-from", "rod", "-to", "cbor", file}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("annotated: expected exit code 2, got %d", code)
+	}
+	os.WriteFile(file, []byte(`{A: 1.0}`), 0644)
+	stdout.Reset()
+	if code := run([]string{"convert", "-from", "rod", "-to", "cbor", file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "\xa1\x61A\xf9\x3c\x00"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	cbor := strings.NewReader("\xa1\x61A\xc1\x01")
+	stdout.Reset()
+	if code := run([]string{"convert", "-from", "cbor", "-to", "rod"}, cbor, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "(\n\t\"A\": <tag 1> 1,\n)\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	msgpack := strings.NewReader("\x82\xa1a\xc4\x01\xff\xa1b\xd4\x01\x00")
+	stdout.Reset()
+	if code := run([]string{"convert", "-from", "msgpack", "-to", "rod"}, msgpack, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want = `(
+	"a": |
+		ff                                               #.#
+	|,
+	"b": <ext 1> |
+		00                                               #.#
+	|,
+)
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"convert", "-from", "protobuf", "-to", "rod"}, strings.NewReader("\x08\x96\x01"), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "(\n\t1: <varint> 150,\n)\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for _, args := range [][]string{
+		{"convert", "-from", "rod", "-to", "protobuf", file},
+		{"convert", "-from", "xml", "-to", "rod", file},
+		{"convert", "-from", "json", "-to", "rod", file},
+		{"convert", "-from", "rod", "-to", "rod", file, file},
+	} {
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+	}
+}
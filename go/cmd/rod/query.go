@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+var cmdQuery = &command{
+	name:  "query",
+	args:  "<expression> [file]",
+	short: "select and transform values of a file",
+}
+
+func init() {
+	cmdQuery.run = runQuery
+	register(cmdQuery)
+}
+
+// Evaluates a query expression against the decoded value of a file, and
+// writes each output as a ROD document. The file defaults to stdin.
+// Annotations within the file are retained.
+func runQuery(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 || len(args) > 2 {
+		cmdQuery.flag.Usage()
+		return 2
+	}
+	q, err := rod.ParseQuery(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "rod query: %s\n", err)
+		return 2
+	}
+	name := "-"
+	if len(args) == 2 {
+		name = args[1]
+	}
+	v, err := decodeFile(name, stdin, true)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod query: %s\n", err)
+		return 2
+	}
+	outputs, err := q.Run(v)
+	if err != nil {
+		fmt.Fprintf(stderr, "rod query: %s\n", err)
+		return 2
+	}
+	e := rod.NewEncoder(stdout)
+	e.SetReferences(true)
+	for _, out := range outputs {
+		if err := e.Encode(out); err != nil {
+			fmt.Fprintf(stderr, "rod query: %s\n", err)
+			return 2
+		}
+		fmt.Fprintln(stdout)
+	}
+	return 0
+}
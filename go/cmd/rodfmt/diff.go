@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines surrounding each hunk.
+const context = 3
+
+// The kind of an edit to a line.
+type editKind byte
+
+const (
+	editEqual  editKind = ' '
+	editDelete editKind = '-'
+	editInsert editKind = '+'
+)
+
+// An edit applied to a single line.
+type edit struct {
+	kind editKind
+	line string
+}
+
+// Returns the shortest sequence of edits that transforms a into b, using the
+// Myers algorithm.
+func editScript(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset, d, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Walks the trace of the Myers algorithm backwards to produce the edits.
+func backtrack(a, b []string, trace [][]int, offset, d, k int) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		var prev int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prev = k + 1
+		} else {
+			prev = k - 1
+		}
+		px := v[offset+prev]
+		py := px - prev
+		for x > px && y > py {
+			x--
+			y--
+			edits = append(edits, edit{editEqual, a[x]})
+		}
+		if x == px {
+			y--
+			edits = append(edits, edit{editInsert, b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{editDelete, a[x]})
+		}
+		k = prev
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{editEqual, a[x]})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Returns a unified diff between a and b.
+func unifiedDiff(nameA, nameB string, a, b []byte) []byte {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+	edits := editScript(linesA, linesB)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for i := 0; i < len(edits); {
+		// Find next change.
+		for i < len(edits) && edits[i].kind == editEqual {
+			i++
+		}
+		if i >= len(edits) {
+			break
+		}
+		// Extend hunk until a run of unchanged lines is long enough to
+		// separate it from the next change.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].kind != editEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == editEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += context
+				if end > len(edits) {
+					end = len(edits)
+				}
+				break
+			}
+			end = run
+		}
+
+		// Count line numbers preceding and within the hunk.
+		lineA, lineB := 1, 1
+		for _, e := range edits[:start] {
+			if e.kind != editInsert {
+				lineA++
+			}
+			if e.kind != editDelete {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, e := range edits[start:end] {
+			if e.kind != editInsert {
+				countA++
+			}
+			if e.kind != editDelete {
+				countB++
+			}
+		}
+		if countA == 0 {
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, e := range edits[start:end] {
+			out.WriteByte(byte(e.kind))
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")
+	b := []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\nj")
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -7,4 +7,4 @@
+ g
+ h
+ i
+-j
++j
+\ No newline at end of file
+`
+	if got := string(unifiedDiff("a", "b", a, b)); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	unformatted := filepath.Join(dir, "unformatted.rod")
+	formatted := filepath.Join(dir, "formatted.rod")
+	os.WriteFile(unformatted, []byte("{B: +1, A: |FF|}"), 0644)
+	os.WriteFile(formatted, []byte("[\n\t1,\n]"), 0644)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("not rod"), 0644)
+
+	*list = true
+	defer func() { *list = false }()
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != unformatted+"\n" {
+		t.Errorf("unexpected listing %q", got)
+	}
+
+	*write = true
+	defer func() { *write = false }()
+	stdout.Reset()
+	if code := run([]string{dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	b, _ := os.ReadFile(unformatted)
+	want := "{\n\tA: |\n\t\tff                                               #.#\n\t|,\n\tB: 1,\n}"
+	if string(b) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, b)
+	}
+
+	*list, *write = false, false
+	stdout.Reset()
+	if code := run(nil, strings.NewReader("{"), &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for invalid input")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Decoder reads and decodes ROD values from an input stream.
@@ -16,6 +17,52 @@ type Decoder struct {
 	l    *lexer
 	next token
 	eof  bool
+
+	annotations bool
+
+	// Values that have been labeled with an anchor. An array is nil until it
+	// has been fully decoded.
+	anchors map[string]any
+
+	// Positions of decoded values, or nil if positions are not recorded.
+	positions Positions
+	// Path of the value being decoded, while positions are recorded.
+	path Path
+
+	// Composites opened by the primitive methods.
+	frames []decodeFrame
+}
+
+// Position is the location of a value within a document.
+type Position struct {
+	// Offset is the byte offset, starting at 0.
+	Offset int64
+	// Line is the line number, starting at 1.
+	Line int
+	// Column is the byte offset within the line, starting at 1.
+	Column int
+}
+
+// String formats the position as a line and column.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Positions maps the path of each decoded value, as formatted by Path.String,
+// to the position of the value.
+type Positions map[string]Position
+
+// Lookup returns the position of the value located by p. A value within a
+// composite decoded from a reference has no position of its own, so the
+// position of its nearest ancestor is returned instead. Returns false if no
+// position is found.
+func (ps Positions) Lookup(p Path) (Position, bool) {
+	for i := len(p); i >= 0; i-- {
+		if pos, ok := ps[p[:i].String()]; ok {
+			return pos, true
+		}
+	}
+	return Position{}, false
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -26,8 +73,31 @@ func NewDecoder(r io.Reader) *Decoder {
 	return &d
 }
 
-// Decode decodes a value into v. v must be a pointer to an empty interface.
-// Other types are not currently supported.
+// SetAnnotations sets whether annotated values are decoded as Annotated. If
+// false, annotations are discarded.
+func (d *Decoder) SetAnnotations(on bool) {
+	d.annotations = on
+}
+
+// SetPositions sets whether the position of each decoded value is recorded, to
+// be retrieved with Positions.
+func (d *Decoder) SetPositions(on bool) {
+	if on {
+		d.positions = Positions{}
+	} else {
+		d.positions = nil
+	}
+}
+
+// Positions returns the positions of the values decoded by the most recent
+// call to Decode, or nil if positions are not recorded.
+func (d *Decoder) Positions() Positions {
+	return d.positions
+}
+
+// Decode decodes a value into v. v must be a pointer to an empty interface, or
+// an Unmarshaler, which is decoded by calling its UnmarshalROD method. Other
+// types are not currently supported.
 //
 // ROD types are decoded into the following Go types:
 //
@@ -41,13 +111,33 @@ func NewDecoder(r io.Reader) *Decoder {
 //     map     : map[any]any
 //     struct  : map[string]any
 //
+// If annotations are enabled, an annotated value is decoded as an Annotated
+// containing the value.
+//
+// A composite labeled with an anchor is decoded once, and each reference to the
+// anchor produces the same value, such that shared and cyclic structures are
+// reconstructed.
 func (d *Decoder) Decode(v any) error {
-	a, ok := v.(*any)
-	if !ok {
-		return errors.New("argument must be pointer to any")
+	d.anchors = map[string]any{}
+	if d.positions != nil {
+		d.positions = Positions{}
+		d.path = d.path[:0]
 	}
-	if err := d.decodeValue(a); err != nil {
-		return err
+	switch v := v.(type) {
+	case *any:
+		if err := d.decodeValue(v); err != nil {
+			return err
+		}
+	case Unmarshaler:
+		d.frames = d.frames[:0]
+		if err := v.UnmarshalROD(d); err != nil {
+			return err
+		}
+		if len(d.frames) > 0 {
+			return errors.New("UnmarshalROD did not read a complete value")
+		}
+	default:
+		return errors.New("argument must be pointer to any or Unmarshaler")
 	}
 
 	// Expect EOF.
@@ -89,8 +179,12 @@ func (d *Decoder) nextToken() (t token, err error) {
 				return t, nil
 			}
 			return t, io.ErrUnexpectedEOF
-		case tSpace, tInlineComment, tBlockComment, tAnnotation:
+		case tSpace, tInlineComment, tBlockComment:
 			continue
+		case tAnnotation:
+			if !d.annotations {
+				continue
+			}
 		}
 		return t, nil
 	}
@@ -122,6 +216,32 @@ func (d *Decoder) expectToken(t tokenType) error {
 	return nil
 }
 
+// Records the position of the value at the current path, unless a position
+// has already been recorded, as for the value of an annotation.
+func (d *Decoder) record(p position) {
+	if d.positions == nil {
+		return
+	}
+	key := d.path.String()
+	if _, ok := d.positions[key]; !ok {
+		d.positions[key] = Position{Offset: p.StartOffset, Line: p.StartLine, Column: p.StartColumn}
+	}
+}
+
+// Appends s to the current path while positions are recorded.
+func (d *Decoder) push(s Step) {
+	if d.positions != nil {
+		d.path = append(d.path, s)
+	}
+}
+
+// Removes the last step of the current path while positions are recorded.
+func (d *Decoder) pop() {
+	if d.positions != nil {
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
 // Decodes one value into a.
 func (d *Decoder) decodeValue(a *any) error {
 	for {
@@ -129,6 +249,7 @@ func (d *Decoder) decodeValue(a *any) error {
 		if err != nil {
 			return err
 		}
+		d.record(t.Position)
 		switch t.Type {
 		default:
 			d.unexpectedToken(t)
@@ -160,15 +281,69 @@ func (d *Decoder) decodeValue(a *any) error {
 		case tBlob:
 			return d.decodeBlob(a)
 		case tArrayOpen:
-			return d.decodeArray(a)
+			return d.decodeArray(a, "")
 		case tMapOpen:
-			return d.decodeMap(a)
+			return d.decodeMap(a, "")
 		case tStructOpen:
-			return d.decodeStruct(a)
+			return d.decodeStruct(a, "")
+		case tAnchor:
+			return d.decodeAnchor(a, t.Value[1:]) // Assumes len(rAnchor) == 1
+		case tReference:
+			return d.decodeReference(a, t.Value[1:]) // Assumes len(rReference) == 1
+		case tAnnotation:
+			return d.decodeAnnotated(a, t.Value[1:len(t.Value)-1]) // Assumes len(rAnnotation) == len(rAnnotationEnd) == 1
 		}
 	}
 }
 
+// Decodes a value with the given annotation into a as an Annotated.
+func (d *Decoder) decodeAnnotated(a *any, annotation string) error {
+	var v any
+	if err := d.decodeValue(&v); err != nil {
+		return err
+	}
+	*a = Annotated{Annotation: annotation, Value: v}
+	return nil
+}
+
+// Decodes a composite labeled with an anchor into a.
+func (d *Decoder) decodeAnchor(a *any, label string) error {
+	if _, ok := d.anchors[label]; ok {
+		return fmt.Errorf("anchor %c%s is defined more than once", rAnchor, label)
+	}
+	// Reserve label so that references to an incomplete array can be
+	// detected.
+	d.anchors[label] = nil
+	t, err := d.nextToken()
+	if err != nil {
+		return err
+	}
+	switch t.Type {
+	default:
+		d.unexpectedToken(t)
+	case tArrayOpen:
+		return d.decodeArray(a, label)
+	case tMapOpen:
+		return d.decodeMap(a, label)
+	case tStructOpen:
+		return d.decodeStruct(a, label)
+	}
+	return nil
+}
+
+// Decodes the value labeled by an anchor into a.
+func (d *Decoder) decodeReference(a *any, label string) error {
+	v, ok := d.anchors[label]
+	if !ok {
+		return fmt.Errorf("reference %c%s has no anchor", rReference, label)
+	}
+	if v == nil {
+		return fmt.Errorf("reference %c%s refers to an array that contains it", rReference, label)
+	}
+	*a = v
+	return nil
+}
+
 // Decodes a numeric value into a with the given sign.
 func (d *Decoder) decodeNumber(a *any, sign int) error {
 	for {
@@ -203,6 +378,9 @@ func (d *Decoder) decodeInteger(a *any, sign int, s string) error {
 // Decodes a float from s with the given sign into a as a float64.
 func (d *Decoder) decodeFloat(a *any, sign int, s string) error {
 	v, err := strconv.ParseFloat(s, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("float %s is out of range", s)
+	}
 	if err != nil {
 		panic(fmt.Errorf("lexer emitted float token with invalid value %q: %s", s, err))
 	}
@@ -212,11 +390,60 @@ func (d *Decoder) decodeFloat(a *any, sign int, s string) error {
 
 // Decodes a quoted string from s into a as a string.
 func (d *Decoder) decodeString(a *any, s string) error {
+	if strings.HasPrefix(s, rBlockString) {
+		return d.decodeBlockString(a, s)
+	}
 	if !strings.HasPrefix(s, string(rString)) || !strings.HasSuffix(s, string(rString)) {
 		panic(fmt.Errorf("lexer emitted string token without delimiters"))
 	}
 
-	r := strings.NewReader(s[1 : len(s)-1]) // Assumes len(rString) == 1
+	v, err := unescapeString(s[1 : len(s)-1]) // Assumes len(rString) == 1
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Decodes a block string from s into a as a string. The indentation preceding
+// the closing delimiter is removed from each line.
+func (d *Decoder) decodeBlockString(a *any, s string) error {
+	if len(s) < len(rBlockString)*2 || !strings.HasSuffix(s, rBlockString) {
+		panic(fmt.Errorf("lexer emitted block string token without delimiters"))
+	}
+
+	s = s[len(rBlockString) : len(s)-len(rBlockString)]
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	if len(lines) < 2 || lines[0] != "" {
+		return fmt.Errorf("block string must begin with a newline")
+	}
+	indent := lines[len(lines)-1]
+	if strings.TrimLeftFunc(indent, isIndent) != "" {
+		return fmt.Errorf("block string must end on its own line")
+	}
+	lines = lines[1 : len(lines)-1]
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, indent) {
+			return fmt.Errorf("block string line %d is not indented to the closing delimiter", i+1)
+		}
+		lines[i] = line[len(indent):]
+	}
+
+	v, err := unescapeString(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Returns s with escape sequences replaced, and CRLF newlines normalized to LF.
+func unescapeString(s string) (string, error) {
+	r := strings.NewReader(s)
 	var b strings.Builder
 	for {
 		c, _, err := r.ReadRune()
@@ -234,8 +461,18 @@ func (d *Decoder) decodeString(a *any, s string) error {
 				b.WriteRune(rEscape)
 			case rString:
 				b.WriteRune(rString)
+			case rEscapeTab:
+				b.WriteRune('\t')
+			case rEscapeNull:
+				b.WriteRune(0)
+			case rEscapeUnicode, rEscapeASCII:
+				p, err := decodeCodePoint(r)
+				if err != nil {
+					return "", err
+				}
+				b.WriteRune(p)
 			default:
-				return fmt.Errorf("string contains invalid escape `%c%c`", rEscape, c)
+				return "", fmt.Errorf("string contains invalid escape `%c%c`", rEscape, c)
 			}
 		case '\r':
 			if c, _, _ := r.ReadRune(); c == '\n' {
@@ -249,8 +486,30 @@ func (d *Decoder) decodeString(a *any, s string) error {
 			b.WriteRune(c)
 		}
 	}
-	*a = b.String()
-	return nil
+	return b.String(), nil
+}
+
+// Decodes the braced hexadecimal portion of a code point escape from r.
+func decodeCodePoint(r *strings.Reader) (rune, error) {
+	if c, _, _ := r.ReadRune(); c != rEscapeOpen {
+		return 0, fmt.Errorf("code point escape must begin with `%c`", rEscapeOpen)
+	}
+	var digits strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return 0, fmt.Errorf("code point escape must end with `%c`", rEscapeClose)
+		}
+		if c == rEscapeClose {
+			break
+		}
+		digits.WriteRune(c)
+	}
+	v, err := strconv.ParseUint(digits.String(), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(v)) {
+		return 0, fmt.Errorf("string contains invalid code point %q", digits.String())
+	}
+	return rune(v), nil
 }
 
 // Decodes a blob sequence into a.
@@ -279,8 +538,9 @@ loop:
 	return nil
 }
 
-// Decodes an array type of the form []any into a.
-func (d *Decoder) decodeArray(a *any) error {
+// Decodes an array type of the form []any into a. If label is not empty, the
+// array is assigned to the anchor once it has been decoded.
+func (d *Decoder) decodeArray(a *any, label string) error {
 	var varray = []any{}
 loop:
 	for {
@@ -289,9 +549,11 @@ loop:
 		}
 
 		var v any
+		d.push(Index(len(varray)))
 		if err := d.decodeValue(&v); err != nil {
 			return err
 		}
+		d.pop()
 
 		varray = append(varray, v)
 
@@ -311,13 +573,20 @@ loop:
 			break loop
 		}
 	}
+	if label != "" {
+		d.anchors[label] = varray
+	}
 	*a = varray
 	return nil
 }
 
-// Decodes a map type of the form map[any]any into a.
-func (d *Decoder) decodeMap(a *any) error {
+// Decodes a map type of the form map[any]any into a. If label is not empty,
+// the map is assigned to the anchor before its entries are decoded.
+func (d *Decoder) decodeMap(a *any, label string) error {
 	var vmap = map[any]any{}
+	if label != "" {
+		d.anchors[label] = vmap
+	}
 loop:
 	for {
 		if d.ifToken(tMapClose) {
@@ -335,9 +604,11 @@ loop:
 		}
 
 		var v any
+		d.push(Key{k})
 		if err := d.decodeValue(&v); err != nil {
 			return err
 		}
+		d.pop()
 
 		vmap[k] = v
 
@@ -361,9 +632,13 @@ loop:
 	return nil
 }
 
-// Decodes a struct type of the form map[string]any into a.
-func (d *Decoder) decodeStruct(a *any) error {
+// Decodes a struct type of the form map[string]any into a. If label is not
+// empty, the struct is assigned to the anchor before its fields are decoded.
+func (d *Decoder) decodeStruct(a *any, label string) error {
 	var vstruct = map[string]any{}
+	if label != "" {
+		d.anchors[label] = vstruct
+	}
 loop:
 	for {
 		t, err := d.nextToken()
@@ -383,9 +658,11 @@ loop:
 		}
 
 		var v any
+		d.push(Field(t.Value))
 		if err := d.decodeValue(&v); err != nil {
 			return err
 		}
+		d.pop()
 
 		vstruct[t.Value] = v
 
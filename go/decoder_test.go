@@ -4,10 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
-
-	"github.com/anaminus/deep"
 )
 
 var sampleControl = _struct{
@@ -226,7 +225,7 @@ func testDecodeEach(t *testing.T, tests map[string]result) {
 			if err != nil {
 				return
 			}
-			if diffs := deep.Equal(v, result.v); len(diffs) > 0 {
+			if diffs := Diff(v, result.v); len(diffs) > 0 {
 				for _, d := range diffs {
 					t.Log(d)
 				}
@@ -249,7 +248,7 @@ func TestDecoder(t *testing.T) {
 		t.Fatalf("%s", err)
 	}
 
-	if diffs := deep.Equal(v, sampleControl); len(diffs) > 0 {
+	if diffs := Diff(v, sampleControl); len(diffs) > 0 {
 		for _, d := range diffs {
 			t.Log(d)
 		}
@@ -260,3 +259,86 @@ func TestDecoder(t *testing.T) {
 	testDecodeEach(t, testComposites)
 	testDecodeEach(t, testExtra)
 }
+
+func TestDecodeFloatRange(t *testing.T) {
+	for _, s := range []string{`1.0e400`, `-1.0e400`} {
+		d := NewDecoder(strings.NewReader(s))
+		var v any
+		if err := d.Decode(&v); err == nil {
+			t.Errorf("%s: expected out of range error, got %v", s, v)
+		}
+	}
+}
+
+func TestDecodeReferences(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`&1 {Self: *1, Shared: [&2 (1: 2), *2]}`))
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s := v.(map[string]any)
+	if reflect.ValueOf(s["Self"]).Pointer() != reflect.ValueOf(s).Pointer() {
+		t.Errorf("expected Self to refer to root struct")
+	}
+	shared := s["Shared"].([]any)
+	if reflect.ValueOf(shared[0]).Pointer() != reflect.ValueOf(shared[1]).Pointer() {
+		t.Errorf("expected Shared elements to refer to the same map")
+	}
+}
+
+func TestDecodeAnnotations(t *testing.T) {
+	const s = `<root> {A: <int32> 1, B: [<x> &1 (1: 2), *1]}`
+	var v any
+	if err := NewDecoder(strings.NewReader(s)).Decode(&v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !Equal(v, _struct{"A": _int(1), "B": _array{_map{_int(1): _int(2)}, _map{_int(1): _int(2)}}}) {
+		t.Errorf("expected annotations to be discarded, got %#v", v)
+	}
+
+	d := NewDecoder(strings.NewReader(s))
+	d.SetAnnotations(true)
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	m := _map{_int(1): _int(2)}
+	want := Annotated{"root", _struct{
+		"A": Annotated{"int32", _int(1)},
+		"B": _array{Annotated{"x", m}, m},
+	}}
+	if diffs := Diff(v, want); len(diffs) > 0 {
+		for _, d := range diffs {
+			t.Log(d)
+		}
+		t.Errorf("decoded annotations not equal to control")
+	}
+}
+
+func TestDecodePositions(t *testing.T) {
+	d := NewDecoder(strings.NewReader("# comment\n[1, <a> {A: &1 (\"k\": 2)}, *1]"))
+	d.SetPositions(true)
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := Positions{
+		`.`:          {Offset: 10, Line: 2, Column: 1},
+		`[0]`:        {Offset: 11, Line: 2, Column: 2},
+		`[1]`:        {Offset: 18, Line: 2, Column: 9},
+		`[1].A`:      {Offset: 22, Line: 2, Column: 13},
+		`[1].A("k")`: {Offset: 31, Line: 2, Column: 22},
+		`[2]`:        {Offset: 36, Line: 2, Column: 27},
+	}
+	ps := d.Positions()
+	if len(ps) != len(want) {
+		t.Errorf("expected %v, got %v", want, ps)
+	}
+	for k, p := range want {
+		if ps[k] != p {
+			t.Errorf("%s: expected %+v, got %+v", k, p, ps[k])
+		}
+	}
+	if p, ok := ps.Lookup(Path{Index(2), Key{"k"}}); !ok || p != want[`[2]`] {
+		t.Errorf("expected lookup of ancestor, got %v", p)
+	}
+}
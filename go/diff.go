@@ -0,0 +1,302 @@
+package rod
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffKind indicates how a value differs between two documents.
+type DiffKind int
+
+const (
+	_ DiffKind = iota
+	// Added indicates that a value is present only in the second document.
+	Added
+	// Removed indicates that a value is present only in the first document.
+	Removed
+	// Changed indicates that a value differs between documents, while retaining
+	// the same type. A composite is changed only if its annotation differs.
+	Changed
+	// TypeChanged indicates that a value has a different type in each document.
+	TypeChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	default:
+		return "invalid"
+	}
+}
+
+// Difference describes a single difference between two documents.
+type Difference struct {
+	// Path locates the value. Elements of arrays are located by their index in
+	// the first document, except for added elements, which are located by
+	// their index in the second document.
+	Path Path
+	Kind DiffKind
+	// A is the value from the first document, or nil if Kind is Added.
+	A any
+	// B is the value from the second document, or nil if Kind is Removed.
+	B any
+}
+
+// String returns the difference formatted on a single line, as the path
+// followed by the values. The line is prefixed with a symbol indicating the
+// kind: "+" for Added, "-" for Removed, "~" for Changed, and "!" for
+// TypeChanged. Changed values are formatted as "old -> new", and each
+// type-changed value is preceded by the name of its type.
+func (d Difference) String() string {
+	var b strings.Builder
+	switch d.Kind {
+	case Added:
+		b.WriteString("+ ")
+		b.WriteString(d.Path.String())
+		b.WriteString(": ")
+		b.WriteString(formatInline(d.B))
+	case Removed:
+		b.WriteString("- ")
+		b.WriteString(d.Path.String())
+		b.WriteString(": ")
+		b.WriteString(formatInline(d.A))
+	case Changed:
+		b.WriteString("~ ")
+		b.WriteString(d.Path.String())
+		b.WriteString(": ")
+		b.WriteString(formatInline(d.A))
+		b.WriteString(" -> ")
+		b.WriteString(formatInline(d.B))
+	case TypeChanged:
+		b.WriteString("! ")
+		b.WriteString(d.Path.String())
+		b.WriteString(": ")
+		b.WriteString(typeName(d.A))
+		b.WriteByte(rSpace)
+		b.WriteString(formatInline(d.A))
+		b.WriteString(" -> ")
+		b.WriteString(typeName(d.B))
+		b.WriteByte(rSpace)
+		b.WriteString(formatInline(d.B))
+	}
+	return b.String()
+}
+
+// WriteDiff writes each difference to w, one per line.
+func WriteDiff(w io.Writer, diffs []Difference) error {
+	for _, d := range diffs {
+		if _, err := io.WriteString(w, d.String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff returns the differences between the decoded values a and b, ordered as
+// they appear in the canonical encoding. Values are compared as by Equal, with
+// the same options. Maps are compared by key, and structs by field name. Arrays
+// are compared by matching their longest common subsequence of equal elements,
+// so that inserting or removing an element produces a single difference.
+// Unmatched elements at the same relative position are compared with each
+// other, and any remaining elements are added or removed.
+//
+// Diff returns nil if the values are equal.
+func Diff(a, b any, opts ...Option) []Difference {
+	c := newComparer(opts)
+	c.diffValue(nil, a, b)
+	return c.diffs
+}
+
+func (c *comparer) add(path Path, kind DiffKind, a, b any) {
+	c.diffs = append(c.diffs, Difference{Path: path, Kind: kind, A: a, B: b})
+}
+
+func (c *comparer) diffValue(path Path, a, b any) {
+	if m, x := c.matcherOf(a); m != nil {
+		c.diffMatcher(path, m, x, unannotate(b))
+		return
+	}
+	if c.ignoreAnnotations || c.matchers != nil {
+		a, b = unannotate(a), unannotate(b)
+	}
+	if typeName(a) != typeName(b) {
+		c.add(path, TypeChanged, a, b)
+		return
+	}
+	x, xok := annotationOf(a)
+	y, yok := annotationOf(b)
+	if x != y || xok != yok {
+		c.add(path, Changed, a, b)
+		return
+	}
+	a, b = unannotate(a), unannotate(b)
+	leave, ok := c.visit(a, b)
+	if !ok {
+		return
+	}
+	defer leave()
+	switch a := a.(type) {
+	case []any:
+		if c.unorderedArrays {
+			c.diffUnordered(path, a, b.([]any))
+		} else {
+			c.diffArray(path, a, b.([]any))
+		}
+	case map[any]any:
+		c.diffMap(path, a, b.(map[any]any))
+	case map[string]any:
+		c.diffStruct(path, a, b.(map[string]any))
+	default:
+		if !c.equalPrimitive(a, b) {
+			c.add(path, Changed, a, b)
+		}
+	}
+}
+
+func (c *comparer) diffArray(path Path, a, b []any) {
+	// Length of the longest common subsequence of a[i:] and b[j:].
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	equal := make([][]bool, n)
+	for i := n - 1; i >= 0; i-- {
+		equal[i] = make([]bool, m)
+		for j := m - 1; j >= 0; j-- {
+			equal[i][j] = c.equal(a[i], b[j])
+			if equal[i][j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Compares the unmatched runs a[i:ei] and b[j:ej].
+	gap := func(i, ei, j, ej int) {
+		for ; i < ei && j < ej; i, j = i+1, j+1 {
+			c.diffValue(path.with(Index(i)), a[i], b[j])
+		}
+		for ; i < ei; i++ {
+			c.add(path.with(Index(i)), Removed, a[i], nil)
+		}
+		for ; j < ej; j++ {
+			c.add(path.with(Index(j)), Added, nil, b[j])
+		}
+	}
+
+	i, j := 0, 0
+	si, sj := 0, 0
+	for i < n && j < m {
+		switch {
+		case equal[i][j]:
+			gap(si, i, sj, j)
+			i++
+			j++
+			si, sj = i, j
+		case lcs[i+1][j] > lcs[i][j+1]:
+			i++
+		case lcs[i+1][j] == lcs[i][j+1] && i-j < n-m:
+			// Either choice is as long, so stay near the diagonal, which
+			// pairs up unmatched elements at the same relative position.
+			i++
+		default:
+			j++
+		}
+	}
+	gap(si, n, sj, m)
+}
+
+// Compares arrays as multisets. Elements of a that are not paired with an equal
+// element of b are compared in order with the unpaired elements of b.
+func (c *comparer) diffUnordered(path Path, a, b []any) {
+	match := c.matchUnordered(a, b)
+	paired := make([]bool, len(b))
+	for _, j := range match {
+		if j >= 0 {
+			paired[j] = true
+		}
+	}
+	var ua, ub []int
+	for i, j := range match {
+		if j < 0 {
+			ua = append(ua, i)
+		}
+	}
+	for j, ok := range paired {
+		if !ok {
+			ub = append(ub, j)
+		}
+	}
+	for len(ua) > 0 && len(ub) > 0 {
+		c.diffValue(path.with(Index(ua[0])), a[ua[0]], b[ub[0]])
+		ua, ub = ua[1:], ub[1:]
+	}
+	for _, i := range ua {
+		c.add(path.with(Index(i)), Removed, a[i], nil)
+	}
+	for _, j := range ub {
+		c.add(path.with(Index(j)), Added, nil, b[j])
+	}
+}
+
+func (c *comparer) diffMap(path Path, a, b map[any]any) {
+	keys := make([]any, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := c.lookupKey(a, k); !ok {
+			keys = append(keys, k)
+		}
+	}
+	sortKeys(keys)
+	for _, k := range keys {
+		va, oka := c.lookupKey(a, k)
+		vb, okb := c.lookupKey(b, k)
+		switch {
+		case !okb:
+			c.add(path.with(Key{k}), Removed, va, nil)
+		case !oka:
+			c.add(path.with(Key{k}), Added, nil, vb)
+		default:
+			c.diffValue(path.with(Key{k}), va, vb)
+		}
+	}
+}
+
+func (c *comparer) diffStruct(path Path, a, b map[string]any) {
+	fields := make([]string, 0, len(a)+len(b))
+	for f := range a {
+		fields = append(fields, f)
+	}
+	for f := range b {
+		if _, ok := a[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		va, oka := a[f]
+		vb, okb := b[f]
+		switch {
+		case !okb:
+			c.add(path.with(Field(f)), Removed, va, nil)
+		case !oka:
+			c.add(path.with(Field(f)), Added, nil, vb)
+		default:
+			c.diffValue(path.with(Field(f)), va, vb)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package rod
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		want []string
+	}{
+		{"equal", _struct{"A": _int(1), "B": _array{_float(math.NaN())}}, _struct{"B": _array{_float(math.NaN())}, "A": _int(1)}, nil},
+		{"nil blob", _blob(nil), _blob{}, nil},
+		{"root", _int(1), _int(2), []string{"~ .: 1 -> 2"}},
+		{"negative zero", _float(0), _float(math.Copysign(0, -1)), []string{"~ .: 0.0 -> -0.0"}},
+		{"type changed", _struct{"A": _int(0)}, _struct{"A": "0"}, []string{`! .A: int 0 -> string "0"`}},
+		{"int to float", _int(1), _float(1), []string{"! .: int 1 -> float 1.0"}},
+		{
+			"fields",
+			_struct{"A": _int(1), "B": _int(2)},
+			_struct{"B": _int(3), "C": _int(4)},
+			[]string{"- .A: 1", "~ .B: 2 -> 3", "+ .C: 4"},
+		},
+		{
+			"keys",
+			_map{"a": true, _int(2): nil, _float(math.NaN()): _int(1)},
+			_map{"b": true, _int(2): nil, _float(math.NaN()): _int(2)},
+			[]string{`~ (nan): 1 -> 2`, `- ("a"): true`, `+ ("b"): true`},
+		},
+		{
+			"nested",
+			_struct{"Blobs": _map{_int(3): _array{_blob{0}, _blob{1}, _blob{2}}}},
+			_struct{"Blobs": _map{_int(3): _array{_blob{0}, _blob{1}, _blob{2, 3}}}},
+			[]string{"~ .Blobs(3)[2]: |02| -> |02 03|"},
+		},
+		{
+			"insert",
+			_array{_int(1), _int(2), _int(3)},
+			_array{_int(0), _int(1), _int(2), _int(3)},
+			[]string{"+ [0]: 0"},
+		},
+		{
+			"remove",
+			_array{_int(1), _int(2), _int(3)},
+			_array{_int(1), _int(3)},
+			[]string{"- [1]: 2"},
+		},
+		{
+			"replace",
+			_array{_int(1), _struct{"A": _int(2)}, _int(3)},
+			_array{_int(1), _struct{"A": _int(4)}, _int(5), _int(3)},
+			[]string{"~ [1].A: 2 -> 4", "+ [2]: 5"},
+		},
+		{
+			"composite",
+			_array{},
+			_array{_map{"k": _array{"a\nb"}}},
+			[]string{`+ [0]: ("k": ["a\nb"])`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range Diff(tt.a, tt.b) {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	a := _struct{"N": _int(1)}
+	a["Self"] = a
+	b := _struct{"N": _int(2)}
+	b["Self"] = b
+	diffs := Diff(a, b)
+	if len(diffs) != 1 || diffs[0].String() != "~ .N: 1 -> 2" {
+		t.Errorf("unexpected diffs %v", diffs)
+	}
+	if diffs := Diff(a, a); diffs != nil {
+		t.Errorf("expected no diffs, got %v", diffs)
+	}
+	d := Difference{Path: Path{Field("A")}, Kind: Added, B: a}
+	if s := d.String(); s != "+ .A: {N: 1, Self: ...}" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
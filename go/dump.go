@@ -0,0 +1,319 @@
+package rod
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DumpOption configures Dump.
+type DumpOption func(*dumper)
+
+// DumpMaxDepth limits the depth of composites rendered by Dump. A composite
+// nested within n other composites is replaced by a marker. If n is zero or
+// less, then the depth is not limited.
+func DumpMaxDepth(n int) DumpOption {
+	return func(d *dumper) { d.maxDepth = n }
+}
+
+// DumpMaxLen limits the number of elements rendered by Dump for each slice,
+// array, map, and string. Elements beyond the first n are omitted. If n is
+// zero or less, then the length is not limited.
+func DumpMaxLen(n int) DumpOption {
+	return func(d *dumper) { d.maxLen = n }
+}
+
+// Dump returns a ROD rendering of any Go value, intended for debugging. Unlike
+// Encode, Dump never fails: values are rendered through reflection, including
+// unexported struct fields.
+//
+// Go values are rendered as follows:
+//
+//	bool, ints, floats     bool, int, float; a uint that overflows an int is
+//	                       rendered as a string annotated with <overflow>
+//	complex                {Real: float, Imag: float}, annotated with its type
+//	string                 string, or blob if it is not valid UTF-8
+//	[]byte, [N]byte        blob
+//	slice, array           array
+//	map                    map; a key that is not primitive is rendered as a
+//	                       string containing the key formatted on one line
+//	struct                 struct with a field for each named field
+//	pointer                the value it points to
+//	interface              the dynamic value, annotated with its type
+//	chan                   {Len: int, Cap: int}, annotated with its type
+//	func                   the name of the function, annotated with its type
+//	unsafe.Pointer         {}, annotated with its type
+//
+// A nil pointer, slice, map, interface, chan, or func is rendered as null. The
+// value passed to Dump is itself treated as the dynamic value of an interface.
+//
+// A pointer, slice, or map that refers to a value that is already being
+// rendered is replaced by null, annotated with the type and "cycle", such as
+// <*pkg.Node, cycle>. Likewise, a composite beyond the maximum depth is
+// annotated with "max depth". A value truncated by the maximum length has an
+// annotation containing its original length, such as <len 100>.
+//
+// Map entries are sorted by key. Entries whose keys render identically are
+// merged.
+func Dump(v any, opts ...DumpOption) string {
+	d := dumper{visiting: map[dumpIdentity]bool{}}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	var r any
+	if v != nil {
+		r = d.dump(reflect.ValueOf(v), 0, true)
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetInvalidAsBlob(true)
+	if err := e.Encode(r); err != nil {
+		// Not expected; render the error rather than nothing.
+		e.Encode(Annotated{Annotation: "error", Value: err.Error()})
+	}
+	return buf.String()
+}
+
+type dumper struct {
+	maxDepth int
+	maxLen   int
+	// Pointers, slices, and maps currently being rendered.
+	visiting map[dumpIdentity]bool
+}
+
+// Identifies the referent of a pointer, slice, or map. The type distinguishes
+// a struct from its first field.
+type dumpIdentity struct {
+	t   reflect.Type
+	ptr uintptr
+	len int
+}
+
+// Returns the decoded value representing v at the given depth. If typed is
+// true, then v is the dynamic value of an interface, and its type is included
+// in the annotation.
+func (d *dumper) dump(v reflect.Value, depth int, typed bool) any {
+	var notes []string
+	if typed {
+		notes = append(notes, dumpType(v.Type()))
+	}
+	r := d.value(v, depth, &notes)
+	if len(notes) == 0 {
+		return r
+	}
+	return Annotated{Annotation: strings.Join(notes, ", "), Value: r}
+}
+
+// Returns the unannotated value representing v. Notes to be included in the
+// annotation are appended to notes.
+func (d *dumper) value(v reflect.Value, depth int, notes *[]string) any {
+	note := func(s string) { *notes = append(*notes, s) }
+	// Records that a composite is being rendered, returning false if it is
+	// already being rendered.
+	enter := func(id dumpIdentity) bool {
+		if d.visiting[id] {
+			if len(*notes) == 0 {
+				note(dumpType(v.Type()))
+			}
+			note("cycle")
+			return false
+		}
+		d.visiting[id] = true
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if d.maxDepth > 0 && depth >= d.maxDepth {
+			if len(*notes) == 0 {
+				note(dumpType(v.Type()))
+			}
+			note("max depth")
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			note("overflow")
+			return strconv.FormatUint(u, 10)
+		}
+		return int64(u)
+	case reflect.Float32:
+		// Render the shortest representation of the float32 rather than that
+		// of the float64 it converts to.
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(v.Float(), 'g', -1, 32), 64)
+		return f
+	case reflect.Float64:
+		return v.Float()
+	case reflect.Complex64, reflect.Complex128:
+		if len(*notes) == 0 {
+			note(dumpType(v.Type()))
+		}
+		c := v.Complex()
+		return map[string]any{"Real": real(c), "Imag": imag(c)}
+	case reflect.String:
+		s := v.String()
+		if n := utf8.RuneCountInString(s); d.maxLen > 0 && n > d.maxLen {
+			note("len " + strconv.Itoa(n))
+			n := 0
+			for i := range s {
+				if n == d.maxLen {
+					s = s[:i]
+					break
+				}
+				n++
+			}
+		}
+		return s
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		// The dynamic type supersedes the type of the interface.
+		*notes = []string{dumpType(v.Elem().Type())}
+		return d.value(v.Elem(), depth, notes)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		if !enter(dumpIdentity{t: v.Type(), ptr: v.Pointer()}) {
+			return nil
+		}
+		defer delete(d.visiting, dumpIdentity{t: v.Type(), ptr: v.Pointer()})
+		return d.value(v.Elem(), depth, notes)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		id := dumpIdentity{t: v.Type(), ptr: v.Pointer(), len: v.Len()}
+		if !enter(id) {
+			return nil
+		}
+		defer delete(d.visiting, id)
+		return d.list(v, depth, notes)
+	case reflect.Array:
+		return d.list(v, depth, notes)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		id := dumpIdentity{t: v.Type(), ptr: v.Pointer()}
+		if !enter(id) {
+			return nil
+		}
+		defer delete(d.visiting, id)
+		return d.dumpMap(v, depth, notes)
+	case reflect.Struct:
+		s := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Name
+			if name == "_" {
+				continue
+			}
+			s[name] = d.dump(v.Field(i), depth+1, false)
+		}
+		return s
+	case reflect.Chan:
+		if len(*notes) == 0 {
+			note(dumpType(v.Type()))
+		}
+		if v.IsNil() {
+			return nil
+		}
+		return map[string]any{"Len": int64(v.Len()), "Cap": int64(v.Cap())}
+	case reflect.Func:
+		if len(*notes) == 0 {
+			note(dumpType(v.Type()))
+		}
+		if v.IsNil() {
+			return nil
+		}
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			return f.Name()
+		}
+		return "func"
+	case reflect.UnsafePointer:
+		if len(*notes) == 0 {
+			note(dumpType(v.Type()))
+		}
+		if v.IsNil() {
+			return nil
+		}
+		return map[string]any{}
+	}
+	note("invalid")
+	return nil
+}
+
+// Returns the value representing the slice or array v.
+func (d *dumper) list(v reflect.Value, depth int, notes *[]string) any {
+	n := v.Len()
+	if d.maxLen > 0 && n > d.maxLen {
+		*notes = append(*notes, "len "+strconv.Itoa(n))
+		n = d.maxLen
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(v.Index(i).Uint())
+		}
+		return b
+	}
+	a := make([]any, n)
+	for i := range a {
+		a[i] = d.dump(v.Index(i), depth+1, false)
+	}
+	return a
+}
+
+// Returns the value representing the map v.
+func (d *dumper) dumpMap(v reflect.Value, depth int, notes *[]string) any {
+	type entry struct {
+		key   any
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	seen := map[any]bool{}
+	for iter := v.MapRange(); iter.Next(); {
+		k := d.dump(iter.Key(), depth+1, false)
+		switch unannotate(k).(type) {
+		case nil, bool, int64, float64, string:
+		default:
+			// Includes blobs, which are not comparable.
+			k = formatInline(k)
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		entries = append(entries, entry{k, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return keyLess(entries[i].key, entries[j].key)
+	})
+	if d.maxLen > 0 && len(entries) > d.maxLen {
+		*notes = append(*notes, "len "+strconv.Itoa(v.Len()))
+		entries = entries[:d.maxLen]
+	}
+	m := make(map[any]any, len(entries))
+	for _, e := range entries {
+		m[e.key] = d.dump(e.value, depth+1, false)
+	}
+	return m
+}
+
+// Returns the name of t as it appears in an annotation.
+func dumpType(t reflect.Type) string {
+	return strings.ReplaceAll(t.String(), string(rAnnotationEnd), `\x3e`)
+}
@@ -0,0 +1,163 @@
+package rod
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type dumpNode struct {
+	Name     string
+	next     *dumpNode
+	Children []any
+	private  map[[2]byte]float32
+	_        int
+}
+
+func dumpFunc() {}
+
+func TestDump(t *testing.T) {
+	n := &dumpNode{Name: "a", private: map[[2]byte]float32{{1, 2}: 0.1}}
+	n.next = n
+	n.Children = []any{int8(1), nil, &dumpNode{Name: "b"}, nil}
+	n.Children[3] = n.Children
+	ch := make(chan int, 3)
+	ch <- 1
+	type value struct {
+		Node    *dumpNode
+		Nil     *dumpNode
+		Any     any
+		Chan    chan int
+		NilChan <-chan int
+		Func    func()
+		NilFunc func(int) error
+		Complex complex64
+		Big     uint64
+		Invalid string
+		Bytes   []byte
+		Array   [2]uint16
+	}
+	v := value{
+		Node:    n,
+		Any:     map[string]any{"x": math.Inf(1)},
+		Chan:    ch,
+		Func:    dumpFunc,
+		Complex: 1 + 2i,
+		Big:     math.MaxUint64,
+		Invalid: "\xff",
+		Bytes:   []byte("ab"),
+		Array:   [2]uint16{1, 2},
+	}
+	got := Dump(v)
+	want := `<rod.value> {
+	Any: <map[string]interface {}> (
+		"x": <float64> inf,
+	),
+	Array: [
+		1,
+		2,
+	],
+	Big: <overflow> "18446744073709551615",
+	Bytes: |
+		61 62                                            #ab#
+	|,
+	Chan: <chan int> {
+		Cap: 3,
+		Len: 1,
+	},
+	Complex: <complex64> {
+		Imag: 2.0,
+		Real: 1.0,
+	},
+	Func: <func()> "github.com/anaminus/rod/go.dumpFunc",
+	Invalid: |
+		ff                                               #.#
+	|,
+	Nil: null,
+	NilChan: <<-chan int> null,
+	NilFunc: <func(int) error> null,
+	Node: {
+		Children: [
+			<int8> 1,
+			null,
+			<*rod.dumpNode> {
+				Children: null,
+				Name: "b",
+				next: null,
+				private: null,
+			},
+			<[]interface {}, cycle> null,
+		],
+		Name: "a",
+		next: <*rod.dumpNode, cycle> null,
+		private: (
+			"|01 02|": 0.1,
+		),
+	},
+}`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	var d any
+	if err := NewDecoder(strings.NewReader(got)).Decode(&d); err != nil {
+		t.Errorf("decode: %s", err)
+	}
+}
+
+func TestDumpLimits(t *testing.T) {
+	v := []any{
+		"abcdé",
+		[]int{1, 2, 3, 4},
+		map[int]string{3: "c", 1: "a", 2: "b"},
+		[][]int{{1}},
+	}
+	got := Dump(v, DumpMaxDepth(2), DumpMaxLen(4))
+	want := `<[]interface {}> [
+	<string, len 5> "abcd",
+	<[]int> [
+		1,
+		2,
+		3,
+		4,
+	],
+	<map[int]string> (
+		1: "a",
+		2: "b",
+		3: "c",
+	),
+	<[][]int> [
+		<[]int, max depth> null,
+	],
+]`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	got = Dump(v, DumpMaxLen(2))
+	got += Dump(v[2], DumpMaxLen(2))
+	for _, s := range []string{
+		`<[]interface {}, len 4> [`,
+		`<string, len 5> "ab"`,
+		`<[]int, len 4> [`,
+		`<map[int]string, len 3> (`,
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("expected %q in:\n%s", s, got)
+		}
+	}
+
+	// Primitive keys of an interface type keep their annotation.
+	got = Dump(map[any]int{"a": 1, int8(2): 2, [1]int{3}: 3})
+	want = `<map[interface {}]int> (
+	<int8> 2: 2,
+	"<[1]int> [3]": 3,
+	<string> "a": 1,
+)`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := Dump(nil); got != "null" {
+		t.Errorf("expected null, got %s", got)
+	}
+}
@@ -1,31 +1,65 @@
 package rod
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Encoder struct {
-	w *bufio.Writer
+	dst io.Writer    // Destination of complete documents.
+	w   bytes.Buffer // Stages the current document.
 
 	lead []byte
+
+	invalidAsBlob bool
+	references    bool
+	transform     Transform
+
+	visiting map[identity]bool   // Composites currently being encoded.
+	shared   map[identity]bool   // Composites that appear more than once.
+	labels   map[identity]string // Anchors assigned to shared composites.
+
+	frames    []encodeFrame // Composites opened by the primitive methods.
+	annotated bool          // Whether an annotation awaits its value.
 }
 
 func NewEncoder(w io.Writer) *Encoder {
 	e := &Encoder{
-		w: bufio.NewWriter(w),
+		dst: w,
 	}
 	return e
 }
 
+// SetInvalidAsBlob sets whether a string containing invalid UTF-8 is encoded as
+// a blob of its bytes. If false, encoding such a string returns an error.
+func (e *Encoder) SetInvalidAsBlob(on bool) {
+	e.invalidAsBlob = on
+}
+
+// SetReferences sets whether a composite that appears more than once within a
+// value is encoded once with an anchor, and referred to elsewhere with a
+// reference. If false, such composites are duplicated, and a cyclic value
+// results in an error.
+func (e *Encoder) SetReferences(on bool) {
+	e.references = on
+}
+
+// SetTransform sets a transform that is applied to each value before it is
+// encoded, as by Apply. If t is nil, values are encoded as they are.
+func (e *Encoder) SetTransform(t Transform) {
+	e.transform = t
+}
+
 func (e *Encoder) push() {
 	e.lead = append(e.lead, '\t')
 }
@@ -39,17 +73,72 @@ func (e *Encoder) newline() {
 	e.w.Write(e.lead)
 }
 
+// Encode writes the ROD encoding of v to the underlying writer. The document is
+// staged in memory until it has been fully encoded, so if an error occurs, then
+// nothing is written.
+//
+// A Marshaler, including one within v, is encoded by calling its MarshalROD
+// method. The transform is not applied to a Marshaler, and composites written
+// by a Marshaler are never encoded as references.
 func (e *Encoder) Encode(v any) error {
+	e.w.Reset()
+	e.lead = e.lead[:0]
+	e.visiting = map[identity]bool{}
+	e.shared = nil
+	e.labels = map[identity]string{}
+	e.frames = e.frames[:0]
+	e.annotated = false
+	if _, ok := v.(Marshaler); !ok && e.transform != nil {
+		var err error
+		if v, err = Apply(v, e.transform); err != nil {
+			return err
+		}
+	}
+	if e.references {
+		e.shared = findShared(v)
+	}
 	if err := e.encodeValue(v); err != nil {
+		e.w.Reset()
 		return err
 	}
-	return e.w.Flush()
+	_, err := e.dst.Write(e.w.Bytes())
+	e.w.Reset()
+	return err
 }
 
 func (e *Encoder) encodeValue(v any) error {
+	if v, ok := v.(Annotated); ok {
+		return e.encodeAnnotated(v)
+	}
+	if v, ok := v.(Marshaler); ok {
+		return e.encodeMarshaler(v)
+	}
 	if ok, err := e.encodePrimitive(v); ok {
 		return err
 	}
+	id, ok := identityOf(v)
+	if ok {
+		if label, ok := e.labels[id]; ok {
+			if e.visiting[id] && id.array {
+				return errors.New("cannot encode array that contains itself")
+			}
+			e.w.WriteRune(rReference)
+			e.w.WriteString(label)
+			return nil
+		}
+		if e.visiting[id] {
+			return errors.New("cannot encode cyclic value")
+		}
+		if e.shared[id] {
+			label := strconv.Itoa(len(e.labels) + 1)
+			e.labels[id] = label
+			e.w.WriteRune(rAnchor)
+			e.w.WriteString(label)
+			e.w.WriteByte(rSpace)
+		}
+		e.visiting[id] = true
+		defer delete(e.visiting, id)
+	}
 	switch v := v.(type) {
 	case []any:
 		return e.encodeArray(v)
@@ -62,6 +151,77 @@ func (e *Encoder) encodeValue(v any) error {
 	}
 }
 
+func (e *Encoder) encodeAnnotated(v Annotated) error {
+	if strings.ContainsRune(v.Annotation, rAnnotationEnd) {
+		return fmt.Errorf("annotation cannot contain %q", rAnnotationEnd)
+	}
+	if _, ok := v.Value.(Annotated); ok {
+		return errors.New("cannot annotate an annotated value")
+	}
+	e.w.WriteRune(rAnnotation)
+	e.w.WriteString(v.Annotation)
+	e.w.WriteRune(rAnnotationEnd)
+	e.w.WriteByte(rSpace)
+	return e.encodeValue(v.Value)
+}
+
+// Identifies the underlying data of a composite value.
+type identity struct {
+	array bool
+	ptr   uintptr
+	len   int
+}
+
+// Returns the identity of v, and whether v is a composite with an identity. An
+// empty array has no identity.
+func identityOf(v any) (id identity, ok bool) {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			return id, false
+		}
+		return identity{array: true, ptr: reflect.ValueOf(v).Pointer(), len: len(v)}, true
+	case map[any]any, map[string]any:
+		return identity{ptr: reflect.ValueOf(v).Pointer()}, true
+	default:
+		return id, false
+	}
+}
+
+// Returns the set of composites that appear more than once within v.
+func findShared(v any) map[identity]bool {
+	seen := map[identity]bool{}
+	shared := map[identity]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		if id, ok := identityOf(v); ok {
+			if seen[id] {
+				shared[id] = true
+				return
+			}
+			seen[id] = true
+		}
+		switch v := v.(type) {
+		case Annotated:
+			walk(v.Value)
+		case []any:
+			for _, v := range v {
+				walk(v)
+			}
+		case map[any]any:
+			for _, v := range v {
+				walk(v)
+			}
+		case map[string]any:
+			for _, v := range v {
+				walk(v)
+			}
+		}
+	}
+	walk(v)
+	return shared
+}
+
 func (e *Encoder) encodePrimitive(v any) (ok bool, err error) {
 	switch v := v.(type) {
 	case nil:
@@ -110,33 +270,105 @@ func (e *Encoder) encodeFloat(v float64) error {
 	case v != v:
 		e.w.WriteString(rNaN)
 	default:
-		s := strconv.FormatFloat(v, 'f', -1, 64)
-		e.w.WriteString(s)
+		e.w.WriteString(formatFloat(v))
+	}
+	return nil
+}
+
+// Bounds of the decimal exponent within which a finite float is formatted
+// without an exponent.
+const (
+	minPlainExp = -7
+	maxPlainExp = 21
+)
+
+// Returns the canonical representation of a finite float. Values with a
+// decimal exponent within (minPlainExp, maxPlainExp) are formatted as plain
+// decimals. Otherwise, the value is formatted with a single digit before the
+// decimal, followed by an exponent without a positive sign or leading zeros.
+// In either case, the mantissa always contains a decimal.
+func formatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'e', -1, 64)
+	i := strings.IndexRune(s, rExponent)
+	mant, exp := s[:i], s[i+1:]
+	x, _ := strconv.Atoi(exp)
+	if minPlainExp < x && x < maxPlainExp {
+		s = strconv.FormatFloat(v, 'f', -1, 64)
 		if strings.IndexRune(s, rDecimal) < 0 {
 			// Force decimal.
-			e.w.WriteRune(rDecimal)
-			e.w.WriteByte('0')
+			s += string(rDecimal) + "0"
 		}
+		return s
 	}
-	return nil
+	if strings.IndexRune(mant, rDecimal) < 0 {
+		// Force decimal.
+		mant += string(rDecimal) + "0"
+	}
+	return mant + string(rExponent) + strconv.Itoa(x)
 }
 
 func (e *Encoder) encodeString(v string) error {
+	if !utf8.ValidString(v) {
+		if e.invalidAsBlob {
+			return e.encodeBlob([]byte(v))
+		}
+		return errors.New("string contains invalid UTF-8")
+	}
+	if hasLineBreak(v) {
+		return e.encodeBlockString(v)
+	}
+	e.w.WriteRune(rString)
+	e.encodeStringContent(v, false)
 	e.w.WriteRune(rString)
+	return nil
+}
+
+// Encodes a string containing line breaks as a block string, with each line
+// indented one level deeper than the current line.
+func (e *Encoder) encodeBlockString(v string) error {
+	e.w.WriteString(rBlockString)
+	e.push()
+	e.w.WriteByte('\n')
+	e.encodeStringContent(v, true)
+	e.newline()
+	e.w.WriteString(rBlockString)
+	e.pop()
+	return nil
+}
+
+// Writes the content of a string, escaping characters as needed. If block is
+// true, then each line is indented, tabs are written literally, and a quote is
+// escaped when it would otherwise close the string.
+func (e *Encoder) encodeStringContent(v string, block bool) {
 	crlf := false
+	bol := block // At beginning of line.
+	quotes := 0  // Number of consecutive unescaped quotes.
 	for i, r := range v {
+		if bol && r != '\n' {
+			e.w.Write(e.lead)
+		}
+		bol = false
+		if r != rString {
+			quotes = 0
+		}
 		switch r {
-		case rString, rEscape:
-			e.w.WriteRune(rEscape)
-		case '\r':
-			// If next character is \n.
-			if i+1 < len(v) && v[i+1] == '\n' {
-				// Escape explicit CRLF so that decoder interprets it correctly.
+		case rString:
+			if !block {
 				e.w.WriteRune(rEscape)
-				e.w.WriteRune(rEscapeCR)
-				crlf = true
-				continue
+			} else if quotes++; quotes == len(rBlockString) {
+				e.w.WriteRune(rEscape)
+				quotes = 0
 			}
+		case rEscape:
+			e.w.WriteRune(rEscape)
+		case '\r':
+			// Escape all carriage returns so that the decoder does not
+			// normalize them, and so that editors do not mangle them. If next
+			// character is \n, it is escaped as well.
+			crlf = i+1 < len(v) && v[i+1] == '\n'
+			e.w.WriteRune(rEscape)
+			e.w.WriteRune(rEscapeCR)
+			continue
 		case '\n':
 			if crlf {
 				e.w.WriteRune(rEscape)
@@ -144,11 +376,51 @@ func (e *Encoder) encodeString(v string) error {
 				crlf = false
 				continue
 			}
+			bol = block
+		case '\t':
+			if !block {
+				e.w.WriteRune(rEscape)
+				e.w.WriteRune(rEscapeTab)
+				continue
+			}
+		case 0:
+			e.w.WriteRune(rEscape)
+			e.w.WriteRune(rEscapeNull)
+			continue
+		default:
+			if !unicode.IsPrint(r) {
+				e.encodeCodePoint(r)
+				continue
+			}
 		}
 		e.w.WriteRune(r)
 	}
-	e.w.WriteRune(rString)
-	return nil
+}
+
+// Returns whether s contains a line feed that would be written literally.
+func hasLineBreak(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' && (i == 0 || s[i-1] != '\r') {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes a non-printable rune as an escaped code point. ASCII characters are
+// written with two digits, while others are written with at least four.
+func (e *Encoder) encodeCodePoint(r rune) {
+	e.w.WriteRune(rEscape)
+	if r <= unicode.MaxASCII {
+		e.w.WriteRune(rEscapeASCII)
+		e.w.WriteRune(rEscapeOpen)
+		fmt.Fprintf(&e.w, "%02x", r)
+	} else {
+		e.w.WriteRune(rEscapeUnicode)
+		e.w.WriteRune(rEscapeOpen)
+		fmt.Fprintf(&e.w, "%04x", r)
+	}
+	e.w.WriteRune(rEscapeClose)
 }
 
 func (e *Encoder) encodeBlob(v []byte) error {
@@ -224,10 +496,10 @@ func (e *Encoder) encodeBlob(v []byte) error {
 func (e *Encoder) encodeArray(v []any) error {
 	e.w.WriteRune(rArrayOpen)
 	e.push()
-	for _, v := range v {
+	for i, v := range v {
 		e.newline()
 		if err := e.encodeValue(v); err != nil {
-			return err
+			return prependStep(Index(i), err)
 		}
 		e.w.WriteRune(rSep)
 	}
@@ -242,15 +514,13 @@ func (e *Encoder) encodeMap(v map[any]any) error {
 	e.push()
 	err := mapForEach(v, func(k, v any) error {
 		e.newline()
-		if ok, err := e.encodePrimitive(k); !ok {
-			return fmt.Errorf("cannot encode type %T as map key", v)
-		} else if err != nil {
+		if err := e.encodeKey(k); err != nil {
 			return err
 		}
 		e.w.WriteRune(rAssoc)
 		e.w.WriteByte(rSpace)
 		if err := e.encodeValue(v); err != nil {
-			return err
+			return prependStep(Key{k}, err)
 		}
 		e.w.WriteRune(rSep)
 		return nil
@@ -264,6 +534,18 @@ func (e *Encoder) encodeMap(v map[any]any) error {
 	return nil
 }
 
+// Encodes a map key, which is a primitive, optionally annotated.
+func (e *Encoder) encodeKey(k any) error {
+	if typeIndex(unannotate(k)) == 0 {
+		return fmt.Errorf("cannot encode type %T as map key", unannotate(k))
+	}
+	if a, ok := k.(Annotated); ok {
+		return e.encodeAnnotated(a)
+	}
+	_, err := e.encodePrimitive(k)
+	return err
+}
+
 func (e *Encoder) encodeStruct(v map[string]any) error {
 	e.w.WriteRune(rStructOpen)
 	e.push()
@@ -275,7 +557,7 @@ func (e *Encoder) encodeStruct(v map[string]any) error {
 		e.w.WriteRune(rAssoc)
 		e.w.WriteByte(rSpace)
 		if err := e.encodeValue(v); err != nil {
-			return err
+			return prependStep(Field(i), err)
 		}
 		e.w.WriteRune(rSep)
 		return nil
@@ -301,22 +583,46 @@ func mapForEach(m map[any]any, f func(k, v any) error) error {
 	for key := range m {
 		keys = append(keys, key)
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		ti := typeIndex(keys[i])
-		tj := typeIndex(keys[j])
-		if ti == tj {
-			return typeCmp(keys[i], keys[j])
-		}
-		return ti < tj
-	})
+	sortKeys(keys)
 	for _, key := range keys {
-		if err := f(key, m[key]); err != nil {
+		// Look up with lookupKey, since indexing never finds a NaN key.
+		v, _ := lookupKey(m, key)
+		if err := f(key, v); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// Sorts map keys by type, then by value.
+func sortKeys(keys []any) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+}
+
+// Reports whether map key i sorts before map key j. Keys are ordered by the
+// type of their unannotated value, then by that value, then by annotation, with
+// an unannotated key first.
+func keyLess(i, j any) bool {
+	ui, uj := unannotate(i), unannotate(j)
+	ti, tj := typeIndex(ui), typeIndex(uj)
+	switch {
+	case ti != tj:
+		return ti < tj
+	case typeCmp(ui, uj):
+		return true
+	case typeCmp(uj, ui):
+		return false
+	}
+	ai, oki := annotationOf(i)
+	aj, okj := annotationOf(j)
+	if oki != okj {
+		return okj
+	}
+	return ai < aj
+}
+
 func typeIndex(v any) int {
 	switch v.(type) {
 	default:
@@ -3,10 +3,11 @@ package rod
 import (
 	"bytes"
 	"io"
+	"math"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
-
-	"github.com/anaminus/deep"
 )
 
 func TestEncoder(t *testing.T) {
@@ -39,10 +40,254 @@ func TestEncoder(t *testing.T) {
 		t.Fatalf("%s", err)
 	}
 
-	if diffs := deep.Equal(u, v); len(diffs) > 0 {
+	if diffs := Diff(u, v); len(diffs) > 0 {
 		for _, d := range diffs {
 			t.Log(d)
 		}
 		t.Errorf("encoded sample file not equal to control")
 	}
 }
+
+func TestEncodeFloat(t *testing.T) {
+	tests := map[float64]string{
+		0:                       "0.0",
+		-1:                      "-1.0",
+		3.14:                    "3.14",
+		0.000001:                "0.000001",
+		1.5e-7:                  "1.5e-7",
+		1e20:                    "100000000000000000000.0",
+		1e21:                    "1.0e21",
+		-1.7976931348623157e308: "-1.7976931348623157e308",
+		5e-324:                  "5.0e-324",
+		math.Inf(1):             "inf",
+		math.Inf(-1):            "-inf",
+	}
+	for v, want := range tests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(v); err != nil {
+			t.Fatalf("%v: %s", v, err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%v: expected %s, got %s", v, want, got)
+		}
+		var u any
+		if err := NewDecoder(&buf).Decode(&u); err != nil {
+			t.Fatalf("%s: %s", want, err)
+		}
+		if u != v {
+			t.Errorf("%s: decoded %v, expected %v", want, u, v)
+		}
+	}
+}
+
+func TestEncodeNaNKey(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(_map{math.NaN(): _int(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "(\n\tnan: 1,\n)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEncodeAnnotatedKey(t *testing.T) {
+	const src = "(\n\t2: \"b\",\n\t<int32> 1: \"a\",\n\t1: \"c\",\n)"
+	d := NewDecoder(strings.NewReader(src))
+	d.SetAnnotations(true)
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	want := "(\n\t1: \"c\",\n\t<int32> 1: \"a\",\n\t2: \"b\",\n)"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEncodeString(t *testing.T) {
+	tests := map[string]string{
+		"plain":           `"plain"`,
+		"new\nline":       "\"\"\"\n\tnew\n\tline\n\t\"\"\"",
+		"crlf\r\n":        `"crlf\r\n"`,
+		"cr\r":            `"cr\r"`,
+		"tab\t":           `"tab\t"`,
+		"nul\x00":         `"nul\0"`,
+		"esc\x1b[0m":      `"esc\x{1b}[0m"`,
+		"del\x7f":         `"del\x{7f}"`,
+		"nbsp\u00a0":      `"nbsp\u{00a0}"`,
+		"zwsp\u200b":      `"zwsp\u{200b}"`,
+		"emoji\U0001F600": "\"emoji\U0001F600\"",
+		`"quote\"`:        `"\"quote\\\""`,
+	}
+	for v, want := range tests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(v); err != nil {
+			t.Fatalf("%q: %s", v, err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%q: expected %s, got %s", v, want, got)
+		}
+		var u any
+		if err := NewDecoder(&buf).Decode(&u); err != nil {
+			t.Fatalf("%s: %s", want, err)
+		}
+		if u != v {
+			t.Errorf("%s: decoded %q, expected %q", want, u, v)
+		}
+	}
+
+	const invalid = "bad\xff"
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(invalid); err == nil {
+		t.Errorf("expected error for invalid UTF-8")
+	}
+	buf.Reset()
+	e := NewEncoder(&buf)
+	e.SetInvalidAsBlob(true)
+	if err := e.Encode(invalid); err != nil {
+		t.Fatalf("%s", err)
+	}
+	var u any
+	if err := NewDecoder(&buf).Decode(&u); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if b, ok := u.([]byte); !ok || string(b) != invalid {
+		t.Errorf("expected blob fallback, got %#v", u)
+	}
+}
+
+func TestEncodeBlockString(t *testing.T) {
+	v := map[string]any{
+		"Source": "void main() {\n\tgl_FragColor = vec4(1.0);\n}\n\n\"\"\"quoted\"\"\"\n",
+	}
+	want := `{
+	Source: """
+		void main() {
+			gl_FragColor = vec4(1.0);
+		}
+
+		""\"quoted""\"
+
+		""",
+}`
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	var u any
+	if err := NewDecoder(&buf).Decode(&u); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if diffs := Diff(u, v); len(diffs) > 0 {
+		for _, d := range diffs {
+			t.Log(d)
+		}
+		t.Errorf("decoded block string not equal to control")
+	}
+}
+
+func TestEncodeReferences(t *testing.T) {
+	cyclic := map[string]any{"A": int64(1)}
+	cyclic["Self"] = cyclic
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(cyclic); err == nil {
+		t.Errorf("expected error for cyclic value")
+	}
+
+	shared := map[any]any{int64(1): "one"}
+	v := map[string]any{
+		"Self": cyclic,
+		"List": []any{shared, shared, []any{}, []any{}},
+	}
+	want := `{
+	List: [
+		&1 (
+			1: "one",
+		),
+		*1,
+		[
+		],
+		[
+		],
+	],
+	Self: &2 {
+		A: 1,
+		Self: *2,
+	},
+}`
+	buf.Reset()
+	e := NewEncoder(&buf)
+	e.SetReferences(true)
+	if err := e.Encode(v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	var u any
+	if err := NewDecoder(&buf).Decode(&u); err != nil {
+		t.Fatalf("%s", err)
+	}
+	self := u.(map[string]any)["Self"].(map[string]any)
+	if reflect.ValueOf(self["Self"]).Pointer() != reflect.ValueOf(self).Pointer() {
+		t.Errorf("expected cycle to be reconstructed")
+	}
+
+	array := []any{nil}
+	array[0] = array
+	buf.Reset()
+	e = NewEncoder(&buf)
+	e.SetReferences(true)
+	if err := e.Encode(array); err == nil {
+		t.Errorf("expected error for array that contains itself")
+	}
+}
+
+func TestEncodeNoPartialOutput(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	v := []any{int64(1), "two", map[string]any{"3": int64(3)}}
+	if err := e.Encode(v); err == nil {
+		t.Fatalf("expected error")
+	}
+	if buf.Len() > 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+
+	// Encoder remains usable after an error.
+	if err := e.Encode([]any{int64(1)}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got, want := buf.String(), "[\n\t1,\n]"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEncodeAnnotations(t *testing.T) {
+	v := Annotated{"root", _array{Annotated{"int32", _int(1)}, _struct{"A": Annotated{"", _blob{}}}}}
+	want := "<root> [\n\t<int32> 1,\n\t{\n\t\tA: <> ||,\n\t},\n]"
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	for _, v := range []any{
+		Annotated{"a>b", nil},
+		Annotated{"a", Annotated{"b", nil}},
+	} {
+		if err := NewEncoder(&buf).Encode(v); err == nil {
+			t.Errorf("expected error encoding %#v", v)
+		}
+	}
+}
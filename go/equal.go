@@ -0,0 +1,328 @@
+package rod
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+)
+
+// Option configures how values are compared by Equal and Diff.
+type Option func(*options)
+
+type options struct {
+	absTolerance      float64
+	relTolerance      float64
+	ulpTolerance      uint64
+	ignoreAnnotations bool
+	unorderedArrays   bool
+}
+
+// AbsTolerance causes two finite floats to be equal if they differ by no more
+// than tol.
+func AbsTolerance(tol float64) Option {
+	return func(o *options) { o.absTolerance = tol }
+}
+
+// RelTolerance causes two finite floats to be equal if they differ by no more
+// than tol times the greater of their magnitudes.
+func RelTolerance(tol float64) Option {
+	return func(o *options) { o.relTolerance = tol }
+}
+
+// ULPTolerance causes two finite floats to be equal if there are no more than n
+// representable floats between them, counting the second float.
+func ULPTolerance(n uint64) Option {
+	return func(o *options) { o.ulpTolerance = n }
+}
+
+// IgnoreAnnotations causes annotations to be ignored, such that an annotated
+// value is equal to the value without the annotation.
+func IgnoreAnnotations() Option {
+	return func(o *options) { o.ignoreAnnotations = true }
+}
+
+// UnorderedArrays causes arrays to be compared as multisets, such that two
+// arrays are equal if their elements can be paired up such that each pair is
+// equal.
+func UnorderedArrays() Option {
+	return func(o *options) { o.unorderedArrays = true }
+}
+
+// Equal reports whether a and b are equal decoded values, according to the
+// equality defined by the specification:
+//
+//   - Values of different types are not equal. An int is never equal to a
+//     float.
+//   - Floats are compared by representation, so NaN is equal to NaN, and -0.0
+//     is not equal to 0.0. Tolerance options relax this for finite floats.
+//   - An empty blob is equal to a nil blob.
+//   - Arrays are equal if they have equal elements in the same order.
+//   - Maps and structs are equal if they have the same keys, with equal values.
+//     A NaN key is equal to a NaN key.
+//   - Annotated values are equal if they have the same annotation and equal
+//     values. An annotated value is not equal to an unannotated value.
+//
+// Composites that refer back to themselves are compared without recursing
+// forever.
+func Equal(a, b any, opts ...Option) bool {
+	return newComparer(opts).equal(a, b)
+}
+
+// Compares values, and accumulates differences.
+type comparer struct {
+	options
+	diffs []Difference
+	// Matchers by annotation, or nil if annotations are not interpreted as
+	// matchers.
+	matchers map[string]*matcher
+	// Pairs of composites currently being compared. A pair that is revisited
+	// is assumed to be equal, since any difference will be found by the
+	// comparison already in progress.
+	visiting map[[2]identity]bool
+}
+
+func newComparer(opts []Option) *comparer {
+	c := comparer{visiting: map[[2]identity]bool{}}
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	return &c
+}
+
+// Marks the pair of composites a and b as visited. Returns false if the pair is
+// already being visited. Otherwise, returns a function that unmarks the pair.
+func (c *comparer) visit(a, b any) (leave func(), ok bool) {
+	ida, oka := identityOf(a)
+	idb, okb := identityOf(b)
+	if !oka || !okb {
+		return func() {}, true
+	}
+	pair := [2]identity{ida, idb}
+	if c.visiting[pair] {
+		return nil, false
+	}
+	c.visiting[pair] = true
+	return func() { delete(c.visiting, pair) }, true
+}
+
+// Reports whether a and b have no differences.
+func (c *comparer) equal(a, b any) bool {
+	if m, x := c.matcherOf(a); m != nil {
+		return c.equalMatcher(m, x, unannotate(b))
+	}
+	if c.ignoreAnnotations || c.matchers != nil {
+		a, b = unannotate(a), unannotate(b)
+	}
+	if x, ok := a.(Annotated); ok {
+		y, ok := b.(Annotated)
+		return ok && x.Annotation == y.Annotation && c.equal(x.Value, y.Value)
+	} else if _, ok := b.(Annotated); ok {
+		return false
+	}
+	if typeName(a) != typeName(b) {
+		return false
+	}
+	leave, ok := c.visit(a, b)
+	if !ok {
+		return true
+	}
+	defer leave()
+	switch a := a.(type) {
+	case []any:
+		b := b.([]any)
+		if len(a) != len(b) {
+			return false
+		}
+		if c.unorderedArrays {
+			for _, j := range c.matchUnordered(a, b) {
+				if j < 0 {
+					return false
+				}
+			}
+			return true
+		}
+		for i := range a {
+			if !c.equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[any]any:
+		b := b.(map[any]any)
+		if len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := c.lookupKey(b, k)
+			if !ok || !c.equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b := b.(map[string]any)
+		if len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := b[k]
+			if !ok || !c.equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	default:
+		return c.equalPrimitive(a, b)
+	}
+}
+
+// Pairs each element of a with an equal element of b, such that as many
+// elements as possible are paired. Returns, for each element of a, the index of
+// its paired element in b, or -1 if it is unpaired.
+func (c *comparer) matchUnordered(a, b []any) []int {
+	eq := make([][]bool, len(a))
+	for i := range a {
+		eq[i] = make([]bool, len(b))
+		for j := range b {
+			eq[i][j] = c.equal(a[i], b[j])
+		}
+	}
+	// Find augmenting paths, so that an early greedy pairing does not prevent
+	// a later element from being paired.
+	pairOf := make([]int, len(b))
+	for j := range pairOf {
+		pairOf[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for j := range b {
+			if eq[i][j] && !seen[j] {
+				seen[j] = true
+				if pairOf[j] < 0 || augment(pairOf[j], seen) {
+					pairOf[j] = i
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for i := range a {
+		augment(i, make([]bool, len(b)))
+	}
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	for j, i := range pairOf {
+		if i >= 0 {
+			match[i] = j
+		}
+	}
+	return match
+}
+
+// Reports whether primitives a and b, of the same type, are equal.
+func (c *comparer) equalPrimitive(a, b any) bool {
+	switch a := a.(type) {
+	case float64:
+		return c.equalFloat(a, b.(float64))
+	case []byte:
+		return bytes.Equal(a, b.([]byte))
+	case nil, bool, int64, string:
+		return a == b
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func (c *comparer) equalFloat(a, b float64) bool {
+	if a != a || b != b {
+		return a != a && b != b
+	}
+	if math.Float64bits(a) == math.Float64bits(b) {
+		return true
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	d := math.Abs(a - b)
+	switch {
+	case c.absTolerance > 0 && d <= c.absTolerance:
+		return true
+	case c.relTolerance > 0 && d <= c.relTolerance*math.Max(math.Abs(a), math.Abs(b)):
+		return true
+	case c.ulpTolerance > 0 && ulpDistance(a, b) <= c.ulpTolerance:
+		return true
+	}
+	return false
+}
+
+// Returns the number of representable floats between a and b, counting b.
+func ulpDistance(a, b float64) uint64 {
+	x, y := orderedBits(a), orderedBits(b)
+	if x > y {
+		return x - y
+	}
+	return y - x
+}
+
+// Maps the bits of f to an integer that increases with f.
+func orderedBits(f float64) uint64 {
+	u := math.Float64bits(f)
+	if u&(1<<63) != 0 {
+		return ^u
+	}
+	return u | 1<<63
+}
+
+// Returns the value of m associated with a key equal to k. If annotations are
+// ignored, keys are compared without their annotations.
+func (c *comparer) lookupKey(m map[any]any, k any) (v any, ok bool) {
+	if !c.ignoreAnnotations && c.matchers == nil {
+		return lookupKey(m, k)
+	}
+	_, v, ok = lookupUnannotated(m, unannotate(k))
+	return v, ok
+}
+
+// Returns the key of m that is equal to k without its annotation, along with
+// the associated value.
+func lookupUnannotated(m map[any]any, k any) (key, v any, ok bool) {
+	if v, ok := lookupKey(m, k); ok {
+		return k, v, true
+	}
+	for mk, v := range m {
+		if a, isAnnotated := mk.(Annotated); isAnnotated && sameKey(a.Value, k) {
+			return mk, v, true
+		}
+	}
+	return nil, nil, false
+}
+
+// Reports whether unannotated keys a and b are equal. A NaN key is equal to a
+// NaN key.
+func sameKey(a, b any) bool {
+	if fa, ok := a.(float64); ok && fa != fa {
+		fb, ok := b.(float64)
+		return ok && fb != fb
+	}
+	return a == b
+}
+
+// Returns the value of m associated with a key equal to k. Unlike indexing m
+// directly, a NaN key is found, even if annotated.
+func lookupKey(m map[any]any, k any) (v any, ok bool) {
+	if f, isFloat := unannotate(k).(float64); !isFloat || f == f {
+		v, ok = m[k]
+		return v, ok
+	}
+	a, annotated := annotationOf(k)
+	for mk, v := range m {
+		if f, isFloat := unannotate(mk).(float64); isFloat && f != f {
+			if b, ok := annotationOf(mk); ok == annotated && b == a {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package rod
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	nan := math.NaN()
+	negZero := math.Copysign(0, -1)
+	tests := []struct {
+		name  string
+		a, b  any
+		opts  []Option
+		equal bool
+	}{
+		{"nan", _float(nan), _float(nan), nil, true},
+		{"nan key", _map{_float(nan): _int(1)}, _map{_float(nan): _int(1)}, nil, true},
+		{"negative zero", _float(0), _float(negZero), nil, false},
+		{"nil blob", _blob(nil), _blob{}, nil, true},
+		{"int float", _int(1), _float(1), nil, false},
+		{"nested", _struct{"A": _array{_map{"k": _blob{1}}}}, _struct{"A": _array{_map{"k": _blob{1}}}}, nil, true},
+		{"missing field", _struct{"A": nil}, _struct{"B": nil}, nil, false},
+		{"abs", _float(1), _float(1.001), []Option{AbsTolerance(0.01)}, true},
+		{"abs exceeded", _float(1), _float(1.1), []Option{AbsTolerance(0.01)}, false},
+		{"abs zero", _float(0), _float(negZero), []Option{AbsTolerance(0.01)}, true},
+		{"rel", _float(1000), _float(1001), []Option{RelTolerance(0.001)}, true},
+		{"rel exceeded", _float(1), _float(1.01), []Option{RelTolerance(0.001)}, false},
+		{"ulp", _float(1), _float(math.Nextafter(math.Nextafter(1, 2), 2)), []Option{ULPTolerance(2)}, true},
+		{"ulp exceeded", _float(1), _float(math.Nextafter(math.Nextafter(1, 2), 2)), []Option{ULPTolerance(1)}, false},
+		{"ulp zero", _float(0), _float(negZero), []Option{ULPTolerance(1)}, true},
+		{"inf", _float(math.Inf(1)), _float(math.MaxFloat64), []Option{RelTolerance(1), ULPTolerance(1)}, false},
+		{"annotation", Annotated{"a", _int(1)}, Annotated{"a", _int(1)}, nil, true},
+		{"annotation differs", Annotated{"a", _int(1)}, Annotated{"b", _int(1)}, nil, false},
+		{"annotation missing", Annotated{"a", _int(1)}, _int(1), nil, false},
+		{"ignore annotations", _array{Annotated{"a", _int(1)}}, _array{_int(1)}, []Option{IgnoreAnnotations()}, true},
+		{"annotated key", _map{Annotated{"a", _int(1)}: nil}, _map{_int(1): nil}, nil, false},
+		{"ignore key annotations", _map{Annotated{"a", _int(1)}: nil}, _map{_int(1): nil}, []Option{IgnoreAnnotations()}, true},
+		{"annotated nan key", _map{Annotated{"a", _float(nan)}: nil}, _map{Annotated{"a", _float(nan)}: nil}, nil, true},
+		{"ignore nan key annotations", _map{_float(nan): nil}, _map{Annotated{"a", _float(nan)}: nil}, []Option{IgnoreAnnotations()}, true},
+		{"ordered", _array{_int(1), _int(2)}, _array{_int(2), _int(1)}, nil, false},
+		{"unordered", _array{_int(1), _int(2), _int(2)}, _array{_int(2), _int(1), _int(2)}, []Option{UnorderedArrays()}, true},
+		{"unordered counts", _array{_int(1), _int(1), _int(2)}, _array{_int(1), _int(2), _int(2)}, []Option{UnorderedArrays()}, false},
+		{
+			// A greedy pairing of 1.0 with 1.5 would leave 2.0 unpaired.
+			"unordered matching",
+			_array{_float(1), _float(2)},
+			_array{_float(1.5), _float(0.5)},
+			[]Option{UnorderedArrays(), AbsTolerance(0.5)},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equal(tt.a, tt.b, tt.opts...); got != tt.equal {
+				t.Errorf("expected %v, got %v", tt.equal, got)
+			}
+			if got := len(Diff(tt.a, tt.b, tt.opts...)) == 0; got != tt.equal {
+				t.Errorf("Diff: expected equal %v, got %v", tt.equal, got)
+			}
+		})
+	}
+}
+
+func TestEqualCycle(t *testing.T) {
+	a := _map{"n": _int(1)}
+	a["self"] = a
+	b := _map{"n": _int(1)}
+	b["self"] = b
+	if !Equal(a, b) {
+		t.Errorf("expected cyclic maps to be equal")
+	}
+	b["n"] = _int(2)
+	if Equal(a, b) {
+		t.Errorf("expected cyclic maps to differ")
+	}
+}
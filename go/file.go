@@ -0,0 +1,103 @@
+package rod
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileWriter writes to a temporary file that atomically replaces a named file
+// when committed. Until then, the named file is left untouched.
+type FileWriter struct {
+	name string
+	perm fs.FileMode
+	f    *os.File
+	done bool
+}
+
+// CreateFile returns a FileWriter that will replace the named file. The
+// temporary file is created in the same directory as the named file, so that it
+// can be renamed into place. If the named file exists, its permissions are
+// preserved.
+func CreateFile(name string) (*FileWriter, error) {
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &FileWriter{name: name, perm: perm, f: f}, nil
+}
+
+// Write writes p to the temporary file.
+func (w *FileWriter) Write(p []byte) (n int, err error) {
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	return w.f.Write(p)
+}
+
+// Commit flushes the temporary file to storage, and renames it to the named
+// file. If an error occurs, the temporary file is removed, and the named file
+// is left untouched.
+func (w *FileWriter) Commit() error {
+	if w.done {
+		return os.ErrClosed
+	}
+	w.done = true
+	err := w.f.Sync()
+	if err == nil {
+		err = w.f.Chmod(w.perm)
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.name)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
+
+// Close discards the temporary file if it has not been committed. Close may be
+// called after Commit.
+func (w *FileWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	err := w.f.Close()
+	if rerr := os.Remove(w.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// WriteFile encodes v and atomically writes it to the named file. Either the
+// file receives the complete document, or it is left untouched.
+func WriteFile(name string, v any) error {
+	return EncodeFile(name, NewEncoder, v)
+}
+
+// EncodeFile is like WriteFile, but encodes v with an Encoder returned by
+// newEncoder, which allows the encoder to be configured.
+func EncodeFile(name string, newEncoder func(w io.Writer) *Encoder, v any) error {
+	w, err := CreateFile(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := newEncoder(w).Encode(v); err != nil {
+		return err
+	}
+	return w.Commit()
+}
@@ -0,0 +1,47 @@
+package rod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "golden.rod")
+
+	if err := WriteFile(name, map[string]any{"A": int64(1)}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	want, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(want) != "{\n\tA: 1,\n}" {
+		t.Errorf("unexpected content %q", want)
+	}
+
+	// Invalid identifier is detected after the first field is encoded.
+	invalid := map[string]any{"A": int64(2), "not valid": int64(3)}
+	if err := WriteFile(name, invalid); err == nil {
+		t.Fatalf("expected error")
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("file was modified by failed write: %q", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(entries) != 1 {
+		for _, entry := range entries {
+			t.Log(entry.Name())
+		}
+		t.Errorf("expected temporary file to be removed")
+	}
+}
@@ -0,0 +1,504 @@
+package rod
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format returns the canonical formatting of the ROD document in src.
+//
+// The result is byte-identical to what an Encoder produces for the decoded
+// value, except that comments and annotations are preserved. Map entries and
+// struct fields are sorted, and comments move along with the entry they are
+// attached to. A comment is attached to the entry that follows it, or to the
+// entry that precedes it if the comment is an inline comment on the same line.
+// Comments within a blob are discarded, because the encoder produces its own.
+// Anchors are renumbered in the order they are written, and an anchor that is
+// never referred to is removed.
+func Format(src []byte) ([]byte, error) {
+	p := formatParser{l: newLexer(bytes.NewReader(src))}
+	root, err := p.parseRoot()
+	if err != nil {
+		return nil, err
+	}
+	if err := root.value.sort(); err != nil {
+		return nil, err
+	}
+	renumberAnchors(root.value)
+
+	var e Encoder
+	for _, c := range root.leading {
+		e.w.WriteString(c)
+		e.w.WriteByte('\n')
+	}
+	e.formatNode(root.value)
+	for _, c := range root.trailing {
+		e.w.WriteByte('\n')
+		e.w.WriteString(c)
+	}
+	return e.w.Bytes(), nil
+}
+
+// A value within a document, retaining comments and annotations.
+type formatNode struct {
+	annotation string // Includes delimiters.
+	anchor     string
+	reference  string
+	kind       tokenType // Opening token of a composite, or tInvalid.
+	value      any       // Value of a primitive.
+	entries    []*formatEntry
+	trailing   []string // Comments preceding the closing delimiter.
+}
+
+// An element, entry, or field of a composite, or the root of a document.
+type formatEntry struct {
+	leading  []string // Comments on lines preceding the entry.
+	key      *formatNode
+	ident    string
+	value    *formatNode
+	comment  string   // Inline comment on the same line as the entry.
+	trailing []string // Comments following the root.
+}
+
+// A comment, and whether it appears on the same line as the preceding token.
+type formatComment struct {
+	text     string
+	sameLine bool
+}
+
+// Builds a formatNode tree from lexer tokens.
+type formatParser struct {
+	l        *lexer
+	peeked   token
+	hasPeek  bool
+	newline  bool
+	comments []formatComment
+}
+
+// Returns the next token that is not whitespace or a comment, without
+// consuming it. Comments are collected along the way.
+func (p *formatParser) peek() (token, error) {
+	if p.hasPeek {
+		return p.peeked, nil
+	}
+	for p.l.Next() {
+		t := p.l.Token()
+		switch t.Type {
+		case tError:
+			return t, tokenError(t)
+		case tSpace:
+			if strings.ContainsRune(t.Value, '\n') {
+				p.newline = true
+			}
+			continue
+		case tInlineComment:
+			p.comments = append(p.comments, formatComment{
+				text:     strings.TrimRight(t.Value, "\r\n"),
+				sameLine: !p.newline,
+			})
+			p.newline = true
+			continue
+		case tBlockComment:
+			p.comments = append(p.comments, formatComment{
+				text:     t.Value,
+				sameLine: !p.newline,
+			})
+			continue
+		}
+		p.peeked = t
+		p.hasPeek = true
+		return t, nil
+	}
+	return token{Type: tEOF}, nil
+}
+
+// Returns and consumes the next significant token.
+func (p *formatParser) next() (token, error) {
+	t, err := p.peek()
+	p.hasPeek = false
+	p.newline = false
+	return t, err
+}
+
+// Returns and clears the collected comments.
+func (p *formatParser) take() []string {
+	var c []string
+	for _, comment := range p.comments {
+		c = append(c, comment.text)
+	}
+	p.comments = p.comments[:0]
+	return c
+}
+
+// If the first collected comment is an inline comment on the same line as the
+// preceding token, then it is removed and returned.
+func (p *formatParser) takeSameLine() string {
+	if len(p.comments) == 0 || !p.comments[0].sameLine || strings.HasPrefix(p.comments[0].text, rBlockComment) {
+		return ""
+	}
+	c := p.comments[0].text
+	p.comments = p.comments[1:]
+	return c
+}
+
+func (p *formatParser) unexpected(t token) error {
+	return tokenError(token{
+		Type:     tError,
+		Position: t.Position,
+		Err:      fmt.Errorf("unexpected %s", t.Type),
+	})
+}
+
+func (p *formatParser) parseRoot() (*formatEntry, error) {
+	root := &formatEntry{}
+	if _, err := p.peek(); err != nil {
+		return nil, err
+	}
+	root.leading = p.take()
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	root.value = v
+	root.leading = append(root.leading, p.take()...)
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type != tEOF {
+		return nil, p.unexpected(t)
+	}
+	root.trailing = p.take()
+	return root, nil
+}
+
+// Parses an optional annotation followed by a value.
+func (p *formatParser) parseValue() (*formatNode, error) {
+	n := &formatNode{}
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == tAnnotation {
+		n.annotation = t.Value
+		if t, err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if t.Type == tAnchor {
+		n.anchor = t.Value[1:]
+		if t, err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+	var d Decoder
+	switch t.Type {
+	default:
+		return nil, p.unexpected(t)
+	case tReference:
+		n.reference = t.Value[1:]
+	case tNull:
+		n.value = nil
+	case tTrue:
+		n.value = true
+	case tFalse:
+		n.value = false
+	case tInf, tNaN, tInteger, tFloat:
+		err = p.parseNumber(&d, n, 1, t)
+	case tPos, tNeg:
+		sign := 1
+		if t.Type == tNeg {
+			sign = -1
+		}
+		if t, err = p.next(); err != nil {
+			return nil, err
+		}
+		err = p.parseNumber(&d, n, sign, t)
+	case tString:
+		err = d.decodeString(&n.value, t.Value)
+	case tBlob:
+		err = p.parseBlob(n)
+	case tArrayOpen, tMapOpen, tStructOpen:
+		n.kind = t.Type
+		err = p.parseComposite(n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *formatParser) parseNumber(d *Decoder, n *formatNode, sign int, t token) error {
+	switch t.Type {
+	default:
+		return p.unexpected(t)
+	case tInf:
+		return d.decodeFloat(&n.value, sign, rInf)
+	case tNaN:
+		return d.decodeFloat(&n.value, sign, rNaN)
+	case tInteger:
+		return d.decodeInteger(&n.value, sign, t.Value)
+	case tFloat:
+		return d.decodeFloat(&n.value, sign, t.Value)
+	}
+}
+
+func (p *formatParser) parseBlob(n *formatNode) error {
+	var b []byte
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch t.Type {
+		default:
+			return p.unexpected(t)
+		case tByte:
+			v, _ := strconv.ParseUint(t.Value, 16, 8)
+			b = append(b, byte(v))
+		case tBlob:
+			// Discard comments within blob.
+			p.take()
+			if b == nil {
+				b = []byte{}
+			}
+			n.value = b
+			return nil
+		}
+	}
+}
+
+func (p *formatParser) parseComposite(n *formatNode) error {
+	var close tokenType
+	switch n.kind {
+	case tArrayOpen:
+		close = tArrayClose
+	case tMapOpen:
+		close = tMapClose
+	case tStructOpen:
+		close = tStructClose
+	}
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return err
+		}
+		if t.Type == close {
+			p.next()
+			n.trailing = p.take()
+			return nil
+		}
+
+		entry := &formatEntry{leading: p.take()}
+		switch n.kind {
+		case tMapOpen:
+			if entry.key, err = p.parseValue(); err != nil {
+				return err
+			}
+		case tStructOpen:
+			if t, err = p.next(); err != nil {
+				return err
+			}
+			entry.ident = t.Value
+		}
+		if n.kind != tArrayOpen {
+			if t, err = p.next(); err != nil {
+				return err
+			}
+			if t.Type != tAssoc {
+				return p.unexpected(t)
+			}
+			// Hoist comments around the association.
+			entry.leading = append(entry.leading, p.take()...)
+		}
+		if entry.value, err = p.parseValue(); err != nil {
+			return err
+		}
+		// Hoist comments between the annotation and the value.
+		entry.leading = append(entry.leading, p.take()...)
+		n.entries = append(n.entries, entry)
+
+		if t, err = p.peek(); err != nil {
+			return err
+		}
+		entry.comment = p.takeSameLine()
+		if t.Type == tSep {
+			p.next()
+			if _, err = p.peek(); err != nil {
+				return err
+			}
+			if entry.comment == "" {
+				entry.comment = p.takeSameLine()
+			}
+		}
+	}
+}
+
+// Sorts the entries of maps and structs within n, according to the order used
+// by the encoder.
+func (n *formatNode) sort() error {
+	switch n.kind {
+	case tMapOpen:
+		for _, entry := range n.entries {
+			if typeIndex(entry.key.value) == 0 {
+				return fmt.Errorf("cannot sort map key of type %T", entry.key.value)
+			}
+		}
+		sort.SliceStable(n.entries, func(i, j int) bool {
+			ki := n.entries[i].key.value
+			kj := n.entries[j].key.value
+			ti := typeIndex(ki)
+			tj := typeIndex(kj)
+			if ti == tj {
+				return typeCmp(ki, kj)
+			}
+			return ti < tj
+		})
+		for i := 1; i < len(n.entries); i++ {
+			ki := n.entries[i-1].key.value
+			kj := n.entries[i].key.value
+			if typeIndex(ki) == typeIndex(kj) && !typeCmp(ki, kj) && !typeCmp(kj, ki) {
+				return fmt.Errorf("map contains duplicate key %v", kj)
+			}
+		}
+	case tStructOpen:
+		sort.SliceStable(n.entries, func(i, j int) bool {
+			return n.entries[i].ident < n.entries[j].ident
+		})
+		for i := 1; i < len(n.entries); i++ {
+			if n.entries[i-1].ident == n.entries[i].ident {
+				return fmt.Errorf("struct contains duplicate field %s", n.entries[i].ident)
+			}
+		}
+	}
+	for _, entry := range n.entries {
+		if err := entry.value.sort(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renumbers anchors in the order that they are written, in the same manner as
+// the encoder. If a reference would be written before its anchor, then the
+// composite is moved to the location of the reference. Anchors that are never
+// referred to are removed.
+func renumberAnchors(root *formatNode) {
+	anchors := map[string]*formatNode{}
+	referred := map[string]bool{}
+	var find func(n *formatNode)
+	find = func(n *formatNode) {
+		if n.anchor != "" {
+			anchors[n.anchor] = n
+		}
+		if n.reference != "" {
+			referred[n.reference] = true
+		}
+		for _, entry := range n.entries {
+			find(entry.value)
+		}
+	}
+	find(root)
+
+	labels := map[string]string{}
+	var walk func(n *formatNode)
+	walk = func(n *formatNode) {
+		if old := n.reference; old != "" {
+			if label, ok := labels[old]; ok {
+				n.reference = label
+				return
+			}
+			// Reference precedes its anchor; swap the composite into place.
+			a := anchors[old]
+			if a == nil {
+				return
+			}
+			n.kind, a.kind = a.kind, tInvalid
+			n.entries, a.entries = a.entries, nil
+			n.trailing, a.trailing = a.trailing, nil
+			n.anchor, n.reference = old, ""
+			a.anchor, a.reference = "", old
+			anchors[old] = n
+		}
+		if old := n.anchor; old != "" {
+			if referred[old] {
+				labels[old] = strconv.Itoa(len(labels) + 1)
+				n.anchor = labels[old]
+			} else {
+				n.anchor = ""
+			}
+		}
+		for _, entry := range n.entries {
+			walk(entry.value)
+		}
+	}
+	walk(root)
+}
+
+// Writes a node in the same layout as encodeValue.
+func (e *Encoder) formatNode(n *formatNode) {
+	if n.annotation != "" {
+		e.w.WriteString(n.annotation)
+		e.w.WriteByte(rSpace)
+	}
+	if n.reference != "" {
+		e.w.WriteRune(rReference)
+		e.w.WriteString(n.reference)
+		return
+	}
+	if n.anchor != "" {
+		e.w.WriteRune(rAnchor)
+		e.w.WriteString(n.anchor)
+		e.w.WriteByte(rSpace)
+	}
+	var open, close rune
+	switch n.kind {
+	case tArrayOpen:
+		open, close = rArrayOpen, rArrayClose
+	case tMapOpen:
+		open, close = rMapOpen, rMapClose
+	case tStructOpen:
+		open, close = rStructOpen, rStructClose
+	default:
+		// Strings are known to be valid UTF-8 because they were decoded.
+		e.encodePrimitive(n.value)
+		return
+	}
+	e.w.WriteRune(open)
+	e.push()
+	for _, entry := range n.entries {
+		e.formatComments(entry.leading)
+		e.newline()
+		switch n.kind {
+		case tMapOpen:
+			e.formatNode(entry.key)
+			e.w.WriteRune(rAssoc)
+			e.w.WriteByte(rSpace)
+		case tStructOpen:
+			e.w.WriteString(entry.ident)
+			e.w.WriteRune(rAssoc)
+			e.w.WriteByte(rSpace)
+		}
+		e.formatNode(entry.value)
+		e.w.WriteRune(rSep)
+		if entry.comment != "" {
+			e.w.WriteByte(rSpace)
+			e.w.WriteString(entry.comment)
+		}
+	}
+	e.formatComments(n.trailing)
+	e.pop()
+	e.newline()
+	e.w.WriteRune(close)
+}
+
+// Writes each comment on its own line.
+func (e *Encoder) formatComments(comments []string) {
+	for _, c := range comments {
+		e.newline()
+		e.w.WriteString(c)
+	}
+}
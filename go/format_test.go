@@ -0,0 +1,97 @@
+package rod
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestFormatEncoded(t *testing.T) {
+	b, err := os.ReadFile("testdata/sample.rod")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	var v any
+	if err := NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	encoded := buf.Bytes()
+
+	formatted, err := Format(encoded)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !bytes.Equal(formatted, encoded) {
+		t.Errorf("formatted encoder output differs from encoder output:\n%s", formatted)
+	}
+}
+
+var testFormat = map[string]string{
+	`+42`:     `42`,
+	`-0`:      `0`,
+	`-0.0`:    `-0.0`,
+	`1.50E+3`: `1500.0`,
+	`|AB cd|`: "|\n\tab cd                                            #..#\n|",
+	`( "b": 1, true: 2, -3: 3, null: 4, 1.5: 5, |00|: 6 )`: `(
+	null: 4,
+	true: 2,
+	-3: 3,
+	1.5: 5,
+	"b": 1,
+	|
+		00                                               #.#
+	|: 6,
+)`,
+	`{B: 1, A: 2}`: `{
+	A: 2,
+	B: 1,
+}`,
+	"# head\n<anno> {B: 2, # b\n\t# before a\n\tA: <x> 1, C: []\n\t# end\n} # tail\n": `# head
+<anno> {
+	# before a
+	A: <x> 1,
+	B: 2, # b
+	C: [
+	],
+	# end
+}
+# tail`,
+	"[1, #<block> 2, 3 # three\n]": `[
+	1,
+	#<block>
+	2,
+	3, # three
+]`,
+	"[*1, &1 {A: *1}, &2 []]": `[
+	&1 {
+		A: *1,
+	},
+	*1,
+	[
+	],
+]`,
+}
+
+func TestFormat(t *testing.T) {
+	for _, src := range keysOf(testFormat) {
+		want := testFormat[src]
+		got, err := Format([]byte(src))
+		if err != nil {
+			t.Errorf("%#q: %s", src, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%#q: expected:\n%s\ngot:\n%s", src, want, got)
+		}
+	}
+
+	for _, src := range []string{`{A: 1, A: 2}`, `(1: 1, 1: 2)`, `[`} {
+		if _, err := Format([]byte(src)); err == nil {
+			t.Errorf("%#q: expected error", src)
+		}
+	}
+}
@@ -1,5 +1,3 @@
 module github.com/anaminus/rod/go
 
 go 1.20
-
-require github.com/anaminus/deep v0.0.0-20190609161759-a37cba07138a
@@ -0,0 +1,434 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/types"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// GoSyntaxOption configures GoSyntax.
+type GoSyntaxOption func(*goSyntax)
+
+// AsType causes the value to be rendered as a value of the Go type t, rather
+// than as a decoded value. Named types are qualified by q, as by
+// types.TypeString.
+func AsType(t types.Type, q types.Qualifier) GoSyntaxOption {
+	return func(g *goSyntax) {
+		g.typ = t
+		g.qualifier = q
+	}
+}
+
+// GoSyntax returns Go source for a composite literal or other expression that
+// evaluates to the decoded value v, formatted as by gofmt. A float that is
+// infinite, NaN, or negative zero is written with the math package, and an
+// annotated value is written as a rod.Annotated.
+//
+// With AsType, a value is rendered as a value of the given type: structs are
+// rendered from the fields named by their rod tags, and ints and floats are
+// checked to fit their types. Annotations are discarded, except within values
+// of interface types, which are rendered as decoded values.
+//
+// Blobs are rendered with each byte as a hexadecimal literal. Composites that
+// appear more than once are duplicated, and a cyclic value results in an
+// error.
+func GoSyntax(v any, opts ...GoSyntaxOption) (string, error) {
+	g := goSyntax{visiting: map[identity]bool{}}
+	for _, opt := range opts {
+		opt(&g)
+	}
+	var err error
+	if g.typ != nil {
+		err = g.typed(nil, v, g.typ, true, false)
+	} else {
+		err = g.dynamic(nil, v)
+	}
+	if err != nil {
+		return "", err
+	}
+	src, err := format.Source([]byte(g.b.String()))
+	if err != nil {
+		return "", fmt.Errorf("format: %w", err)
+	}
+	return string(src), nil
+}
+
+type goSyntax struct {
+	b         strings.Builder
+	typ       types.Type
+	qualifier types.Qualifier
+	// Composites currently being rendered.
+	visiting map[identity]bool
+}
+
+// Marks the composite v as being rendered, returning a function that unmarks
+// it. Returns an error if v is already being rendered.
+func (g *goSyntax) visit(path Path, v any) (func(), error) {
+	id, ok := identityOf(v)
+	if !ok {
+		return func() {}, nil
+	}
+	if g.visiting[id] {
+		return nil, &PathError{Path: path, Err: errors.New("cannot render cyclic value")}
+	}
+	g.visiting[id] = true
+	return func() { delete(g.visiting, id) }, nil
+}
+
+// Renders v as a decoded value.
+func (g *goSyntax) dynamic(path Path, v any) error {
+	done, err := g.visit(path, v)
+	if err != nil {
+		return err
+	}
+	defer done()
+	switch v := v.(type) {
+	case nil:
+		g.b.WriteString("nil")
+	case bool:
+		g.b.WriteString(strconv.FormatBool(v))
+	case int64:
+		g.b.WriteString("int64(" + strconv.FormatInt(v, 10) + ")")
+	case float64:
+		g.b.WriteString(goFloat(v, "float64"))
+	case string:
+		g.b.WriteString(strconv.Quote(v))
+	case []byte:
+		g.blob("[]byte", v)
+	case Annotated:
+		fmt.Fprintf(&g.b, "rod.Annotated{Annotation: %s, Value: ", strconv.Quote(v.Annotation))
+		if err := g.dynamic(path, v.Value); err != nil {
+			return err
+		}
+		g.b.WriteString("}")
+	case []any:
+		g.b.WriteString("[]any{")
+		for i, e := range v {
+			g.b.WriteString("\n")
+			if err := g.dynamic(path.with(Index(i)), e); err != nil {
+				return err
+			}
+			g.b.WriteString(",")
+		}
+		g.close(len(v))
+	case map[any]any:
+		g.b.WriteString("map[any]any{")
+		err := mapForEach(v, func(k, e any) error {
+			g.b.WriteString("\n")
+			if err := g.dynamic(path, k); err != nil {
+				return err
+			}
+			g.b.WriteString(": ")
+			if err := g.dynamic(path.with(Key{k}), e); err != nil {
+				return err
+			}
+			g.b.WriteString(",")
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		g.close(len(v))
+	case map[string]any:
+		g.b.WriteString("map[string]any{")
+		err := structForEach(v, func(k string, e any) error {
+			fmt.Fprintf(&g.b, "\n%s: ", strconv.Quote(k))
+			if err := g.dynamic(path.with(Field(k)), e); err != nil {
+				return err
+			}
+			g.b.WriteString(",")
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		g.close(len(v))
+	default:
+		return &PathError{Path: path, Err: fmt.Errorf("cannot render type %T", v)}
+	}
+	return nil
+}
+
+// Closes a composite literal with n elements.
+func (g *goSyntax) close(n int) {
+	if n > 0 {
+		g.b.WriteString("\n")
+	}
+	g.b.WriteString("}")
+}
+
+// Renders a blob as a composite literal of the given type.
+func (g *goSyntax) blob(typ string, v []byte) {
+	const width = 16 // Bytes per line.
+	g.b.WriteString(typ + "{")
+	for i, c := range v {
+		if i%width == 0 {
+			g.b.WriteString("\n")
+		} else {
+			g.b.WriteString(" ")
+		}
+		fmt.Fprintf(&g.b, "0x%02x,", c)
+	}
+	g.close(len(v))
+}
+
+// Returns an expression for the float v. A non-constant expression is
+// converted to the type named typ unless it is float64.
+func goFloat(v float64, typ string) string {
+	var s string
+	switch {
+	case math.IsInf(v, 1):
+		s = "math.Inf(1)"
+	case math.IsInf(v, -1):
+		s = "math.Inf(-1)"
+	case v != v:
+		s = "math.NaN()"
+	case v == 0 && math.Signbit(v):
+		s = "math.Copysign(0, -1)"
+	default:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	if typ != "float64" {
+		s = typ + "(" + s + ")"
+	}
+	return s
+}
+
+// Renders v as a value of type t. If conv is true, then a constant is
+// converted to t unless t is its default type. If elide is true, then the type
+// of a composite literal is omitted, as permitted for elements of a composite
+// literal.
+func (g *goSyntax) typed(path Path, v any, t types.Type, conv, elide bool) error {
+	name := types.TypeString(t, g.qualifier)
+	mismatch := func() error {
+		return &PathError{Path: path, Err: fmt.Errorf("cannot render %s as %s", typeName(v), name)}
+	}
+	if u, ok := t.Underlying().(*types.Interface); ok {
+		if !u.Empty() {
+			return mismatch()
+		}
+		return g.dynamic(path, v)
+	}
+	v = unannotate(v)
+	if u, ok := t.Underlying().(*types.Pointer); ok {
+		return g.typedPointer(path, v, u, elide)
+	}
+	done, err := g.visit(path, v)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		var s, def string
+		info := u.Info()
+		switch v := v.(type) {
+		case bool:
+			if info&types.IsBoolean == 0 {
+				return mismatch()
+			}
+			s, def = strconv.FormatBool(v), "bool"
+		case string:
+			if info&types.IsString == 0 {
+				return mismatch()
+			}
+			s, def = strconv.Quote(v), "string"
+		case int64:
+			switch {
+			case info&types.IsInteger != 0:
+				if !intFits(v, u.Kind()) {
+					return &PathError{Path: path, Err: fmt.Errorf("%d overflows %s", v, name)}
+				}
+				s, def = strconv.FormatInt(v, 10), "int"
+			case info&types.IsFloat != 0:
+				if !floatFits(float64(v), u.Kind()) {
+					return &PathError{Path: path, Err: fmt.Errorf("%d overflows %s", v, name)}
+				}
+				s, def = goFloat(float64(v), name), "float64"
+			default:
+				return mismatch()
+			}
+		case float64:
+			if info&types.IsFloat == 0 {
+				return mismatch()
+			}
+			if !floatFits(v, u.Kind()) {
+				return &PathError{Path: path, Err: fmt.Errorf("%s overflows %s", strconv.FormatFloat(v, 'g', -1, 64), name)}
+			}
+			s, def = goFloat(v, name), "float64"
+		default:
+			return mismatch()
+		}
+		// A float that is not a constant is already converted.
+		constant := !strings.HasSuffix(s, ")")
+		if conv && constant && name != def {
+			s = name + "(" + s + ")"
+		}
+		g.b.WriteString(s)
+	case *types.Slice:
+		if elide {
+			name = ""
+		}
+		switch v := v.(type) {
+		case nil:
+			g.b.WriteString("nil")
+		case []byte:
+			if e, ok := u.Elem().Underlying().(*types.Basic); !ok || e.Kind() != types.Byte {
+				return mismatch()
+			}
+			g.blob(name, v)
+		case []any:
+			g.b.WriteString(name + "{")
+			for i, e := range v {
+				g.b.WriteString("\n")
+				if err := g.typed(path.with(Index(i)), e, u.Elem(), false, true); err != nil {
+					return err
+				}
+				g.b.WriteString(",")
+			}
+			g.close(len(v))
+		default:
+			return mismatch()
+		}
+	case *types.Map:
+		if elide {
+			name = ""
+		}
+		switch v := v.(type) {
+		case nil:
+			g.b.WriteString("nil")
+		case map[any]any:
+			g.b.WriteString(name + "{")
+			err := mapForEach(v, func(k, e any) error {
+				g.b.WriteString("\n")
+				if err := g.typed(path, k, u.Key(), false, true); err != nil {
+					return err
+				}
+				g.b.WriteString(": ")
+				if err := g.typed(path.with(Key{k}), e, u.Elem(), false, true); err != nil {
+					return err
+				}
+				g.b.WriteString(",")
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			g.close(len(v))
+		default:
+			return mismatch()
+		}
+	case *types.Struct:
+		s, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		// The encoded name of each field, or an empty string if the field is
+		// not encoded.
+		names := make([]string, u.NumFields())
+		fields := map[string]bool{}
+		for i := range names {
+			f := u.Field(i)
+			tag := reflect.StructTag(u.Tag(i)).Get("rod")
+			if !f.Exported() || tag == "-" {
+				continue
+			}
+			names[i], _, _ = strings.Cut(tag, ",")
+			if names[i] == "" {
+				names[i] = f.Name()
+			}
+			fields[names[i]] = true
+		}
+		err := structForEach(s, func(k string, _ any) error {
+			if !fields[k] {
+				return &PathError{Path: path, Err: fmt.Errorf("%s has no field for %s", name, k)}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if elide {
+			name = ""
+		}
+		g.b.WriteString(name + "{")
+		n := 0
+		for i, k := range names {
+			e, ok := s[k]
+			if k == "" || !ok {
+				continue
+			}
+			fmt.Fprintf(&g.b, "\n%s: ", u.Field(i).Name())
+			if err := g.typed(path.with(Field(k)), e, u.Field(i).Type(), false, false); err != nil {
+				return err
+			}
+			g.b.WriteString(",")
+			n++
+		}
+		g.close(n)
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// Renders v as a value of the pointer type t.
+func (g *goSyntax) typedPointer(path Path, v any, t *types.Pointer, elide bool) error {
+	if v == nil {
+		g.b.WriteString("nil")
+		return nil
+	}
+	if _, ok := t.Elem().Underlying().(*types.Struct); ok {
+		if !elide {
+			g.b.WriteString("&")
+		}
+		return g.typed(path, v, t.Elem(), false, elide)
+	}
+	// The address of an element of a slice literal.
+	g.b.WriteString("&[]" + types.TypeString(t.Elem(), g.qualifier) + "{")
+	if err := g.typed(path, v, t.Elem(), false, true); err != nil {
+		return err
+	}
+	g.b.WriteString("}[0]")
+	return nil
+}
+
+// Reports whether v can be represented by the integer kind k.
+func intFits(v int64, k types.BasicKind) bool {
+	switch k {
+	case types.Int8:
+		return v == int64(int8(v))
+	case types.Int16:
+		return v == int64(int16(v))
+	case types.Int32:
+		return v == int64(int32(v))
+	case types.Uint8:
+		return v == int64(uint8(v))
+	case types.Uint16:
+		return v == int64(uint16(v))
+	case types.Uint32:
+		return v == int64(uint32(v))
+	case types.Uint, types.Uint64, types.Uintptr:
+		return v >= 0
+	}
+	return true
+}
+
+// Reports whether v can be represented by the float kind k. Infinities and NaN
+// are rendered as expressions, and so always fit.
+func floatFits(v float64, k types.BasicKind) bool {
+	if k != types.Float32 {
+		return true
+	}
+	return math.Abs(v) <= math.MaxFloat32 || math.IsInf(v, 0) || v != v
+}
@@ -0,0 +1,183 @@
+package rod
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	gotoken "go/token"
+	"go/types"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGoSyntax(t *testing.T) {
+	v := _struct{
+		"A": _array{nil, true, _int(-1), 1.0, 2.5e100, math.Inf(-1), math.NaN(), math.Copysign(0, -1)},
+		"B": _map{_int(1): "x\ny", "k": _blob{0x00, 0xff}},
+		"C": Annotated{"a", _struct{}},
+		"D": _array{},
+		"E": _blob{},
+	}
+	got, err := GoSyntax(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `map[string]any{
+	"A": []any{
+		nil,
+		true,
+		int64(-1),
+		1.0,
+		2.5e+100,
+		math.Inf(-1),
+		math.NaN(),
+		math.Copysign(0, -1),
+	},
+	"B": map[any]any{
+		int64(1): "x\ny",
+		"k": []byte{
+			0x00, 0xff,
+		},
+	},
+	"C": rod.Annotated{Annotation: "a", Value: map[string]any{}},
+	"D": []any{},
+	"E": []byte{},
+}`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	cyclic := _array{nil}
+	cyclic[0] = cyclic
+	if _, err := GoSyntax(cyclic); err == nil {
+		t.Error("expected error for cyclic value")
+	}
+	if _, err := GoSyntax(_array{1}); err == nil {
+		t.Error("expected error for int")
+	}
+}
+
+const goSyntaxSource = `package p
+
+type Root struct {
+	Name    string             ` + "`rod:\"name\"`" + `
+	Count   uint8
+	Ratio   float32
+	Ints    []int64
+	Items   []*Item
+	Table   map[string]Item
+	Data    []byte
+	Label   *string
+	Extra   any
+	Skipped int ` + "`rod:\"-\"`" + `
+}
+
+type Item struct {
+	ID ID
+}
+
+type ID int32
+`
+
+// Type-checks src as a package, along with each of exprs as the value of a
+// variable.
+func checkGoPackage(t *testing.T, src string, exprs ...string) *types.Package {
+	t.Helper()
+	if len(exprs) > 0 {
+		src = strings.Replace(src, "package p\n", "package p\n\nimport \"math\"\n\nvar _ = math.Pi\n", 1)
+	}
+	for _, expr := range exprs {
+		src += "\nvar _ = " + expr + "\n"
+	}
+	fset := gotoken.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("parse: %s\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("check: %s\n%s", err, src)
+	}
+	return pkg
+}
+
+func TestGoSyntaxAsType(t *testing.T) {
+	pkg := checkGoPackage(t, goSyntaxSource)
+	root := pkg.Scope().Lookup("Root").Type()
+	q := types.RelativeTo(pkg)
+
+	v := _struct{
+		"name":  "n",
+		"Count": Annotated{"u8", _int(255)},
+		"Ratio": math.Inf(1),
+		"Ints":  _array{_int(1)},
+		"Items": _array{_struct{"ID": _int(1)}, nil},
+		"Table": _map{"a": _struct{}},
+		"Data":  _blob{1},
+		"Label": "l",
+		"Extra": Annotated{"x", _int(1)},
+	}
+	got, err := GoSyntax(v, AsType(root, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Root{
+	Name:  "n",
+	Count: 255,
+	Ratio: float32(math.Inf(1)),
+	Ints: []int64{
+		1,
+	},
+	Items: []*Item{
+		{
+			ID: 1,
+		},
+		nil,
+	},
+	Table: map[string]Item{
+		"a": {},
+	},
+	Data: []byte{
+		0x01,
+	},
+	Label: &[]string{"l"}[0],
+	Extra: rod.Annotated{Annotation: "x", Value: int64(1)},
+}`
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Except for the rod package, the result is valid within the package.
+	got, _ = GoSyntax(_struct{"Label": nil, "Ratio": _int(2)}, AsType(root, q))
+	checkGoPackage(t, goSyntaxSource, got)
+	got, _ = GoSyntax(_int(3), AsType(pkg.Scope().Lookup("ID").Type(), q))
+	if got != "ID(3)" {
+		t.Errorf("expected ID(3), got %s", got)
+	}
+	got, _ = GoSyntax(_struct{"ID": _int(3)}, AsType(types.NewPointer(pkg.Scope().Lookup("Item").Type()), q))
+	checkGoPackage(t, goSyntaxSource, got)
+	got, _ = GoSyntax(_struct{"Ratio": _float(math.MaxFloat32)}, AsType(root, q))
+	checkGoPackage(t, goSyntaxSource, got)
+	if _, err := GoSyntax(_float(1e300), AsType(types.Typ[types.Float32], nil)); err == nil || err.Error() != ".: 1e+300 overflows float32" {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+
+	for _, v := range []any{
+		_struct{"Count": _int(256)},
+		_struct{"Count": _int(-1)},
+		_struct{"Ratio": _float(1e300)},
+		_struct{"Ratio": _float(-1e39)},
+		_struct{"Skipped": _int(1)},
+		_struct{"Unknown": _int(1)},
+		_struct{"name": _int(1)},
+		_struct{"Ints": _array{1.5}},
+		_struct{"Table": _map{_int(1): _struct{}}},
+		_array{},
+	} {
+		if _, err := GoSyntax(v, AsType(root, q)); err == nil {
+			t.Errorf("%s: expected error", formatInline(v))
+		}
+	}
+}
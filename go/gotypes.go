@@ -0,0 +1,241 @@
+package rod
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GoTypes returns Go type declarations for values described by schema, in the
+// format described by Validate. The root type is declared with the given name,
+// and nested structs are declared with names derived from it. The result is
+// formatted as by gofmt, and contains no package clause.
+//
+// ROD types are mapped to Go types as follows:
+//
+//	null      any
+//	bool      bool
+//	int       int64, or the integer type named by an annotation such as
+//	          <int32> or <uint8>
+//	float     float64, or float32 if annotated with <float32>
+//	string    string
+//	blob      []byte
+//	array     a slice of the element type
+//	map       a map from the key type to the value type, or map[any]any if
+//	          the keys are not of a single primitive type
+//	struct    a named struct type, or any if its fields are unknown
+//
+// A union of int and float is mapped to a float type, and a union with null is
+// mapped to a pointer to the other type, except at a root struct. Other unions
+// are mapped to any. Each struct field has a rod tag containing the name of the
+// field, along with the omitempty option if the field is not required.
+func GoTypes(schema any, name string) ([]byte, error) {
+	s, err := compileSchema(nil, schema, map[identity]*schemaNode{})
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	g := goTypeGen{
+		names: map[*schemaNode]string{},
+		used:  map[string]bool{},
+	}
+	name = g.unique(goIdent(name))
+	t := g.typeOf(s, name, true)
+	if t == "*"+name {
+		// The root struct is declared with name, so a pointer to it cannot
+		// also be.
+		t = name
+	}
+	if t != name {
+		g.decls = append([]string{"type " + name + " " + t + "\n"}, g.decls...)
+	}
+	src, err := format.Source([]byte(strings.Join(g.decls, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("format: %w", err)
+	}
+	return src, nil
+}
+
+type goTypeGen struct {
+	decls []string
+	// Names of struct types that have been declared or are being declared.
+	names map[*schemaNode]string
+	// Structs currently being declared.
+	pending map[*schemaNode]bool
+	used    map[string]bool
+}
+
+// Returns name, with a number appended if it is already used.
+func (g *goTypeGen) unique(name string) string {
+	u := name
+	for i := 2; g.used[u]; i++ {
+		u = name + strconv.Itoa(i)
+	}
+	g.used[u] = true
+	return u
+}
+
+// Go types of annotations that refine ints and floats.
+var (
+	goIntTypes   = []string{"int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64"}
+	goFloatTypes = []string{"float32", "float64"}
+)
+
+// Returns the Go type of values described by s. Structs are declared with
+// name. If root is true, then the root struct receives name exactly.
+func (g *goTypeGen) typeOf(s *schemaNode, name string, root bool) string {
+	types := s.types
+	nullable := false
+	if len(types) > 1 && containsString(types, "null") {
+		nullable = true
+		types = removeString(types, "null")
+	}
+	var t string
+	switch {
+	case len(types) == 0:
+		return "any"
+	case len(types) == 2 && containsString(types, "int") && containsString(types, "float"):
+		t = g.annotated(s, "float64", goFloatTypes)
+	case len(types) > 1:
+		return "any"
+	default:
+		switch types[0] {
+		case "null":
+			return "any"
+		case "bool":
+			t = "bool"
+		case "int":
+			t = g.annotated(s, "int64", goIntTypes)
+		case "float":
+			t = g.annotated(s, "float64", goFloatTypes)
+		case "string":
+			t = "string"
+		case "blob":
+			return "[]byte"
+		case "array":
+			elem := "any"
+			if s.elem != nil {
+				elem = g.typeOf(s.elem, name+"Elem", false)
+			}
+			return "[]" + elem
+		case "map":
+			key := "any"
+			if s.key != nil {
+				key = g.typeOf(s.key, name+"Key", false)
+				switch key {
+				case "bool", "string", "int64", "float64", "float32",
+					"int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32", "uint64":
+				default:
+					key = "any"
+				}
+			}
+			value := "any"
+			// A map with keys of any type is encoded dynamically, which
+			// requires values of any type.
+			if s.value != nil && key != "any" {
+				value = g.typeOf(s.value, name+"Value", false)
+			}
+			return "map[" + key + "]" + value
+		case "struct":
+			if s.fields == nil {
+				return "any"
+			}
+			t = g.declareStruct(s, name, root)
+		}
+	}
+	if nullable || strings.HasPrefix(t, "*") {
+		t = "*" + strings.TrimPrefix(t, "*")
+	}
+	return t
+}
+
+// Returns the type named by the annotation of s if it is one of types.
+// Otherwise, returns def.
+func (g *goTypeGen) annotated(s *schemaNode, def string, types []string) string {
+	var found string
+	for _, a := range s.annotations {
+		a, ok := a.(string)
+		if !ok {
+			continue
+		}
+		if !containsString(types, a) || found != "" && found != a {
+			return def
+		}
+		found = a
+	}
+	if found == "" {
+		return def
+	}
+	return found
+}
+
+// Declares a struct type for s, returning its name. A reference to a struct
+// that is still being declared is returned as a pointer, so that recursive
+// types have a finite size.
+func (g *goTypeGen) declareStruct(s *schemaNode, name string, root bool) string {
+	if n, ok := g.names[s]; ok {
+		if g.pending[s] {
+			return "*" + n
+		}
+		return n
+	}
+	if !root {
+		name = g.unique(name)
+	}
+	g.names[s] = name
+	if g.pending == nil {
+		g.pending = map[*schemaNode]bool{}
+	}
+	g.pending[s] = true
+	defer delete(g.pending, s)
+
+	names := make([]string, 0, len(s.fields))
+	for f := range s.fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	fieldNames := map[string]bool{}
+	// Reserve the declaration so that nested types follow it.
+	i := len(g.decls)
+	g.decls = append(g.decls, "")
+	for _, f := range names {
+		field := goIdent(f)
+		for j := 2; fieldNames[field]; j++ {
+			field = goIdent(f) + strconv.Itoa(j)
+		}
+		fieldNames[field] = true
+		t := g.typeOf(s.fields[f], name+goIdent(f), false)
+		tag := f
+		if !containsString(s.required, f) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&b, "\t%s %s `rod:%s`\n", field, t, strconv.Quote(tag))
+	}
+	b.WriteString("}\n")
+	g.decls[i] = b.String()
+	return name
+}
+
+// Returns an exported Go identifier for the ROD identifier s.
+func goIdent(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if r == '_' || r == utf8.RuneError {
+		return "X" + s
+	}
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func removeString(a []string, s string) []string {
+	r := make([]string, 0, len(a))
+	for _, x := range a {
+		if x != s {
+			r = append(r, x)
+		}
+	}
+	return r
+}
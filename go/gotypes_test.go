@@ -0,0 +1,109 @@
+package rod
+
+import (
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestGoTypes(t *testing.T) {
+	var schema any
+	NewDecoder(strings.NewReader(`{
+		Type: "struct",
+		Fields: {
+			Name: {Type: "string"},
+			Scale: {Type: "float", Annotation: [null, "float32"]},
+			Count: {Type: "int", Annotation: "uint16"},
+			Ratio: {Type: ["int", "float"]},
+			Data: {Type: "blob"},
+			Maybe: {Type: ["null", "bool"]},
+			Mixed: {Type: ["string", "int"]},
+			Table: {Type: "map", Key: {Type: "string"}, Value: {Type: "struct", Fields: {X: {Type: "int"}}}},
+			Items: {Type: "array", Elem: {Type: "struct", Fields: {_id: {Type: "int"}}}},
+			Other: {Type: "struct"},
+		},
+		Required: ["Name", "Count"],
+	}`)).Decode(&schema)
+	got, err := GoTypes(schema, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "type Config struct {\n" +
+		"\tCount uint16                      `rod:\"Count\"`\n" +
+		"\tData  []byte                      `rod:\"Data,omitempty\"`\n" +
+		"\tItems []ConfigItemsElem           `rod:\"Items,omitempty\"`\n" +
+		"\tMaybe *bool                       `rod:\"Maybe,omitempty\"`\n" +
+		"\tMixed any                         `rod:\"Mixed,omitempty\"`\n" +
+		"\tName  string                      `rod:\"Name\"`\n" +
+		"\tOther any                         `rod:\"Other,omitempty\"`\n" +
+		"\tRatio float64                     `rod:\"Ratio,omitempty\"`\n" +
+		"\tScale float32                     `rod:\"Scale,omitempty\"`\n" +
+		"\tTable map[string]ConfigTableValue `rod:\"Table,omitempty\"`\n" +
+		"}\n\n" +
+		"type ConfigItemsElem struct {\n" +
+		"\tX_id int64 `rod:\"_id,omitempty\"`\n" +
+		"}\n\n" +
+		"type ConfigTableValue struct {\n" +
+		"\tX int64 `rod:\"X,omitempty\"`\n" +
+		"}\n"
+	if string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// A recursive schema produces a type with a finite size.
+	node := _struct{"Type": "struct"}
+	node["Fields"] = _struct{
+		"Value": _struct{"Type": "int"},
+		"Next":  node,
+		"Kids":  _struct{"Type": "array", "Elem": node},
+	}
+	got, err = GoTypes(node, "Node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoSource(t, got)
+	if !strings.Contains(string(got), "Next  *Node") || !strings.Contains(string(got), "Kids  []*Node") {
+		t.Errorf("unexpected recursive type:\n%s", got)
+	}
+
+	// A non-struct root is declared as a named type.
+	got, err = GoTypes(_struct{"Type": "array", "Elem": _struct{"Type": "struct", "Fields": _struct{}}}, "List")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoSource(t, got)
+	if !strings.HasPrefix(string(got), "type List []ListElem\n") {
+		t.Errorf("unexpected root type:\n%s", got)
+	}
+
+	// A nullable root struct is declared as the struct.
+	got, err = GoTypes(InferSchema(nil, _struct{"A": _int(1)}), "Root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoSource(t, got)
+	if !strings.HasPrefix(string(got), "type Root struct {\n") {
+		t.Errorf("unexpected nullable root type:\n%s", got)
+	}
+
+	if _, err := GoTypes(_struct{"Type": "thing"}, "X"); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+}
+
+// Reports an error if src is not a valid set of Go declarations.
+func checkGoSource(t *testing.T, src []byte) {
+	t.Helper()
+	fset := gotoken.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", "package p\n\n"+string(src), 0)
+	if err != nil {
+		t.Errorf("parse: %s\n%s", err, src)
+		return
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("check: %s\n%s", err, src)
+	}
+}
@@ -0,0 +1,158 @@
+package rod
+
+import "sort"
+
+// InferSchema returns the tightest schema, in the format described by
+// Validate, to which each of the decoded values docs conforms:
+//
+//   - Type lists each type observed at a location.
+//   - Annotation lists each annotation observed at a location, along with null
+//     if a value was not annotated. It is omitted if no value was annotated.
+//   - Min and Max are the least and greatest numbers observed at a location.
+//     They are omitted if a NaN was observed.
+//   - Fields describes each field observed in a struct, and Required lists the
+//     fields present in every observed struct.
+//   - Elem, Key, and Value describe every observed element, key, and value.
+//
+// With no documents, the schema is an empty struct, which permits any value.
+func InferSchema(docs ...any) map[string]any {
+	inf := inferrer{seen: map[identity]bool{}}
+	return inf.infer(docs)
+}
+
+type inferrer struct {
+	// Composites that have already been observed. A composite that is
+	// observed again, as through a cycle, is not observed further.
+	seen map[identity]bool
+}
+
+// Returns a schema that permits each of values.
+func (inf *inferrer) infer(values []any) map[string]any {
+	s := map[string]any{}
+	if len(values) == 0 {
+		return s
+	}
+
+	var (
+		types       = map[string]bool{}
+		annotations = map[string]bool{}
+		annotated   bool
+		unannotated bool
+		min, max    any
+		nan         bool
+		elems       []any
+		keys        []any
+		mapValues   []any
+		structs     int
+		fields      = map[string][]any{}
+	)
+	for _, v := range values {
+		if a, ok := annotationOf(v); ok {
+			annotations[a] = true
+			annotated = true
+		} else {
+			unannotated = true
+		}
+		v = unannotate(v)
+		types[typeName(v)] = true
+		if id, ok := identityOf(v); ok {
+			if inf.seen[id] {
+				continue
+			}
+			inf.seen[id] = true
+		}
+		switch v := v.(type) {
+		case int64, float64:
+			if f, ok := v.(float64); ok && f != f {
+				nan = true
+				break
+			}
+			if min == nil || compareNumbers(v, min) < 0 {
+				min = v
+			}
+			if max == nil || compareNumbers(v, max) > 0 {
+				max = v
+			}
+		case []any:
+			elems = append(elems, v...)
+		case map[any]any:
+			mapForEach(v, func(k, e any) error {
+				keys = append(keys, k)
+				mapValues = append(mapValues, e)
+				return nil
+			})
+		case map[string]any:
+			structs++
+			structForEach(v, func(k string, e any) error {
+				fields[k] = append(fields[k], e)
+				return nil
+			})
+		}
+	}
+
+	s["Type"] = inferTypes(types)
+	if annotated {
+		a := []any{}
+		if unannotated {
+			a = append(a, nil)
+		}
+		names := make([]string, 0, len(annotations))
+		for name := range annotations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			a = append(a, name)
+		}
+		s["Annotation"] = a
+	}
+	if min != nil && !nan {
+		s["Min"] = min
+		s["Max"] = max
+	}
+	if len(elems) > 0 {
+		s["Elem"] = inf.infer(elems)
+	}
+	if len(keys) > 0 {
+		s["Key"] = inf.infer(keys)
+		s["Value"] = inf.infer(mapValues)
+	}
+	if structs > 0 {
+		fs := map[string]any{}
+		required := []any{}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fs[name] = inf.infer(fields[name])
+			if len(fields[name]) == structs {
+				required = append(required, name)
+			}
+		}
+		s["Fields"] = fs
+		if len(required) > 0 {
+			s["Required"] = required
+		}
+	} else if types["struct"] {
+		// Each struct was observed elsewhere, so its fields are not known.
+		s["Open"] = true
+	}
+	return s
+}
+
+// Returns the observed types in the order listed by OfType, as a single string
+// if there is one.
+func inferTypes(types map[string]bool) any {
+	var a []any
+	for _, t := range schemaTypes {
+		if types[t] {
+			a = append(a, t)
+		}
+	}
+	if len(a) == 1 {
+		return a[0]
+	}
+	return a
+}
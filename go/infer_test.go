@@ -0,0 +1,76 @@
+package rod
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	docs := []any{
+		_struct{
+			"Name":  "a",
+			"Score": _int(5),
+			"Tags":  _array{"x", _int(1)},
+			"ID":    Annotated{"uint32", _int(1)},
+			"Table": _map{_int(1): true},
+		},
+		_struct{
+			"Name":  "b",
+			"Score": _float(7.5),
+			"Tags":  _array{},
+			"ID":    _int(2),
+			"Extra": nil,
+		},
+		_struct{
+			"Name":  "c",
+			"Score": _int(-1),
+			"ID":    Annotated{"int32", _int(3)},
+			"Table": _map{math.NaN(): false},
+		},
+	}
+	got := InferSchema(docs...)
+	want := _struct{
+		"Type": "struct",
+		"Fields": _struct{
+			"Extra": _struct{"Type": "null"},
+			"ID": _struct{
+				"Type":       "int",
+				"Annotation": _array{nil, "int32", "uint32"},
+				"Min":        _int(1),
+				"Max":        _int(3),
+			},
+			"Name":  _struct{"Type": "string"},
+			"Score": _struct{"Type": _array{"int", "float"}, "Min": _int(-1), "Max": _float(7.5)},
+			"Table": _struct{
+				"Type":  "map",
+				"Key":   _struct{"Type": _array{"int", "float"}},
+				"Value": _struct{"Type": "bool"},
+			},
+			"Tags": _struct{
+				"Type": "array",
+				"Elem": _struct{"Type": _array{"int", "string"}, "Min": _int(1), "Max": _int(1)},
+			},
+		},
+		"Required": _array{"ID", "Name", "Score"},
+	}
+	if !Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", formatInline(want), formatInline(got))
+	}
+	for _, doc := range docs {
+		if vs, err := Validate(doc, got); err != nil || len(vs) > 0 {
+			t.Errorf("%s: unexpected violations %v, %v", formatInline(doc), vs, err)
+		}
+	}
+
+	if s := InferSchema(); len(s) != 0 {
+		t.Errorf("expected empty schema, got %s", formatInline(s))
+	}
+
+	var cyclic any
+	NewDecoder(strings.NewReader(`&1 {Next: *1, Value: 1}`)).Decode(&cyclic)
+	s := InferSchema(cyclic)
+	if vs, err := Validate(cyclic, s); err != nil || len(vs) > 0 {
+		t.Errorf("unexpected violations %v, %v", vs, err)
+	}
+}
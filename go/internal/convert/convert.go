@@ -0,0 +1,56 @@
+// Package convert contains helpers shared by the packages that convert between
+// the decoded values of the rod package and other formats.
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// Describe returns a description of the type of v for error messages.
+func Describe(v any) string {
+	switch v := v.(type) {
+	case rod.Annotated:
+		return "annotated value"
+	case []byte:
+		return "blob"
+	case []any:
+		return "array"
+	case map[any]any:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Visiting tracks the composites currently being converted, to detect cyclic
+// values.
+type Visiting map[uintptr]bool
+
+// Visit marks the composite v as being converted, returning a function that
+// unmarks it. Returns an error if v is already being converted. An empty array
+// must not be visited, since it may share its pointer with other values.
+func (m Visiting) Visit(v any) (leave func(), err error) {
+	p := reflect.ValueOf(v).Pointer()
+	if m[p] {
+		return nil, errors.New("cannot convert cyclic value")
+	}
+	m[p] = true
+	return func() { delete(m, p) }, nil
+}
+
+// IsKey reports whether k can be converted as a map key: a null, bool, int,
+// float, or string, which may be annotated.
+func IsKey(k any) bool {
+	if a, ok := k.(rod.Annotated); ok {
+		k = a.Value
+	}
+	switch k.(type) {
+	case nil, bool, int64, float64, string:
+		return true
+	}
+	return false
+}
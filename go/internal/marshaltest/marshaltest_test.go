@@ -0,0 +1,234 @@
+package marshaltest
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// Converts v to the equivalent decoded value through reflection, according to
+// the rod tags of struct fields.
+func toDynamic(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return v.Elem().Interface()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return toDynamic(v.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes()
+		}
+		a := make([]any, v.Len())
+		for i := range a {
+			a[i] = toDynamic(v.Index(i))
+		}
+		return a
+	case reflect.Map:
+		m := make(map[any]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[toDynamic(iter.Key())] = toDynamic(iter.Value())
+		}
+		return m
+	case reflect.Struct:
+		s := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			tag := f.Tag.Get("rod")
+			if !f.IsExported() || tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+			fv := v.Field(i)
+			if opts == "omitempty" && isEmpty(fv) {
+				continue
+			}
+			s[name] = toDynamic(fv)
+		}
+		return s
+	}
+	panic("unsupported kind " + v.Kind().String())
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+func encode(t testing.TB, v any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := rod.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("encode: %s", err)
+	}
+	return buf.String()
+}
+
+func label(s string) *string { return &s }
+
+var samples = []Sample{
+	{},
+	{
+		Name:  "root",
+		Count: 255,
+		Small: -7,
+		Big:   math.MaxInt64,
+		Ratio: 0.25,
+		Scale: 1.5,
+		OK:    true,
+		Data:  []byte("\x00\x01binary data that spans lines"),
+		Tags:  []string{"b", "a", "multi\nline"},
+		Table: map[string]int64{"z": 1, "a": 2, "m": -3},
+		Codes: map[int32]bool{3: true, -1: false, 2: true},
+		Items: []Item{
+			{ID: 1, Weights: map[string]float64{"x": math.Inf(1), "y": 2}},
+			{ID: 2, Label: label(""), Weights: map[string]float64{}, Flags: map[bool]Flag{true: 1, false: 65535}},
+		},
+		Next:    &Sample{Name: "next", Extra: []any{int64(1), map[string]any{"A": nil}}},
+		Extra:   map[any]any{"k": []byte{1}, int64(1): true},
+		Meta:    map[any]any{int64(2): "b", "a": nil, false: 1.5},
+		Path:    Path{"a", "b"},
+		Ignored: "ignored",
+		Default: "default",
+		hidden:  1,
+	},
+}
+
+func TestGeneratedEncode(t *testing.T) {
+	for i, sample := range samples {
+		want := encode(t, toDynamic(reflect.ValueOf(sample)))
+		if got := encode(t, sample); got != want {
+			t.Errorf("sample %d: expected:\n%s\ngot:\n%s", i, want, got)
+		}
+	}
+
+	// A Marshaler within a decoded value.
+	want := encode(t, []any{toDynamic(reflect.ValueOf(samples[1])), int64(1)})
+	if got := encode(t, []any{samples[1], int64(1)}); got != want {
+		t.Errorf("nested: expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestGeneratedDecode(t *testing.T) {
+	for i, sample := range samples {
+		s := encode(t, sample)
+		var got Sample
+		if err := rod.NewDecoder(strings.NewReader(s)).Decode(&got); err != nil {
+			t.Errorf("sample %d: decode: %s", i, err)
+			continue
+		}
+		if again := encode(t, got); again != s {
+			t.Errorf("sample %d: expected:\n%s\ngot:\n%s", i, s, again)
+		}
+	}
+
+	var got Sample
+	got.Name = "overwritten"
+	d := rod.NewDecoder(strings.NewReader(`{
+		Unknown: [1, {A: &1 (1: 2)}],
+		Count: <u8> 3,
+		Ratio: 2,
+		Next: <next> &2 {Tags: ["x",]},
+		Extra: *1,
+		Path: [],
+	}`))
+	d.SetAnnotations(true)
+	if err := d.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := Sample{
+		Count: 3,
+		Ratio: 2,
+		Next:  &Sample{Tags: []string{"x"}},
+		Extra: map[any]any{int64(1): int64(2)},
+		Path:  Path{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %#v, got %#v", want, got)
+	}
+
+	for s, msg := range map[string]string{
+		`{Count: 256}`:          "256 overflows uint8",
+		`{Count: -1}`:           "-1 overflows uint64",
+		`{Name: 1}`:             "expected string, got int",
+		`{Items: {}}`:           "expected array, got struct",
+		`{Table: ("a": "b")}`:   "expected int, got string",
+		`{Table: (1: 2)}`:       "expected string, got int",
+		`{Path: ["a"] [}`:       "",
+		`[]`:                    "expected struct, got array",
+		`{Items: [{_id: 1.5}]}`: "expected int, got float",
+	} {
+		err := rod.NewDecoder(strings.NewReader(s)).Decode(&got)
+		if err == nil {
+			t.Errorf("%s: expected error", s)
+		} else if msg != "" && err.Error() != msg {
+			t.Errorf("%s: expected error %q, got %q", s, msg, err)
+		}
+	}
+}
+
+func BenchmarkEncodeGenerated(b *testing.B) {
+	e := rod.NewEncoder(io.Discard)
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(samples[1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeReflect(b *testing.B) {
+	e := rod.NewEncoder(io.Discard)
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(toDynamic(reflect.ValueOf(samples[1]))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeGenerated(b *testing.B) {
+	s := encode(b, samples[1])
+	for i := 0; i < b.N; i++ {
+		var v Sample
+		if err := rod.NewDecoder(strings.NewReader(s)).Decode(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeDynamic(b *testing.B) {
+	s := encode(b, samples[1])
+	for i := 0; i < b.N; i++ {
+		var v any
+		if err := rod.NewDecoder(strings.NewReader(s)).Decode(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
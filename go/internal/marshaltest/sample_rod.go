@@ -0,0 +1,660 @@
+// Code generated by rod gen-marshal. DO NOT EDIT.
+
+package marshaltest
+
+import (
+	"fmt"
+	"sort"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// MarshalROD implements rod.Marshaler.
+func (v Sample) MarshalROD(e *rod.Encoder) error {
+	if err := e.BeginStruct(); err != nil {
+		return err
+	}
+	if v.Big != 0 {
+		if err := e.WriteField("Big"); err != nil {
+			return err
+		}
+		if err := e.WriteUint(v.Big); err != nil {
+			return err
+		}
+	}
+	if len(v.Codes) != 0 {
+		if err := e.WriteField("Codes"); err != nil {
+			return err
+		}
+		keys1 := make([]int32, 0, len(v.Codes))
+		for key2 := range v.Codes {
+			keys1 = append(keys1, key2)
+		}
+		sort.Slice(keys1, func(i, j int) bool {
+			return keys1[i] < keys1[j]
+		})
+		if err := e.BeginMap(); err != nil {
+			return err
+		}
+		for _, key2 := range keys1 {
+			if err := e.WriteInt(int64(key2)); err != nil {
+				return err
+			}
+			if err := e.WriteBool(v.Codes[key2]); err != nil {
+				return err
+			}
+		}
+		if err := e.EndMap(); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("Count"); err != nil {
+		return err
+	}
+	if err := e.WriteUint(uint64(v.Count)); err != nil {
+		return err
+	}
+	if err := e.WriteField("Data"); err != nil {
+		return err
+	}
+	if err := e.WriteBlob(v.Data); err != nil {
+		return err
+	}
+	if err := e.WriteField("Default"); err != nil {
+		return err
+	}
+	if err := e.WriteString(v.Default); err != nil {
+		return err
+	}
+	if err := e.WriteField("Extra"); err != nil {
+		return err
+	}
+	if err := e.WriteValue(v.Extra); err != nil {
+		return err
+	}
+	if err := e.WriteField("Items"); err != nil {
+		return err
+	}
+	if err := e.BeginArray(); err != nil {
+		return err
+	}
+	for _, elem3 := range v.Items {
+		if err := elem3.MarshalROD(e); err != nil {
+			return err
+		}
+	}
+	if err := e.EndArray(); err != nil {
+		return err
+	}
+	if len(v.Meta) != 0 {
+		if err := e.WriteField("Meta"); err != nil {
+			return err
+		}
+		if err := e.WriteValue(map[any]any(v.Meta)); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("Name"); err != nil {
+		return err
+	}
+	if err := e.WriteString(v.Name); err != nil {
+		return err
+	}
+	if err := e.WriteField("Next"); err != nil {
+		return err
+	}
+	if v.Next == nil {
+		if err := e.WriteNull(); err != nil {
+			return err
+		}
+	} else {
+		if err := (*v.Next).MarshalROD(e); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("OK"); err != nil {
+		return err
+	}
+	if err := e.WriteBool(v.OK); err != nil {
+		return err
+	}
+	if len(v.Path) != 0 {
+		if err := e.WriteField("Path"); err != nil {
+			return err
+		}
+		if err := v.Path.MarshalROD(e); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("Ratio"); err != nil {
+		return err
+	}
+	if err := e.WriteFloat(v.Ratio); err != nil {
+		return err
+	}
+	if v.Scale != 0 {
+		if err := e.WriteField("Scale"); err != nil {
+			return err
+		}
+		if err := e.WriteFloat(float64(v.Scale)); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("Table"); err != nil {
+		return err
+	}
+	keys4 := make([]string, 0, len(v.Table))
+	for key5 := range v.Table {
+		keys4 = append(keys4, key5)
+	}
+	sort.Slice(keys4, func(i, j int) bool {
+		return keys4[i] < keys4[j]
+	})
+	if err := e.BeginMap(); err != nil {
+		return err
+	}
+	for _, key5 := range keys4 {
+		if err := e.WriteString(key5); err != nil {
+			return err
+		}
+		if err := e.WriteInt(v.Table[key5]); err != nil {
+			return err
+		}
+	}
+	if err := e.EndMap(); err != nil {
+		return err
+	}
+	if err := e.WriteField("Tags"); err != nil {
+		return err
+	}
+	if err := e.BeginArray(); err != nil {
+		return err
+	}
+	for _, elem6 := range v.Tags {
+		if err := e.WriteString(elem6); err != nil {
+			return err
+		}
+	}
+	if err := e.EndArray(); err != nil {
+		return err
+	}
+	if v.Small != 0 {
+		if err := e.WriteField("small"); err != nil {
+			return err
+		}
+		if err := e.WriteInt(int64(v.Small)); err != nil {
+			return err
+		}
+	}
+	return e.EndStruct()
+}
+
+// UnmarshalROD implements rod.Unmarshaler.
+func (v *Sample) UnmarshalROD(d *rod.Decoder) error {
+	*v = Sample{}
+	if err := d.BeginStruct(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+		field, err := d.ReadField()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case "Big":
+			x7, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			v.Big = x7
+		case "Codes":
+			if err := d.BeginMap(); err != nil {
+				return err
+			}
+			x8 := map[int32]bool{}
+			for {
+				more11, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more11 {
+					break
+				}
+				var key9 int32
+				x12, err := d.ReadInt()
+				if err != nil {
+					return err
+				}
+				if int64(int32(x12)) != x12 {
+					return fmt.Errorf("%d overflows int32", x12)
+				}
+				key9 = int32(x12)
+				var value10 bool
+				x13, err := d.ReadBool()
+				if err != nil {
+					return err
+				}
+				value10 = x13
+				x8[key9] = value10
+			}
+			v.Codes = x8
+		case "Count":
+			x14, err := d.ReadUint()
+			if err != nil {
+				return err
+			}
+			if uint64(uint8(x14)) != x14 {
+				return fmt.Errorf("%d overflows uint8", x14)
+			}
+			v.Count = uint8(x14)
+		case "Data":
+			x15, err := d.ReadBlob()
+			if err != nil {
+				return err
+			}
+			v.Data = x15
+		case "Default":
+			x16, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Default = x16
+		case "Extra":
+			if err := d.ReadValue(&v.Extra); err != nil {
+				return err
+			}
+		case "Items":
+			if err := d.BeginArray(); err != nil {
+				return err
+			}
+			x17 := []Item{}
+			for {
+				more19, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more19 {
+					break
+				}
+				var elem18 Item
+				if err := elem18.UnmarshalROD(d); err != nil {
+					return err
+				}
+				x17 = append(x17, elem18)
+			}
+			v.Items = x17
+		case "Meta":
+			if err := d.BeginMap(); err != nil {
+				return err
+			}
+			x20 := map[any]any{}
+			for {
+				more23, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more23 {
+					break
+				}
+				var key21 any
+				if err := d.ReadValue(&key21); err != nil {
+					return err
+				}
+				var value22 any
+				if err := d.ReadValue(&value22); err != nil {
+					return err
+				}
+				x20[key21] = value22
+			}
+			v.Meta = x20
+		case "Name":
+			x24, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			v.Name = x24
+		case "Next":
+			if null25, err := d.ReadNull(); err != nil {
+				return err
+			} else if null25 {
+				v.Next = nil
+			} else {
+				var x26 Sample
+				if err := x26.UnmarshalROD(d); err != nil {
+					return err
+				}
+				v.Next = &x26
+			}
+		case "OK":
+			x27, err := d.ReadBool()
+			if err != nil {
+				return err
+			}
+			v.OK = x27
+		case "Path":
+			if err := v.Path.UnmarshalROD(d); err != nil {
+				return err
+			}
+		case "Ratio":
+			x28, err := d.ReadFloat()
+			if err != nil {
+				return err
+			}
+			v.Ratio = x28
+		case "Scale":
+			x29, err := d.ReadFloat()
+			if err != nil {
+				return err
+			}
+			v.Scale = float32(x29)
+		case "Table":
+			if err := d.BeginMap(); err != nil {
+				return err
+			}
+			x30 := map[string]int64{}
+			for {
+				more33, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more33 {
+					break
+				}
+				var key31 string
+				x34, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				key31 = x34
+				var value32 int64
+				x35, err := d.ReadInt()
+				if err != nil {
+					return err
+				}
+				value32 = x35
+				x30[key31] = value32
+			}
+			v.Table = x30
+		case "Tags":
+			if err := d.BeginArray(); err != nil {
+				return err
+			}
+			x36 := []string{}
+			for {
+				more38, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more38 {
+					break
+				}
+				var elem37 string
+				x39, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				elem37 = x39
+				x36 = append(x36, elem37)
+			}
+			v.Tags = x36
+		case "small":
+			x40, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			if int64(int32(x40)) != x40 {
+				return fmt.Errorf("%d overflows int32", x40)
+			}
+			v.Small = int32(x40)
+		default:
+			var skip any
+			if err := d.ReadValue(&skip); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// MarshalROD implements rod.Marshaler.
+func (v Item) MarshalROD(e *rod.Encoder) error {
+	if err := e.BeginStruct(); err != nil {
+		return err
+	}
+	if len(v.Flags) != 0 {
+		if err := e.WriteField("Flags"); err != nil {
+			return err
+		}
+		keys41 := make([]bool, 0, len(v.Flags))
+		for key42 := range v.Flags {
+			keys41 = append(keys41, key42)
+		}
+		sort.Slice(keys41, func(i, j int) bool {
+			return !keys41[i] && keys41[j]
+		})
+		if err := e.BeginMap(); err != nil {
+			return err
+		}
+		for _, key42 := range keys41 {
+			if err := e.WriteBool(key42); err != nil {
+				return err
+			}
+			if err := v.Flags[key42].MarshalROD(e); err != nil {
+				return err
+			}
+		}
+		if err := e.EndMap(); err != nil {
+			return err
+		}
+	}
+	if v.Label != nil {
+		if err := e.WriteField("Label"); err != nil {
+			return err
+		}
+		if err := e.WriteString(*v.Label); err != nil {
+			return err
+		}
+	}
+	if err := e.WriteField("Weights"); err != nil {
+		return err
+	}
+	keys43 := make([]string, 0, len(v.Weights))
+	for key44 := range v.Weights {
+		keys43 = append(keys43, key44)
+	}
+	sort.Slice(keys43, func(i, j int) bool {
+		return keys43[i] < keys43[j]
+	})
+	if err := e.BeginMap(); err != nil {
+		return err
+	}
+	for _, key44 := range keys43 {
+		if err := e.WriteString(key44); err != nil {
+			return err
+		}
+		if err := e.WriteFloat(v.Weights[key44]); err != nil {
+			return err
+		}
+	}
+	if err := e.EndMap(); err != nil {
+		return err
+	}
+	if err := e.WriteField("_id"); err != nil {
+		return err
+	}
+	if err := e.WriteInt(v.ID); err != nil {
+		return err
+	}
+	return e.EndStruct()
+}
+
+// UnmarshalROD implements rod.Unmarshaler.
+func (v *Item) UnmarshalROD(d *rod.Decoder) error {
+	*v = Item{}
+	if err := d.BeginStruct(); err != nil {
+		return err
+	}
+	for {
+		more, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+		field, err := d.ReadField()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case "Flags":
+			if err := d.BeginMap(); err != nil {
+				return err
+			}
+			x45 := map[bool]Flag{}
+			for {
+				more48, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more48 {
+					break
+				}
+				var key46 bool
+				x49, err := d.ReadBool()
+				if err != nil {
+					return err
+				}
+				key46 = x49
+				var value47 Flag
+				if err := value47.UnmarshalROD(d); err != nil {
+					return err
+				}
+				x45[key46] = value47
+			}
+			v.Flags = x45
+		case "Label":
+			if null50, err := d.ReadNull(); err != nil {
+				return err
+			} else if null50 {
+				v.Label = nil
+			} else {
+				var x51 string
+				x52, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				x51 = x52
+				v.Label = &x51
+			}
+		case "Weights":
+			if err := d.BeginMap(); err != nil {
+				return err
+			}
+			x53 := map[string]float64{}
+			for {
+				more56, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more56 {
+					break
+				}
+				var key54 string
+				x57, err := d.ReadString()
+				if err != nil {
+					return err
+				}
+				key54 = x57
+				var value55 float64
+				x58, err := d.ReadFloat()
+				if err != nil {
+					return err
+				}
+				value55 = x58
+				x53[key54] = value55
+			}
+			v.Weights = x53
+		case "_id":
+			x59, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			v.ID = x59
+		default:
+			var skip any
+			if err := d.ReadValue(&skip); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// MarshalROD implements rod.Marshaler.
+func (v Path) MarshalROD(e *rod.Encoder) error {
+	if err := e.BeginArray(); err != nil {
+		return err
+	}
+	for _, elem60 := range v {
+		if err := e.WriteString(elem60); err != nil {
+			return err
+		}
+	}
+	if err := e.EndArray(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalROD implements rod.Unmarshaler.
+func (v *Path) UnmarshalROD(d *rod.Decoder) error {
+	if err := d.BeginArray(); err != nil {
+		return err
+	}
+	x61 := Path{}
+	for {
+		more63, err := d.More()
+		if err != nil {
+			return err
+		}
+		if !more63 {
+			break
+		}
+		var elem62 string
+		x64, err := d.ReadString()
+		if err != nil {
+			return err
+		}
+		elem62 = x64
+		x61 = append(x61, elem62)
+	}
+	*v = x61
+	return nil
+}
+
+// MarshalROD implements rod.Marshaler.
+func (v Flag) MarshalROD(e *rod.Encoder) error {
+	if err := e.WriteUint(uint64(v)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalROD implements rod.Unmarshaler.
+func (v *Flag) UnmarshalROD(d *rod.Decoder) error {
+	x65, err := d.ReadUint()
+	if err != nil {
+		return err
+	}
+	if uint64(uint16(x65)) != x65 {
+		return fmt.Errorf("%d overflows uint16", x65)
+	}
+	*v = Flag(x65)
+	return nil
+}
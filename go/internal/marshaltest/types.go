@@ -0,0 +1,39 @@
+// Package marshaltest contains types with methods generated by "rod
+// gen-marshal", to test that generated methods encode and decode values as
+// the Encoder and Decoder do.
+package marshaltest
+
+//go:generate go run github.com/anaminus/rod/go/cmd/rod gen-marshal -type=Sample
+
+type Sample struct {
+	Name    string           `rod:"Name"`
+	Count   uint8            `rod:"Count"`
+	Small   int32            `rod:"small,omitempty"`
+	Big     uint64           `rod:"Big,omitempty"`
+	Ratio   float64          `rod:"Ratio"`
+	Scale   float32          `rod:"Scale,omitempty"`
+	OK      bool             `rod:"OK"`
+	Data    []byte           `rod:"Data"`
+	Tags    []string         `rod:"Tags"`
+	Table   map[string]int64 `rod:"Table"`
+	Codes   map[int32]bool   `rod:"Codes,omitempty"`
+	Items   []Item           `rod:"Items"`
+	Next    *Sample          `rod:"Next"`
+	Extra   any              `rod:"Extra"`
+	Meta    map[any]any      `rod:"Meta,omitempty"`
+	Path    Path             `rod:"Path,omitempty"`
+	Ignored string           `rod:"-"`
+	Default string
+	hidden  int
+}
+
+type Item struct {
+	ID      int64              `rod:"_id"`
+	Label   *string            `rod:"Label,omitempty"`
+	Weights map[string]float64 `rod:"Weights"`
+	Flags   map[bool]Flag      `rod:"Flags,omitempty"`
+}
+
+type Path []string
+
+type Flag uint16
@@ -0,0 +1,23 @@
+// Package testutil contains helpers shared by the tests of the packages that
+// convert between ROD and other formats.
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// Decode decodes the ROD document s with annotations enabled, failing the test
+// if s cannot be decoded.
+func Decode(t testing.TB, s string) any {
+	t.Helper()
+	d := rod.NewDecoder(strings.NewReader(s))
+	d.SetAnnotations(true)
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("decode %s: %s", s, err)
+	}
+	return v
+}
@@ -3,6 +3,7 @@ package rod
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -20,6 +21,11 @@ func isHex(r rune) bool {
 	return isDigit(r) || ('A' <= r && r <= 'F') || ('a' <= r && r <= 'f')
 }
 
+// Whether a rune may be used to indent the lines of a block string.
+func isIndent(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
 // Whether a rune is a unicode letter or underscore.
 func isLetter(r rune) bool {
 	return unicode.IsLetter(r) || r == '_'
@@ -47,10 +53,19 @@ const (
 	rPos             rune   = '+'
 	rNeg             rune   = '-'
 	rDecimal         rune   = '.'
+	rExponent        rune   = 'e'
+	rExponentAlt     rune   = 'E'
 	rString          rune   = '"'
+	rBlockString     string = `"""`
 	rEscape          rune   = '\\'
 	rEscapeLF        rune   = 'n'
 	rEscapeCR        rune   = 'r'
+	rEscapeTab       rune   = 't'
+	rEscapeNull      rune   = '0'
+	rEscapeUnicode   rune   = 'u'
+	rEscapeASCII     rune   = 'x'
+	rEscapeOpen      rune   = '{'
+	rEscapeClose     rune   = '}'
 	rBlob            rune   = '|'
 	rSep             rune   = ','
 	rAssoc           rune   = ':'
@@ -60,6 +75,8 @@ const (
 	rMapClose        rune   = ')'
 	rStructOpen      rune   = '{'
 	rStructClose     rune   = '}'
+	rAnchor          rune   = '&'
+	rReference       rune   = '*'
 )
 
 // Indicates the type of token emitted.
@@ -84,8 +101,8 @@ const (
 	tPos           // rPos
 	tNeg           // rNeg
 	tInteger       // isDigit+
-	tFloat         // isDigit+ rDecimal isDigit+
-	tString        // rString ... rString
+	tFloat         // isDigit+ rDecimal isDigit+ [rExponent [rPos|rNeg] isDigit+]
+	tString        // rString ... rString | rBlockString ... rBlockString
 	tBlob          // rBlob
 	tByte          // isHex isHex
 	tSep           // rSep
@@ -96,6 +113,8 @@ const (
 	tMapClose      // rMapClose
 	tStructOpen    // rStructOpen
 	tStructClose   // rStructClose
+	tAnchor        // rAnchor isDigit+
+	tReference     // rReference isDigit+
 )
 
 // Returns a string representation of the token.
@@ -157,6 +176,10 @@ func (t tokenType) String() string {
 		return "StructOpen"
 	case tStructClose:
 		return "StructClose"
+	case tAnchor:
+		return "Anchor"
+	case tReference:
+		return "Reference"
 	}
 }
 
@@ -510,18 +533,63 @@ func lexValue(l *lexer) state {
 	switch {
 	case switchPrimitive(l):
 		return l.pop()
+	case switchComposite(l):
+		return l.pop()
+	case l.r.IsRune(rAnchor):
+		if !lexLabel(l, tAnchor) {
+			return nil
+		}
+		return l.do(lexSpace, lexComposite)
+	case l.r.IsRune(rReference):
+		if !lexLabel(l, tReference) {
+			return nil
+		}
+		return l.pop()
+	default:
+		return l.expected("value")
+	}
+}
+
+// Scans for a composite.
+func lexComposite(l *lexer) state {
+	switch {
+	case switchComposite(l):
+		return l.pop()
+	default:
+		return l.expected("composite value")
+	}
+}
+
+// Used as a switch case to scan for an optional composite.
+func switchComposite(l *lexer) bool {
+	switch {
 	case l.r.IsRune(rArrayOpen):
 		l.emit(tArrayOpen)
-		return l.do(lexSpace, lexElement)
+		l.push(lexSpace, lexElement)
+		return true
 	case l.r.IsRune(rMapOpen):
 		l.emit(tMapOpen)
-		return l.do(lexSpace, lexEntry)
+		l.push(lexSpace, lexEntry)
+		return true
 	case l.r.IsRune(rStructOpen):
 		l.emit(tStructOpen)
-		return l.do(lexSpace, lexField)
+		l.push(lexSpace, lexField)
+		return true
 	default:
-		return l.expected("value")
+		return false
+	}
+}
+
+// Scans the digits of an anchor or reference label, emitting a token of type
+// t. Returns false if an error was emitted.
+func lexLabel(l *lexer, t tokenType) bool {
+	if !isDigit(l.r.Peek()) {
+		l.expected("digit")
+		return false
 	}
+	l.r.IsAny(isDigit)
+	l.emit(t)
+	return true
 }
 
 // Scans for a primitive.
@@ -545,6 +613,9 @@ func switchPrimitive(l *lexer) bool {
 		l.emit(tNeg)
 		l.push(lexNumber)
 		return true
+	case l.r.Is(rBlockString):
+		l.push(lexBlockString)
+		return true
 	case l.r.IsRune(rString):
 		l.push(lexString)
 		return true
@@ -590,6 +661,15 @@ func lexNumber(l *lexer) state {
 		if l.empty() {
 			return l.expected("digit")
 		}
+		if l.r.IsRune(rExponent) || l.r.IsRune(rExponentAlt) {
+			if !l.r.IsRune(rPos) {
+				l.r.IsRune(rNeg)
+			}
+			if !isDigit(l.r.Peek()) {
+				return l.expected("digit")
+			}
+			l.r.IsAny(isDigit)
+		}
 		l.emit(tFloat)
 		return l.pop()
 	}
@@ -602,7 +682,9 @@ func lexString(l *lexer) state {
 	for {
 		switch l.r.MustNext() {
 		case rEscape:
-			l.r.MustNext()
+			if !lexEscape(l) {
+				return nil
+			}
 		case rString:
 			l.emit(tString)
 			return l.pop()
@@ -612,6 +694,80 @@ func lexString(l *lexer) state {
 	}
 }
 
+// Scans the rest of a block string.
+func lexBlockString(l *lexer) state {
+	for {
+		switch l.r.MustNext() {
+		case rEscape:
+			if !lexEscape(l) {
+				return nil
+			}
+		case rString:
+			if l.r.Is(rBlockString[1:]) {
+				l.emit(tString)
+				return l.pop()
+			}
+		case -1:
+			return l.expected("%#q", rBlockString)
+		}
+	}
+}
+
+// Scans the portion of an escape sequence following the escape character.
+// Returns false if an error was emitted.
+func lexEscape(l *lexer) bool {
+	switch l.r.MustNext() {
+	case rEscape, rString, rEscapeLF, rEscapeCR, rEscapeTab, rEscapeNull:
+		return true
+	case rEscapeUnicode:
+		return lexCodePoint(l, 1, 6, unicode.MaxRune)
+	case rEscapeASCII:
+		return lexCodePoint(l, 2, 2, unicode.MaxASCII)
+	default:
+		l.expected("escape sequence")
+		return false
+	}
+}
+
+// Scans the braced hexadecimal portion of a code point escape, which must have
+// between min and max digits, and must not exceed limit. Returns false if an
+// error was emitted.
+func lexCodePoint(l *lexer, min, max int, limit rune) bool {
+	if !l.r.IsRune(rEscapeOpen) {
+		l.expected("%q", rEscapeOpen)
+		return false
+	}
+	if !isHex(l.r.Peek()) {
+		l.expected("hexadecimal digit")
+		return false
+	}
+	l.r.IsAny(isHex)
+	b := l.bytes()
+	digits := b[strings.LastIndexByte(b, byte(rEscapeOpen))+1:]
+	if !l.r.IsRune(rEscapeClose) {
+		l.expected("%q", rEscapeClose)
+		return false
+	}
+	switch {
+	case min == max && len(digits) != min:
+		l.expected("%d hexadecimal digits", min)
+		return false
+	case len(digits) < min || len(digits) > max:
+		l.expected("%d to %d hexadecimal digits", min, max)
+		return false
+	}
+	v, _ := strconv.ParseUint(digits, 16, 32)
+	if v > uint64(limit) {
+		l.expected("code point no greater than U+%04X", limit)
+		return false
+	}
+	if !utf8.ValidRune(rune(v)) {
+		l.expected("valid code point")
+		return false
+	}
+	return true
+}
+
 // Scans the rest of a blob.
 func lexBlob(l *lexer) state {
 	switch r := l.r.MustNext(); {
@@ -3,6 +3,7 @@ package rod
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -45,25 +46,43 @@ var testPrimitives = map[string]result{
 	`"back\\slash"`:       {"back\\slash", nil},
 	`"Hello, \"world\"!"`: {"Hello, \"world\"!", nil},
 	`"Hello, world!"`:     {"Hello, world!", nil},
+	`"tab\tnull\0"`:       {"tab\tnull\x00", nil},
+	`"\u{1F600}\u{a0}"`:   {"\U0001F600\u00a0", nil},
+	`"\x{1b}\x{7F}"`:      {"\x1b\x7f", nil},
+	`"\q"`:                {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "escape sequence", Got: "`\"\\q`"}}},
+	`"\u1F600"`:           {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "'{'", Got: "`\"\\u`"}}},
+	`"\u{}"`:              {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "hexadecimal digit", Got: "`\"\\u{`"}}},
+	`"\u{1F600"`:          {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "'}'", Got: "`\"\\u{1F600`"}}},
+	`"\u{D800}"`:          {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "valid code point", Got: "`\"\\u{D800}`"}}},
+	`"\u{110000}"`:        {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "code point no greater than U+10FFFF", Got: "`\"\\u{110000}`"}}},
+	`"\u{0000041}"`:       {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "1 to 6 hexadecimal digits", Got: "`\"\\u{0000041}`"}}},
+	`"\x{1}"`:             {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "2 hexadecimal digits", Got: "`\"\\x{1}`"}}},
+	`"\x{80}"`:            {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "code point no greater than U+007F", Got: "`\"\\x{80}`"}}},
 	`+0.0`:                {_float(0.0), nil},
 	`+0`:                  {_int(0), nil},
 	`+1234.5678`:          {_float(1234.5678), nil},
 	`+12345678`:           {_int(12345678), nil},
 	`+1e3`:                {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "end of file", Got: "'e'"}}},
+	`+1.5e3`:              {_float(1500), nil},
+	`+1.0e`:               {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "`1.0e`"}}},
 	`+e3`:                 {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "'e'"}}},
 	`+inf`:                {math.Inf(1), nil},
 	`+nan`:                {math.NaN(), lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "'n'"}}},
-	`-0.0`:                {_float(-0.0), nil},
+	`-0.0`:                {math.Copysign(0, -1), nil},
 	`-0`:                  {_int(-0), nil},
 	`-1234.5678`:          {_float(-1234.5678), nil},
 	`-12345678`:           {_int(-12345678), nil},
 	`-1e3`:                {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "end of file", Got: "'e'"}}},
+	`-5.0e-324`:           {_float(-5e-324), nil},
 	`-inf`:                {math.Inf(-1), nil},
 	`-nan`:                {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "'n'"}}},
 	`0.0`:                 {_float(0.0), nil},
 	`0`:                   {_int(0), nil},
 	`1234.5678`:           {_float(1234.5678), nil},
 	`12345678`:            {_int(12345678), nil},
+	`1.0e308`:             {_float(1e308), nil},
+	`1.0E+308`:            {_float(1e308), nil},
+	`1.0e-`:               {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "`1.0e-`"}}},
 	`1e3`:                 {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "end of file", Got: "'e'"}}},
 	`<anno> "tation"`:     {"tation", nil},
 	`false`:               {false, nil},
@@ -136,6 +155,11 @@ var testComposites = map[string]result{
 	`{ I : "V" ,X:"X"}`:      {_struct{"I": "V", "X": "X"}, nil},
 	`{ I : "V" ,X:"X",`:      {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "field or '}'", Got: "end of file"}}},
 	`{ I : "V" ,X:"X",}`:     {_struct{"I": "V", "X": "X"}, nil},
+	`[ &1 { } , *1 ]`:        {_array{_struct{}, _struct{}}, nil},
+	`[ &1 [ "V" ] , *1 ]`:    {_array{_array{"V"}, _array{"V"}}, nil},
+	`[ & { } ]`:              {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "digit", Got: "'&'"}}},
+	`[ &1 "V" ]`:             {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "composite value", Got: "'\"'"}}},
+	`( *1 : "V" )`:           {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "primitive value", Got: "'*'"}}},
 }
 
 var testExtra = map[string]result{
@@ -168,6 +192,20 @@ var testExtra = map[string]result{
 	"\"\\n\"":    {"\n", nil},
 	"\"\\r\\n\"": {"\r\n", nil},
 	"\"\\r\"":    {"\r", nil},
+
+	"\"\"\"\n\tA\n\t\"\"\"":                  {"A", nil},
+	"\"\"\"\r\n\tA\r\n\r\n\t\tB\r\n\t\"\"\"": {"A\n\n\tB", nil},
+	"\"\"\"\n  A\n    B\n  \"\"\"":           {"A\n  B", nil},
+	"\"\"\"\nA\\tB\\\"\"\"\n\"\"\"":          {"A\tB\"\"\"", nil},
+	"\"\"\"\n\n\"\"\"":                       {"", nil},
+	"\"\"\"A\n\"\"\"":                        {nil, errors.New("block string must begin with a newline")},
+	"\"\"\"\nA\"\"\"":                        {nil, errors.New("block string must end on its own line")},
+	"\"\"\"\n\tA\nB\n\t\"\"\"":               {nil, errors.New("block string line 2 is not indented to the closing delimiter")},
+	"\"\"\"\nA":                              {nil, lexerError{Type: "syntax", Err: expectedError{Expected: "`\"\"\"`", Got: "end of file"}}},
+
+	"*1":          {nil, errors.New("reference *1 has no anchor")},
+	"&1 [ *1 ]":   {nil, errors.New("reference *1 refers to an array that contains it")},
+	"[&1{},&1{}]": {nil, errors.New("anchor &1 is defined more than once")},
 }
 
 func keysOf[T any](m map[string]T) []string {
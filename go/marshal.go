@@ -0,0 +1,509 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Marshaler is implemented by types that encode themselves with the primitive
+// methods of an Encoder, such as those generated by "rod gen-marshal".
+//
+// MarshalROD must write exactly one value. The primitive methods are valid
+// only while MarshalROD is called by the Encoder.
+type Marshaler interface {
+	MarshalROD(e *Encoder) error
+}
+
+// Unmarshaler is implemented by types that decode themselves with the
+// primitive methods of a Decoder, such as those generated by "rod
+// gen-marshal".
+//
+// UnmarshalROD must read exactly one value. The primitive methods are valid
+// only while UnmarshalROD is called by the Decoder.
+type Unmarshaler interface {
+	UnmarshalROD(d *Decoder) error
+}
+
+// A composite opened by Encoder.BeginArray, BeginMap, or BeginStruct. The
+// zero value isolates a Marshaler from an enclosing composite.
+type encodeFrame struct {
+	close rune // Closing delimiter of the composite.
+	// Whether the key of a map entry, or the name of a struct field, has been
+	// written without its value.
+	entry bool
+}
+
+// Prepares to write a value within the current composite. primitive reports
+// whether the value is a primitive, which is required for a map key.
+func (e *Encoder) beginValue(primitive bool) error {
+	if e.annotated {
+		e.annotated = false
+		return nil
+	}
+	if len(e.frames) == 0 {
+		return nil
+	}
+	f := &e.frames[len(e.frames)-1]
+	switch f.close {
+	case rArrayClose:
+		e.newline()
+	case rMapClose:
+		if !f.entry {
+			if !primitive {
+				return errors.New("map key must be a primitive")
+			}
+			e.newline()
+		}
+	case rStructClose:
+		if !f.entry {
+			return errors.New("struct value must follow a field")
+		}
+	}
+	return nil
+}
+
+// Completes a value written within the current composite.
+func (e *Encoder) endValue() {
+	if len(e.frames) == 0 {
+		return
+	}
+	f := &e.frames[len(e.frames)-1]
+	switch f.close {
+	case 0:
+		return
+	case rMapClose:
+		if !f.entry {
+			e.w.WriteRune(rAssoc)
+			e.w.WriteByte(rSpace)
+			f.entry = true
+			return
+		}
+		f.entry = false
+	case rStructClose:
+		f.entry = false
+	}
+	e.w.WriteRune(rSep)
+}
+
+func (e *Encoder) encodeMarshaler(m Marshaler) error {
+	e.frames = append(e.frames, encodeFrame{})
+	depth := len(e.frames)
+	if err := m.MarshalROD(e); err != nil {
+		return err
+	}
+	if len(e.frames) != depth || e.annotated {
+		return errors.New("MarshalROD did not write a complete value")
+	}
+	e.frames = e.frames[:depth-1]
+	return nil
+}
+
+// WriteNull writes a null value.
+func (e *Encoder) WriteNull() error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	e.encodeNull()
+	e.endValue()
+	return nil
+}
+
+// WriteBool writes a bool value.
+func (e *Encoder) WriteBool(v bool) error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	e.encodeBool(v)
+	e.endValue()
+	return nil
+}
+
+// WriteInt writes an int value.
+func (e *Encoder) WriteInt(v int64) error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	e.encodeInt(v)
+	e.endValue()
+	return nil
+}
+
+// WriteUint writes an int value. Returns an error if v overflows an int64.
+func (e *Encoder) WriteUint(v uint64) error {
+	if v > math.MaxInt64 {
+		return fmt.Errorf("%d overflows int64", v)
+	}
+	return e.WriteInt(int64(v))
+}
+
+// WriteFloat writes a float value.
+func (e *Encoder) WriteFloat(v float64) error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	e.encodeFloat(v)
+	e.endValue()
+	return nil
+}
+
+// WriteString writes a string value.
+func (e *Encoder) WriteString(v string) error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	if err := e.encodeString(v); err != nil {
+		return err
+	}
+	e.endValue()
+	return nil
+}
+
+// WriteBlob writes a blob value.
+func (e *Encoder) WriteBlob(v []byte) error {
+	if err := e.beginValue(true); err != nil {
+		return err
+	}
+	e.encodeBlob(v)
+	e.endValue()
+	return nil
+}
+
+// WriteValue writes v as it would be written by Encode.
+func (e *Encoder) WriteValue(v any) error {
+	primitive := false
+	switch v.(type) {
+	case nil, bool, int64, float64, string, []byte:
+		primitive = true
+	}
+	if err := e.beginValue(primitive); err != nil {
+		return err
+	}
+	if err := e.encodeValue(v); err != nil {
+		return err
+	}
+	e.endValue()
+	return nil
+}
+
+// WriteAnnotation writes an annotation, which applies to the next value
+// written.
+func (e *Encoder) WriteAnnotation(annotation string) error {
+	if strings.ContainsRune(annotation, rAnnotationEnd) {
+		return fmt.Errorf("annotation cannot contain %q", rAnnotationEnd)
+	}
+	if e.annotated {
+		return errors.New("cannot annotate an annotated value")
+	}
+	if err := e.beginValue(false); err != nil {
+		return err
+	}
+	e.w.WriteRune(rAnnotation)
+	e.w.WriteString(annotation)
+	e.w.WriteRune(rAnnotationEnd)
+	e.w.WriteByte(rSpace)
+	e.annotated = true
+	return nil
+}
+
+func (e *Encoder) begin(open, close rune) error {
+	if err := e.beginValue(false); err != nil {
+		return err
+	}
+	e.w.WriteRune(open)
+	e.push()
+	e.frames = append(e.frames, encodeFrame{close: close})
+	return nil
+}
+
+func (e *Encoder) end(close rune) error {
+	n := len(e.frames)
+	if n == 0 || e.frames[n-1].close != close {
+		return fmt.Errorf("unexpected %q", close)
+	}
+	if e.frames[n-1].entry {
+		return fmt.Errorf("%q before value of entry", close)
+	}
+	e.frames = e.frames[:n-1]
+	e.pop()
+	e.newline()
+	e.w.WriteRune(close)
+	e.endValue()
+	return nil
+}
+
+// BeginArray begins writing an array. Each value written until EndArray is an
+// element of the array.
+func (e *Encoder) BeginArray() error {
+	return e.begin(rArrayOpen, rArrayClose)
+}
+
+// EndArray ends the array begun by BeginArray.
+func (e *Encoder) EndArray() error {
+	return e.end(rArrayClose)
+}
+
+// BeginMap begins writing a map. The values written until EndMap alternate
+// between the key and value of each entry. Entries are written in the order
+// given, so they must already be sorted to match the output of Encode.
+func (e *Encoder) BeginMap() error {
+	return e.begin(rMapOpen, rMapClose)
+}
+
+// EndMap ends the map begun by BeginMap.
+func (e *Encoder) EndMap() error {
+	return e.end(rMapClose)
+}
+
+// BeginStruct begins writing a struct. Each field is written with WriteField
+// followed by its value, until EndStruct. Fields are written in the order
+// given, so they must already be sorted to match the output of Encode.
+func (e *Encoder) BeginStruct() error {
+	return e.begin(rStructOpen, rStructClose)
+}
+
+// WriteField writes the name of a field of the current struct.
+func (e *Encoder) WriteField(name string) error {
+	n := len(e.frames)
+	if n == 0 || e.frames[n-1].close != rStructClose || e.frames[n-1].entry {
+		return errors.New("field must be within a struct")
+	}
+	e.newline()
+	if err := e.encodeIdent(name); err != nil {
+		return err
+	}
+	e.w.WriteRune(rAssoc)
+	e.w.WriteByte(rSpace)
+	e.frames[n-1].entry = true
+	return nil
+}
+
+// EndStruct ends the struct begun by BeginStruct.
+func (e *Encoder) EndStruct() error {
+	return e.end(rStructClose)
+}
+
+// A composite opened by Decoder.BeginArray, BeginMap, or BeginStruct.
+type decodeFrame struct {
+	close tokenType // Closing token of the composite.
+	n     int       // Number of entries begun.
+	key   bool      // Whether the key of a map entry is being read.
+}
+
+// Completes a value read within the current composite.
+func (d *Decoder) endValue() error {
+	if n := len(d.frames); n > 0 && d.frames[n-1].key {
+		d.frames[n-1].key = false
+		return d.expectToken(tAssoc)
+	}
+	return nil
+}
+
+// Reads the next value as by ReadValue, without its annotation.
+func (d *Decoder) readPrimitive() (any, error) {
+	var v any
+	if err := d.ReadValue(&v); err != nil {
+		return nil, err
+	}
+	return unannotate(v), nil
+}
+
+// ReadValue reads the next value into v, as it would be decoded by Decode.
+func (d *Decoder) ReadValue(v *any) error {
+	if err := d.decodeValue(v); err != nil {
+		return err
+	}
+	return d.endValue()
+}
+
+// ReadNull reads the next value if it is null, and reports whether it was
+// read. Otherwise, the value remains to be read.
+func (d *Decoder) ReadNull() (bool, error) {
+	for {
+		t, err := d.nextToken()
+		if err != nil {
+			return false, err
+		}
+		switch t.Type {
+		case tAnnotation:
+			continue
+		case tNull:
+			return true, d.endValue()
+		}
+		d.next = t
+		return false, nil
+	}
+}
+
+// ReadBool reads a bool value.
+func (d *Decoder) ReadBool() (bool, error) {
+	v, err := d.readPrimitive()
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected bool, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+// ReadInt reads an int value.
+func (d *Decoder) ReadInt() (int64, error) {
+	v, err := d.readPrimitive()
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expected int, got %s", typeName(v))
+	}
+	return i, nil
+}
+
+// ReadUint reads a non-negative int value.
+func (d *Decoder) ReadUint() (uint64, error) {
+	i, err := d.ReadInt()
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("%d overflows uint64", i)
+	}
+	return uint64(i), nil
+}
+
+// ReadFloat reads a float value. An int value is converted to a float.
+func (d *Decoder) ReadFloat() (float64, error) {
+	v, err := d.readPrimitive()
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("expected float, got %s", typeName(v))
+}
+
+// ReadString reads a string value.
+func (d *Decoder) ReadString() (string, error) {
+	v, err := d.readPrimitive()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expected string, got %s", typeName(v))
+	}
+	return s, nil
+}
+
+// ReadBlob reads a blob value.
+func (d *Decoder) ReadBlob() ([]byte, error) {
+	v, err := d.readPrimitive()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("expected blob, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+// Begins reading a composite. An anchor labeling the composite is discarded,
+// so a later reference to it cannot be decoded.
+func (d *Decoder) begin(open, close tokenType, kind string) error {
+	for {
+		t, err := d.nextToken()
+		if err != nil {
+			return err
+		}
+		switch t.Type {
+		case tAnnotation, tAnchor:
+			continue
+		case open:
+			d.frames = append(d.frames, decodeFrame{close: close})
+			return nil
+		}
+		d.next = t
+		v, err := d.readPrimitive()
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("expected %s, got %s", kind, typeName(v))
+	}
+}
+
+// BeginArray begins reading an array. Each element is read after More reports
+// true.
+func (d *Decoder) BeginArray() error {
+	return d.begin(tArrayOpen, tArrayClose, "array")
+}
+
+// BeginMap begins reading a map. After More reports true, the key and then the
+// value of an entry are read.
+func (d *Decoder) BeginMap() error {
+	return d.begin(tMapOpen, tMapClose, "map")
+}
+
+// BeginStruct begins reading a struct. After More reports true, the name of a
+// field is read with ReadField, and then its value.
+func (d *Decoder) BeginStruct() error {
+	return d.begin(tStructOpen, tStructClose, "struct")
+}
+
+// More reports whether the current composite has another entry to be read.
+// Once it reports false, the composite has been read completely.
+func (d *Decoder) More() (bool, error) {
+	n := len(d.frames)
+	if n == 0 {
+		return false, errors.New("no composite is being read")
+	}
+	f := &d.frames[n-1]
+	if f.n > 0 {
+		t, err := d.nextToken()
+		if err != nil {
+			return false, err
+		}
+		switch t.Type {
+		case tSep:
+		case f.close:
+			return false, d.end()
+		default:
+			return false, fmt.Errorf("expected separator, got %s", t.Type)
+		}
+	}
+	if d.ifToken(f.close) {
+		return false, d.end()
+	}
+	f.n++
+	f.key = f.close == tMapClose
+	return true, nil
+}
+
+// Ends the current composite.
+func (d *Decoder) end() error {
+	d.frames = d.frames[:len(d.frames)-1]
+	return d.endValue()
+}
+
+// ReadField reads the name of a field of the current struct.
+func (d *Decoder) ReadField() (string, error) {
+	n := len(d.frames)
+	if n == 0 || d.frames[n-1].close != tStructClose {
+		return "", errors.New("field must be within a struct")
+	}
+	t, err := d.nextToken()
+	if err != nil {
+		return "", err
+	}
+	if t.Type != tIdent {
+		return "", fmt.Errorf("expected field, got %s", t.Type)
+	}
+	return t.Value, d.expectToken(tAssoc)
+}
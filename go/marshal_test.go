@@ -0,0 +1,183 @@
+package rod
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Implements Marshaler and Unmarshaler with functions.
+type funcMarshaler struct {
+	marshal   func(e *Encoder) error
+	unmarshal func(d *Decoder) error
+}
+
+func (m funcMarshaler) MarshalROD(e *Encoder) error   { return m.marshal(e) }
+func (m funcMarshaler) UnmarshalROD(d *Decoder) error { return m.unmarshal(d) }
+
+func TestMarshaler(t *testing.T) {
+	want := _struct{
+		"A": _array{nil, true, _int(-1), 1.5, "s", _blob{1, 2}},
+		"B": _map{_int(1): Annotated{"x", _struct{}}, "k": _array{}},
+		"C": Annotated{"y", _int(2)},
+	}
+	m := funcMarshaler{marshal: func(e *Encoder) error {
+		e.BeginStruct()
+		e.WriteField("A")
+		e.BeginArray()
+		e.WriteNull()
+		e.WriteBool(true)
+		e.WriteInt(-1)
+		e.WriteFloat(1.5)
+		e.WriteString("s")
+		e.WriteBlob([]byte{1, 2})
+		e.EndArray()
+		e.WriteField("B")
+		e.BeginMap()
+		e.WriteUint(1)
+		e.WriteAnnotation("x")
+		e.BeginStruct()
+		e.EndStruct()
+		e.WriteValue("k")
+		e.WriteValue(_array{})
+		e.EndMap()
+		e.WriteField("C")
+		e.WriteAnnotation("y")
+		e.WriteInt(2)
+		return e.EndStruct()
+	}}
+	var got, wantBuf bytes.Buffer
+	if err := NewEncoder(&got).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	NewEncoder(&wantBuf).Encode(want)
+	if got.String() != wantBuf.String() {
+		t.Errorf("expected:\n%s\ngot:\n%s", wantBuf.String(), got.String())
+	}
+
+	for name, f := range map[string]func(e *Encoder) error{
+		"composite key": func(e *Encoder) error {
+			e.BeginMap()
+			return e.BeginArray()
+		},
+		"annotated key": func(e *Encoder) error {
+			e.BeginMap()
+			return e.WriteAnnotation("a")
+		},
+		"value without field": func(e *Encoder) error {
+			e.BeginStruct()
+			return e.WriteInt(1)
+		},
+		"field outside struct": func(e *Encoder) error {
+			return e.WriteField("A")
+		},
+		"invalid field": func(e *Encoder) error {
+			e.BeginStruct()
+			return e.WriteField("1")
+		},
+		"mismatched end": func(e *Encoder) error {
+			e.BeginArray()
+			return e.EndMap()
+		},
+		"end before value": func(e *Encoder) error {
+			e.BeginMap()
+			e.WriteInt(1)
+			return e.EndMap()
+		},
+		"incomplete": func(e *Encoder) error {
+			return e.BeginArray()
+		},
+		"dangling annotation": func(e *Encoder) error {
+			return e.WriteAnnotation("a")
+		},
+		"overflow": func(e *Encoder) error {
+			return e.WriteUint(1 << 63)
+		},
+	} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(funcMarshaler{marshal: f}); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if buf.Len() != 0 {
+			t.Errorf("%s: wrote %q", name, buf.String())
+		}
+	}
+}
+
+func TestUnmarshaler(t *testing.T) {
+	var got []any
+	u := funcMarshaler{unmarshal: func(d *Decoder) error {
+		got = nil
+		if err := d.BeginMap(); err != nil {
+			return err
+		}
+		for {
+			more, err := d.More()
+			if err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+			k, err := d.ReadString()
+			if err != nil {
+				return err
+			}
+			got = append(got, k)
+			if null, err := d.ReadNull(); err != nil {
+				return err
+			} else if null {
+				got = append(got, nil)
+				continue
+			}
+			if err := d.BeginStruct(); err != nil {
+				return err
+			}
+			for {
+				more, err := d.More()
+				if err != nil {
+					return err
+				}
+				if !more {
+					break
+				}
+				f, err := d.ReadField()
+				if err != nil {
+					return err
+				}
+				v, err := d.ReadFloat()
+				if err != nil {
+					return err
+				}
+				got = append(got, f, v)
+			}
+		}
+	}}
+	err := NewDecoder(strings.NewReader(`("a": {X: 1, Y: 2.5}, "b": <n> null, "c": {},)`)).Decode(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := _array{"a", "X", 1.0, "Y", 2.5, "b", nil, "c"}
+	if !Equal(_array(got), want) {
+		t.Errorf("expected %s, got %s", formatInline(want), formatInline(_array(got)))
+	}
+
+	for s, msg := range map[string]string{
+		`("a": {X: "1"})`: "expected float, got string",
+		`(1: null)`:       "expected string, got int",
+		`[]`:              "expected map, got array",
+		`("a": null) 1`:   "",
+	} {
+		if err := NewDecoder(strings.NewReader(s)).Decode(u); err == nil {
+			t.Errorf("%s: expected error", s)
+		} else if msg != "" && err.Error() != msg {
+			t.Errorf("%s: expected error %q, got %q", s, msg, err)
+		}
+	}
+
+	incomplete := funcMarshaler{unmarshal: func(d *Decoder) error {
+		return d.BeginArray()
+	}}
+	if err := NewDecoder(strings.NewReader(`[]`)).Decode(incomplete); err == nil {
+		t.Error("expected error for incomplete value")
+	}
+}
@@ -0,0 +1,291 @@
+package rod
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Keywords of annotations that are interpreted as matchers.
+const (
+	matchAny       = "any"
+	matchRegex     = "regex"
+	matchApprox    = "approx"
+	matchUnordered = "unordered"
+)
+
+// An expectation described by an annotation.
+type matcher struct {
+	kind      string
+	pattern   *regexp.Regexp // For matchRegex.
+	tolerance float64        // For matchApprox.
+}
+
+// Match compares the expected value against the actual value, and returns the
+// differences. It is like Diff, except that annotations within expected are
+// interpreted as matchers, which describe what the actual value must look like:
+//
+//	<any> value
+//		Matches any actual value. The annotated value is a placeholder.
+//	<regex "pattern"> "string"
+//		Matches an actual string that matches the regular expression. The
+//		annotated string is a placeholder.
+//	<approx tolerance> value
+//		Matches an actual value like the annotated value, except that floats
+//		within the value may differ by no more than tolerance.
+//	<unordered> [...]
+//		Matches an actual array that has the same elements as the annotated
+//		array, in any order.
+//
+// Other annotations, and annotations within actual, are ignored. expected is
+// usually decoded with annotations enabled. An error is returned if a matcher
+// is malformed.
+func Match(expected, actual any, opts ...Option) ([]Difference, error) {
+	c := newComparer(opts)
+	c.matchers = map[string]*matcher{}
+	if err := c.compileMatchers(nil, expected, map[identity]bool{}); err != nil {
+		return nil, err
+	}
+	c.diffValue(nil, expected, actual)
+	return c.diffs, nil
+}
+
+// Parses the matcher of each annotation within v.
+func (c *comparer) compileMatchers(path Path, v any, seen map[identity]bool) error {
+	if x, ok := v.(Annotated); ok {
+		m, err := parseMatcher(x.Annotation)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if m != nil {
+			if _, ok := x.Value.([]any); m.kind == matchUnordered && !ok {
+				return fmt.Errorf("%s: %s matcher must annotate an array", path, matchUnordered)
+			}
+			c.matchers[x.Annotation] = m
+		}
+		v = x.Value
+	}
+	if id, ok := identityOf(v); ok {
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+	}
+	switch v := v.(type) {
+	case []any:
+		for i, v := range v {
+			if err := c.compileMatchers(path.with(Index(i)), v, seen); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		return mapForEach(v, func(k, v any) error {
+			return c.compileMatchers(path.with(Key{k}), v, seen)
+		})
+	case map[string]any:
+		return structForEach(v, func(k string, v any) error {
+			return c.compileMatchers(path.with(Field(k)), v, seen)
+		})
+	}
+	return nil
+}
+
+// Parses an annotation as a matcher. Returns nil if the annotation is not a
+// matcher.
+func parseMatcher(annotation string) (*matcher, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(annotation), " ")
+	arg = strings.TrimSpace(arg)
+	m := matcher{kind: kind}
+	switch kind {
+	case matchAny, matchUnordered:
+		if arg != "" {
+			return nil, fmt.Errorf("%s matcher has unexpected argument", kind)
+		}
+	case matchRegex:
+		v, err := decodeArgument(kind, arg)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s matcher requires a string", kind)
+		}
+		if m.pattern, err = regexp.Compile(s); err != nil {
+			return nil, fmt.Errorf("%s matcher: %w", kind, err)
+		}
+	case matchApprox:
+		v, err := decodeArgument(kind, arg)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case float64:
+			m.tolerance = v
+		case int64:
+			m.tolerance = float64(v)
+		}
+		if !(m.tolerance > 0) {
+			return nil, fmt.Errorf("%s matcher requires a positive number", kind)
+		}
+	default:
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// Decodes the argument of a matcher as a ROD value.
+func decodeArgument(kind, arg string) (v any, err error) {
+	if arg == "" {
+		return nil, fmt.Errorf("%s matcher requires an argument", kind)
+	}
+	if err := NewDecoder(strings.NewReader(arg)).Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s matcher argument: %w", kind, err)
+	}
+	return v, nil
+}
+
+// Returns the matcher of v if v is annotated with one.
+func (c *comparer) matcherOf(v any) (m *matcher, x Annotated) {
+	if c.matchers == nil {
+		return nil, x
+	}
+	x, ok := v.(Annotated)
+	if !ok {
+		return nil, x
+	}
+	return c.matchers[x.Annotation], x
+}
+
+// Reports whether b satisfies the matcher m annotating x.
+func (c *comparer) equalMatcher(m *matcher, x Annotated, b any) bool {
+	switch m.kind {
+	case matchRegex:
+		s, ok := b.(string)
+		return ok && m.pattern.MatchString(s)
+	case matchApprox:
+		defer func(tol float64) { c.absTolerance = tol }(c.absTolerance)
+		c.absTolerance = m.tolerance
+		return c.equal(x.Value, b)
+	case matchUnordered:
+		a := x.Value.([]any)
+		bs, ok := b.([]any)
+		if !ok || len(a) != len(bs) {
+			return false
+		}
+		for _, j := range c.matchUnordered(a, bs) {
+			if j < 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Records the differences between b and the matcher m annotating x.
+func (c *comparer) diffMatcher(path Path, m *matcher, x Annotated, b any) {
+	switch m.kind {
+	case matchRegex:
+		if s, ok := b.(string); !ok {
+			c.add(path, TypeChanged, x, b)
+		} else if !m.pattern.MatchString(s) {
+			c.add(path, Changed, x, b)
+		}
+	case matchApprox:
+		defer func(tol float64) { c.absTolerance = tol }(c.absTolerance)
+		c.absTolerance = m.tolerance
+		c.diffValue(path, x.Value, b)
+	case matchUnordered:
+		a := x.Value.([]any)
+		bs, ok := b.([]any)
+		if !ok {
+			c.add(path, TypeChanged, x, b)
+			return
+		}
+		leave, ok := c.visit(a, bs)
+		if !ok {
+			return
+		}
+		defer leave()
+		c.diffUnordered(path, a, bs)
+	}
+}
+
+// Reannotate returns a copy of actual with the annotations of expected applied
+// at the same locations, so that a document can be regenerated without losing
+// them. Where actual satisfies a matcher of expected, as interpreted by Match,
+// the annotated value of expected is retained instead, so that placeholders are
+// not replaced. Where actual does not satisfy an approx or unordered matcher,
+// the matcher is applied to the actual value. Other unsatisfied matchers are
+// dropped.
+//
+// Values in actual that are shared or cyclic are not copied, and do not
+// receive annotations beyond their first occurrence.
+func Reannotate(expected, actual any, opts ...Option) (any, error) {
+	c := newComparer(opts)
+	c.matchers = map[string]*matcher{}
+	if err := c.compileMatchers(nil, expected, map[identity]bool{}); err != nil {
+		return nil, err
+	}
+	return c.reannotate(expected, unannotate(actual), map[identity]bool{}), nil
+}
+
+func (c *comparer) reannotate(expected, actual any, seen map[identity]bool) any {
+	if m, x := c.matcherOf(expected); m != nil {
+		if c.equalMatcher(m, x, actual) {
+			return x
+		}
+		switch {
+		case m.kind == matchApprox,
+			m.kind == matchUnordered && typeName(actual) == typeName(x.Value):
+			return Annotated{Annotation: x.Annotation, Value: c.reannotate(x.Value, actual, seen)}
+		}
+		return actual
+	}
+	annotation, annotated := annotationOf(expected)
+	expected = unannotate(expected)
+	if id, ok := identityOf(actual); ok {
+		if seen[id] {
+			return actual
+		}
+		seen[id] = true
+	}
+	var v any
+	switch a := actual.(type) {
+	case []any:
+		e, _ := expected.([]any)
+		u := make([]any, len(a))
+		for i, av := range a {
+			var ev any
+			if i < len(e) {
+				ev = e[i]
+			}
+			u[i] = c.reannotate(ev, unannotate(av), seen)
+		}
+		v = u
+	case map[any]any:
+		e, _ := expected.(map[any]any)
+		u := make(map[any]any, len(a))
+		for k, av := range a {
+			k = unannotate(k)
+			ek, ev, _ := lookupUnannotated(e, k)
+			if annotation, ok := annotationOf(ek); ok {
+				k = Annotated{Annotation: annotation, Value: k}
+			}
+			u[k] = c.reannotate(ev, unannotate(av), seen)
+		}
+		v = u
+	case map[string]any:
+		e, _ := expected.(map[string]any)
+		u := make(map[string]any, len(a))
+		for k, av := range a {
+			u[k] = c.reannotate(e[k], unannotate(av), seen)
+		}
+		v = u
+	default:
+		v = actual
+	}
+	if annotated && typeName(expected) == typeName(actual) {
+		return Annotated{Annotation: annotation, Value: v}
+	}
+	return v
+}
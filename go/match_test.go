@@ -0,0 +1,128 @@
+package rod
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func decodeAnnotated(t *testing.T, s string) any {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(s))
+	d.SetAnnotations(true)
+	var v any
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return v
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   any
+		want     []string
+	}{
+		{"any", `{T: <any> null}`, _struct{"T": _array{_int(1)}}, nil},
+		{"regex", `<regex "^tmp/.*"> "tmp/x"`, "tmp/abc", nil},
+		{"regex mismatch", `<regex "^tmp/.*"> "tmp/x"`, "var/abc", []string{`~ .: <regex "^tmp/.*"> "tmp/x" -> "var/abc"`}},
+		{"regex type", `<regex "^tmp/.*"> "tmp/x"`, _int(1), []string{`! .: string <regex "^tmp/.*"> "tmp/x" -> int 1`}},
+		{"approx", `<approx 1.0e-6> [1.0, {A: 2.0}]`, _array{_float(1.0000005), _struct{"A": _float(1.9999995)}}, nil},
+		{"approx int", `<approx 1> 1.0`, _float(1.5), nil},
+		{"approx mismatch", `<approx 1.0e-6> 1.0`, _float(1.1), []string{`~ .: 1.0 -> 1.1`}},
+		{"unordered", `<unordered> [1, 2, 2]`, _array{_int(2), _int(1), _int(2)}, nil},
+		{"unordered nested", `[<unordered> [1, 2], [1, 2]]`, _array{_array{_int(2), _int(1)}, _array{_int(2), _int(1)}}, []string{"+ [1][0]: 2", "- [1][1]: 2"}},
+		{"unordered mismatch", `<unordered> [1, 2]`, _array{_int(2), _int(3)}, []string{"~ [0]: 1 -> 3"}},
+		{"other annotation", `<int32> 1`, _int(1), nil},
+		{"actual annotation", `1`, Annotated{"x", _int(1)}, nil},
+		{"in map", `(1: <any> 0)`, _map{_int(1): "x"}, nil},
+		{"annotated key", `(<int32> 1: "a")`, _map{_int(1): "a"}, nil},
+		{"annotated key diff", `(<int32> 1: "a")`, _map{_int(1): "b"}, []string{`~ (<int32> 1): "a" -> "b"`}},
+		{"in array diff", `[0, <any> 0, <regex "a"> "a"]`, _array{_int(1), "x", "a"}, []string{"~ [0]: 0 -> 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Match(decodeAnnotated(t, tt.expected), tt.actual)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			var got []string
+			for _, d := range diffs {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	tests := map[string]string{
+		`{A: [<any 1> 0]}`:      `.A[0]: any matcher has unexpected argument`,
+		`<regex> ""`:            `.: regex matcher requires an argument`,
+		`<regex 1> ""`:          `.: regex matcher requires a string`,
+		`<regex "(">  ""`:       `.: regex matcher: error parsing regexp: missing closing ): ` + "`(`",
+		`<approx "x"> 1.0`:      `.: approx matcher requires a positive number`,
+		`<approx -1.0> 1.0`:     `.: approx matcher requires a positive number`,
+		`<approx [> 1.0`:        `.: approx matcher argument: `,
+		`("k": <unordered> {})`: `("k"): unordered matcher must annotate an array`,
+	}
+	for expected, want := range tests {
+		_, err := Match(decodeAnnotated(t, expected), nil)
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: expected error %q, got %v", expected, want, err)
+		}
+	}
+}
+
+func TestReannotate(t *testing.T) {
+	expected := decodeAnnotated(t, `{
+		ID: <regex "^[0-9]+$"> "1",
+		Time: <any> "placeholder",
+		Score: <approx 0.01> 1.0,
+		Tags: <unordered> ["a", "b"],
+		Type: <int32> 1,
+		Gone: <any> 0,
+		Keys: (<int32> 1: "a", 2: "b"),
+	}`)
+	actual := _struct{
+		"ID":    "abc",
+		"Time":  "2006-01-02",
+		"Score": _float(1.5),
+		"Tags":  _array{"b", "a"},
+		"Type":  _int(2),
+		"New":   _int(3),
+		"Keys":  _map{_int(1): "x", _int(3): "y"},
+	}
+	v, err := Reannotate(expected, actual)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("%s", err)
+	}
+	want := `{
+	ID: "abc",
+	Keys: (
+		<int32> 1: "x",
+		3: "y",
+	),
+	New: 3,
+	Score: <approx 0.01> 1.5,
+	Tags: <unordered> [
+		"a",
+		"b",
+	],
+	Time: <any> "placeholder",
+	Type: <int32> 2,
+}`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if diffs, _ := Match(v, actual); len(diffs) > 0 {
+		t.Errorf("expected reannotated value to match, got %v", diffs)
+	}
+}
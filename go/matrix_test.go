@@ -31,6 +31,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tStart}:              false,
 	{tStructOpen, tStart}:            false,
 	{tStructClose, tStart}:           false,
+	{tAnchor, tStart}:                false,
+	{tReference, tStart}:             false,
 	{tStart, tError}:                 true,
 	{tError, tError}:                 false,
 	{tEOF, tError}:                   false,
@@ -60,6 +62,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tError}:              true,
 	{tStructOpen, tError}:            true,
 	{tStructClose, tError}:           true,
+	{tAnchor, tError}:                true,
+	{tReference, tError}:             true,
 	{tStart, tEOF}:                   false,
 	{tError, tEOF}:                   false,
 	{tEOF, tEOF}:                     false,
@@ -89,6 +93,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tEOF}:                true,
 	{tStructOpen, tEOF}:              false,
 	{tStructClose, tEOF}:             true,
+	{tAnchor, tEOF}:                  false,
+	{tReference, tEOF}:               true,
 	{tStart, tInvalid}:               false,
 	{tError, tInvalid}:               false,
 	{tEOF, tInvalid}:                 false,
@@ -118,6 +124,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tInvalid}:            false,
 	{tStructOpen, tInvalid}:          false,
 	{tStructClose, tInvalid}:         false,
+	{tAnchor, tInvalid}:              false,
+	{tReference, tInvalid}:           false,
 	{tStart, tSpace}:                 true,
 	{tError, tSpace}:                 false,
 	{tEOF, tSpace}:                   false,
@@ -147,6 +155,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tSpace}:              true,
 	{tStructOpen, tSpace}:            true,
 	{tStructClose, tSpace}:           true,
+	{tAnchor, tSpace}:                true,
+	{tReference, tSpace}:             true,
 	{tStart, tInlineComment}:         true,
 	{tError, tInlineComment}:         false,
 	{tEOF, tInlineComment}:           false,
@@ -176,6 +186,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tInlineComment}:      true,
 	{tStructOpen, tInlineComment}:    true,
 	{tStructClose, tInlineComment}:   true,
+	{tAnchor, tInlineComment}:        true,
+	{tReference, tInlineComment}:     true,
 	{tStart, tBlockComment}:          true,
 	{tError, tBlockComment}:          false,
 	{tEOF, tBlockComment}:            false,
@@ -205,6 +217,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tBlockComment}:       true,
 	{tStructOpen, tBlockComment}:     true,
 	{tStructClose, tBlockComment}:    true,
+	{tAnchor, tBlockComment}:         true,
+	{tReference, tBlockComment}:      true,
 	{tStart, tAnnotation}:            true,
 	{tError, tAnnotation}:            false,
 	{tEOF, tAnnotation}:              false,
@@ -234,6 +248,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tAnnotation}:         false,
 	{tStructOpen, tAnnotation}:       false,
 	{tStructClose, tAnnotation}:      false,
+	{tAnchor, tAnnotation}:           false,
+	{tReference, tAnnotation}:        false,
 	{tStart, tIdent}:                 false,
 	{tError, tIdent}:                 false,
 	{tEOF, tIdent}:                   false,
@@ -263,6 +279,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tIdent}:              false,
 	{tStructOpen, tIdent}:            true,
 	{tStructClose, tIdent}:           false,
+	{tAnchor, tIdent}:                false,
+	{tReference, tIdent}:             false,
 	{tStart, tNull}:                  true,
 	{tError, tNull}:                  false,
 	{tEOF, tNull}:                    false,
@@ -292,6 +310,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tNull}:               false,
 	{tStructOpen, tNull}:             false,
 	{tStructClose, tNull}:            false,
+	{tAnchor, tNull}:                 false,
+	{tReference, tNull}:              false,
 	{tStart, tTrue}:                  true,
 	{tError, tTrue}:                  false,
 	{tEOF, tTrue}:                    false,
@@ -321,6 +341,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tTrue}:               false,
 	{tStructOpen, tTrue}:             false,
 	{tStructClose, tTrue}:            false,
+	{tAnchor, tTrue}:                 false,
+	{tReference, tTrue}:              false,
 	{tStart, tFalse}:                 true,
 	{tError, tFalse}:                 false,
 	{tEOF, tFalse}:                   false,
@@ -350,6 +372,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tFalse}:              false,
 	{tStructOpen, tFalse}:            false,
 	{tStructClose, tFalse}:           false,
+	{tAnchor, tFalse}:                false,
+	{tReference, tFalse}:             false,
 	{tStart, tInf}:                   true,
 	{tError, tInf}:                   false,
 	{tEOF, tInf}:                     false,
@@ -379,6 +403,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tInf}:                false,
 	{tStructOpen, tInf}:              false,
 	{tStructClose, tInf}:             false,
+	{tAnchor, tInf}:                  false,
+	{tReference, tInf}:               false,
 	{tStart, tNaN}:                   true,
 	{tError, tNaN}:                   false,
 	{tEOF, tNaN}:                     false,
@@ -408,6 +434,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tNaN}:                false,
 	{tStructOpen, tNaN}:              false,
 	{tStructClose, tNaN}:             false,
+	{tAnchor, tNaN}:                  false,
+	{tReference, tNaN}:               false,
 	{tStart, tPos}:                   true,
 	{tError, tPos}:                   false,
 	{tEOF, tPos}:                     false,
@@ -437,6 +465,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tPos}:                false,
 	{tStructOpen, tPos}:              false,
 	{tStructClose, tPos}:             false,
+	{tAnchor, tPos}:                  false,
+	{tReference, tPos}:               false,
 	{tStart, tNeg}:                   true,
 	{tError, tNeg}:                   false,
 	{tEOF, tNeg}:                     false,
@@ -466,6 +496,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tNeg}:                false,
 	{tStructOpen, tNeg}:              false,
 	{tStructClose, tNeg}:             false,
+	{tAnchor, tNeg}:                  false,
+	{tReference, tNeg}:               false,
 	{tStart, tInteger}:               true,
 	{tError, tInteger}:               false,
 	{tEOF, tInteger}:                 false,
@@ -495,6 +527,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tInteger}:            false,
 	{tStructOpen, tInteger}:          false,
 	{tStructClose, tInteger}:         false,
+	{tAnchor, tInteger}:              false,
+	{tReference, tInteger}:           false,
 	{tStart, tFloat}:                 true,
 	{tError, tFloat}:                 false,
 	{tEOF, tFloat}:                   false,
@@ -524,6 +558,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tFloat}:              false,
 	{tStructOpen, tFloat}:            false,
 	{tStructClose, tFloat}:           false,
+	{tAnchor, tFloat}:                false,
+	{tReference, tFloat}:             false,
 	{tStart, tString}:                true,
 	{tError, tString}:                false,
 	{tEOF, tString}:                  false,
@@ -553,6 +589,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tString}:             false,
 	{tStructOpen, tString}:           false,
 	{tStructClose, tString}:          false,
+	{tAnchor, tString}:               false,
+	{tReference, tString}:            false,
 	{tStart, tBlob}:                  true,
 	{tError, tBlob}:                  false,
 	{tEOF, tBlob}:                    false,
@@ -582,6 +620,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tBlob}:               false,
 	{tStructOpen, tBlob}:             false,
 	{tStructClose, tBlob}:            false,
+	{tAnchor, tBlob}:                 false,
+	{tReference, tBlob}:              false,
 	{tStart, tByte}:                  false,
 	{tError, tByte}:                  false,
 	{tEOF, tByte}:                    false,
@@ -611,6 +651,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tByte}:               false,
 	{tStructOpen, tByte}:             false,
 	{tStructClose, tByte}:            false,
+	{tAnchor, tByte}:                 false,
+	{tReference, tByte}:              false,
 	{tStart, tSep}:                   false,
 	{tError, tSep}:                   false,
 	{tEOF, tSep}:                     false,
@@ -640,6 +682,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tSep}:                true,
 	{tStructOpen, tSep}:              false,
 	{tStructClose, tSep}:             true,
+	{tAnchor, tSep}:                  false,
+	{tReference, tSep}:               true,
 	{tStart, tAssoc}:                 false,
 	{tError, tAssoc}:                 false,
 	{tEOF, tAssoc}:                   false,
@@ -669,6 +713,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tAssoc}:              false,
 	{tStructOpen, tAssoc}:            false,
 	{tStructClose, tAssoc}:           false,
+	{tAnchor, tAssoc}:                false,
+	{tReference, tAssoc}:             false,
 	{tStart, tArrayOpen}:             true,
 	{tError, tArrayOpen}:             false,
 	{tEOF, tArrayOpen}:               false,
@@ -698,6 +744,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tArrayOpen}:          false,
 	{tStructOpen, tArrayOpen}:        false,
 	{tStructClose, tArrayOpen}:       false,
+	{tAnchor, tArrayOpen}:            true,
+	{tReference, tArrayOpen}:         false,
 	{tStart, tArrayClose}:            false,
 	{tError, tArrayClose}:            false,
 	{tEOF, tArrayClose}:              false,
@@ -727,6 +775,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tArrayClose}:         true,
 	{tStructOpen, tArrayClose}:       false,
 	{tStructClose, tArrayClose}:      true,
+	{tAnchor, tArrayClose}:           false,
+	{tReference, tArrayClose}:        true,
 	{tStart, tMapOpen}:               true,
 	{tError, tMapOpen}:               false,
 	{tEOF, tMapOpen}:                 false,
@@ -756,6 +806,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tMapOpen}:            false,
 	{tStructOpen, tMapOpen}:          false,
 	{tStructClose, tMapOpen}:         false,
+	{tAnchor, tMapOpen}:              true,
+	{tReference, tMapOpen}:           false,
 	{tStart, tMapClose}:              false,
 	{tError, tMapClose}:              false,
 	{tEOF, tMapClose}:                false,
@@ -785,6 +837,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tMapClose}:           true,
 	{tStructOpen, tMapClose}:         false,
 	{tStructClose, tMapClose}:        true,
+	{tAnchor, tMapClose}:             false,
+	{tReference, tMapClose}:          true,
 	{tStart, tStructOpen}:            true,
 	{tError, tStructOpen}:            false,
 	{tEOF, tStructOpen}:              false,
@@ -814,6 +868,8 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tStructOpen}:         false,
 	{tStructOpen, tStructOpen}:       false,
 	{tStructClose, tStructOpen}:      false,
+	{tAnchor, tStructOpen}:           true,
+	{tReference, tStructOpen}:        false,
 	{tStart, tStructClose}:           false,
 	{tError, tStructClose}:           false,
 	{tEOF, tStructClose}:             false,
@@ -843,4 +899,68 @@ var matrix = map[[2]tokenType]bool{
 	{tMapClose, tStructClose}:        true,
 	{tStructOpen, tStructClose}:      true,
 	{tStructClose, tStructClose}:     true,
+	{tAnchor, tStructClose}:          false,
+	{tReference, tStructClose}:       true,
+	{tStart, tAnchor}:                true,
+	{tError, tAnchor}:                false,
+	{tEOF, tAnchor}:                  false,
+	{tInvalid, tAnchor}:              false,
+	{tSpace, tAnchor}:                true,
+	{tInlineComment, tAnchor}:        true,
+	{tBlockComment, tAnchor}:         true,
+	{tAnnotation, tAnchor}:           true,
+	{tIdent, tAnchor}:                false,
+	{tNull, tAnchor}:                 false,
+	{tTrue, tAnchor}:                 false,
+	{tFalse, tAnchor}:                false,
+	{tInf, tAnchor}:                  false,
+	{tNaN, tAnchor}:                  false,
+	{tPos, tAnchor}:                  false,
+	{tNeg, tAnchor}:                  false,
+	{tInteger, tAnchor}:              false,
+	{tFloat, tAnchor}:                false,
+	{tString, tAnchor}:               false,
+	{tBlob, tAnchor}:                 false,
+	{tByte, tAnchor}:                 false,
+	{tSep, tAnchor}:                  true,
+	{tAssoc, tAnchor}:                true,
+	{tArrayOpen, tAnchor}:            true,
+	{tArrayClose, tAnchor}:           false,
+	{tMapOpen, tAnchor}:              false,
+	{tMapClose, tAnchor}:             false,
+	{tStructOpen, tAnchor}:           false,
+	{tStructClose, tAnchor}:          false,
+	{tAnchor, tAnchor}:               false,
+	{tReference, tAnchor}:            false,
+	{tStart, tReference}:             true,
+	{tError, tReference}:             false,
+	{tEOF, tReference}:               false,
+	{tInvalid, tReference}:           false,
+	{tSpace, tReference}:             true,
+	{tInlineComment, tReference}:     true,
+	{tBlockComment, tReference}:      true,
+	{tAnnotation, tReference}:        true,
+	{tIdent, tReference}:             false,
+	{tNull, tReference}:              false,
+	{tTrue, tReference}:              false,
+	{tFalse, tReference}:             false,
+	{tInf, tReference}:               false,
+	{tNaN, tReference}:               false,
+	{tPos, tReference}:               false,
+	{tNeg, tReference}:               false,
+	{tInteger, tReference}:           false,
+	{tFloat, tReference}:             false,
+	{tString, tReference}:            false,
+	{tBlob, tReference}:              false,
+	{tByte, tReference}:              false,
+	{tSep, tReference}:               true,
+	{tAssoc, tReference}:             true,
+	{tArrayOpen, tReference}:         true,
+	{tArrayClose, tReference}:        false,
+	{tMapOpen, tReference}:           false,
+	{tMapClose, tReference}:          false,
+	{tStructOpen, tReference}:        false,
+	{tStructClose, tReference}:       false,
+	{tAnchor, tReference}:            false,
+	{tReference, tReference}:         false,
 }
@@ -0,0 +1,196 @@
+package rod
+
+import "sort"
+
+// Conflict describes a value that was changed differently by each side of a
+// merge.
+type Conflict struct {
+	// Path locates the value.
+	Path Path
+	// Ours and Theirs describe how each side changed the value of the base.
+	// The Kind of each is Added if the value is absent from the base, and
+	// Removed if the value is absent from the side.
+	Ours, Theirs Difference
+}
+
+// Merge performs a three-way merge of the decoded values ours and theirs,
+// which were each derived from base. A value changed by only one side receives
+// the change, and a value changed identically by both sides is kept. Where
+// both sides changed a value differently, structs and maps are merged by field
+// or key, and arrays of the same length in each document are merged by index.
+// Otherwise, the value is a conflict, and the merged value is taken from ours.
+//
+// Values are compared as by Equal, with the same options. Annotations are a
+// part of the value they annotate, except that a composite with the same
+// annotation in each document is merged by its elements.
+//
+// Merge returns the merged value, and the conflicts ordered by path. The inputs
+// are not modified, but the merged value may share composites with them.
+func Merge(base, ours, theirs any, opts ...Option) (merged any, conflicts []Conflict) {
+	m := merger{
+		c:        newComparer(opts),
+		visiting: map[[3]identity]bool{},
+	}
+	merged, _ = m.merge(nil, base, ours, theirs, true, true, true)
+	return merged, m.conflicts
+}
+
+type merger struct {
+	c         *comparer
+	conflicts []Conflict
+	// Triples of composites currently being merged.
+	visiting map[[3]identity]bool
+}
+
+// Reports whether a and b are equal, where each is present only if its flag is
+// true.
+func (m *merger) same(a, b any, aok, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+	return m.c.equal(a, b)
+}
+
+// Merges values at path, where each is present only if its flag is true.
+// Returns the merged value, and whether it is present.
+func (m *merger) merge(path Path, base, ours, theirs any, bok, ook, tok bool) (any, bool) {
+	switch {
+	case m.same(ours, theirs, ook, tok), m.same(base, theirs, bok, tok):
+		return ours, ook
+	case m.same(base, ours, bok, ook):
+		return theirs, tok
+	case bok && ook && tok:
+		if merged, ok := m.mergeComposite(path, base, ours, theirs); ok {
+			return merged, true
+		}
+	}
+	m.conflicts = append(m.conflicts, Conflict{
+		Path:   path,
+		Ours:   change(path, base, ours, bok, ook),
+		Theirs: change(path, base, theirs, bok, tok),
+	})
+	return ours, ook
+}
+
+// Returns the difference between the base and a side.
+func change(path Path, base, side any, bok, sok bool) Difference {
+	d := Difference{Path: path, A: base, B: side}
+	switch {
+	case !bok:
+		d.Kind = Added
+	case !sok:
+		d.Kind = Removed
+	case typeName(base) != typeName(side):
+		d.Kind = TypeChanged
+	default:
+		d.Kind = Changed
+	}
+	return d
+}
+
+// Merges composites of the same type and annotation by their elements. Returns
+// false if the values cannot be merged this way.
+func (m *merger) mergeComposite(path Path, base, ours, theirs any) (any, bool) {
+	annotation, annotated := annotationOf(ours)
+	for _, v := range []any{base, theirs} {
+		if a, ok := annotationOf(v); a != annotation || ok != annotated {
+			return nil, false
+		}
+	}
+	base, ours, theirs = unannotate(base), unannotate(ours), unannotate(theirs)
+	if typeName(base) != typeName(ours) || typeName(ours) != typeName(theirs) {
+		return nil, false
+	}
+	var triple [3]identity
+	for i, v := range []any{base, ours, theirs} {
+		id, ok := identityOf(v)
+		if !ok {
+			return nil, false
+		}
+		triple[i] = id
+	}
+	if m.visiting[triple] {
+		// The merge already in progress determines the result.
+		return ours, true
+	}
+	m.visiting[triple] = true
+	defer delete(m.visiting, triple)
+
+	var merged any
+	switch o := ours.(type) {
+	case []any:
+		b, t := base.([]any), theirs.([]any)
+		if len(b) != len(o) || len(o) != len(t) {
+			return nil, false
+		}
+		r := make([]any, len(o))
+		for i := range o {
+			r[i], _ = m.merge(path.with(Index(i)), b[i], o[i], t[i], true, true, true)
+		}
+		merged = r
+	case map[any]any:
+		b, t := base.(map[any]any), theirs.(map[any]any)
+		r := make(map[any]any, len(o))
+		for _, k := range unionKeys(b, o, t) {
+			bv, bok := lookupKey(b, k)
+			ov, ook := lookupKey(o, k)
+			tv, tok := lookupKey(t, k)
+			if v, ok := m.merge(path.with(Key{k}), bv, ov, tv, bok, ook, tok); ok {
+				r[k] = v
+			}
+		}
+		merged = r
+	case map[string]any:
+		b, t := base.(map[string]any), theirs.(map[string]any)
+		r := make(map[string]any, len(o))
+		for _, f := range unionFields(b, o, t) {
+			bv, bok := b[f]
+			ov, ook := o[f]
+			tv, tok := t[f]
+			if v, ok := m.merge(path.with(Field(f)), bv, ov, tv, bok, ook, tok); ok {
+				r[f] = v
+			}
+		}
+		merged = r
+	default:
+		return nil, false
+	}
+	if annotated {
+		merged = Annotated{Annotation: annotation, Value: merged}
+	}
+	return merged, true
+}
+
+// Returns the sorted keys present in any of the maps.
+func unionKeys(maps ...map[any]any) []any {
+	var keys []any
+	for i, m := range maps {
+	next:
+		for k := range m {
+			for _, prev := range maps[:i] {
+				if _, ok := lookupKey(prev, k); ok {
+					continue next
+				}
+			}
+			keys = append(keys, k)
+		}
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// Returns the sorted fields present in any of the structs.
+func unionFields(structs ...map[string]any) []string {
+	seen := map[string]bool{}
+	var fields []string
+	for _, s := range structs {
+		for f := range s {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,127 @@
+package rod
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		base, ours, theirs any
+		want               any
+		conflicts          []string
+	}{
+		{_int(1), _int(1), _int(1), _int(1), nil},
+		{_int(1), _int(2), _int(1), _int(2), nil},
+		{_int(1), _int(1), _int(3), _int(3), nil},
+		{_int(1), _int(2), _int(2), _int(2), nil},
+		{_int(1), _int(2), _int(3), _int(2), []string{"."}},
+		{
+			_struct{"A": _int(1), "B": _int(1), "C": _int(1)},
+			_struct{"A": _int(2), "B": _int(1)},
+			_struct{"A": _int(1), "B": _int(3), "C": _int(1), "D": true},
+			_struct{"A": _int(2), "B": _int(3), "D": true},
+			nil,
+		},
+		{
+			_struct{"A": _struct{"X": _int(1), "Y": _int(1)}},
+			_struct{"A": _struct{"X": _int(2), "Y": _int(1)}},
+			_struct{"A": _struct{"X": _int(3), "Y": _int(4)}},
+			_struct{"A": _struct{"X": _int(2), "Y": _int(4)}},
+			[]string{".A.X"},
+		},
+		{
+			_struct{"A": _int(1)},
+			_struct{},
+			_struct{"A": _int(2)},
+			_struct{},
+			[]string{".A"},
+		},
+		{
+			_struct{},
+			_struct{"A": _int(1)},
+			_struct{"A": "1"},
+			_struct{"A": _int(1)},
+			[]string{".A"},
+		},
+		{
+			_map{"k": _int(1), _int(2): _int(1)},
+			_map{"k": _int(2), _int(2): _int(1)},
+			_map{"k": _int(1), _int(2): _int(3), nil: nil},
+			_map{"k": _int(2), _int(2): _int(3), nil: nil},
+			nil,
+		},
+		{
+			_array{_int(1), _int(2), _int(3)},
+			_array{_int(0), _int(2), _int(3)},
+			_array{_int(1), _int(2), _int(4)},
+			_array{_int(0), _int(2), _int(4)},
+			nil,
+		},
+		{
+			_array{_int(1), _int(2)},
+			_array{_int(0), _int(2)},
+			_array{_int(1), _int(2), _int(3)},
+			_array{_int(0), _int(2)},
+			[]string{"."},
+		},
+		{
+			Annotated{"a", _struct{"X": _int(1), "Y": _int(1)}},
+			Annotated{"a", _struct{"X": _int(2), "Y": _int(1)}},
+			Annotated{"a", _struct{"X": _int(1), "Y": _int(2)}},
+			Annotated{"a", _struct{"X": _int(2), "Y": _int(2)}},
+			nil,
+		},
+		{
+			_struct{"X": _int(1), "Y": _int(1)},
+			Annotated{"a", _struct{"X": _int(2), "Y": _int(1)}},
+			_struct{"X": _int(1), "Y": _int(2)},
+			Annotated{"a", _struct{"X": _int(2), "Y": _int(1)}},
+			[]string{"."},
+		},
+	}
+	for _, test := range tests {
+		name := formatInline(_array{test.base, test.ours, test.theirs})
+		got, conflicts := Merge(test.base, test.ours, test.theirs)
+		if !Equal(got, test.want) {
+			t.Errorf("%s: expected %s, got %s", name, formatInline(test.want), formatInline(got))
+		}
+		var paths []string
+		for _, c := range conflicts {
+			paths = append(paths, c.Path.String())
+		}
+		if formatInline(toArray(paths)) != formatInline(toArray(test.conflicts)) {
+			t.Errorf("%s: expected conflicts %v, got %v", name, test.conflicts, paths)
+		}
+	}
+
+	_, conflicts := Merge(_struct{"A": _int(1)}, _struct{}, _struct{"A": _int(2)})
+	want := Conflict{
+		Path:   Path{Field("A")},
+		Ours:   Difference{Path: Path{Field("A")}, Kind: Removed, A: _int(1)},
+		Theirs: Difference{Path: Path{Field("A")}, Kind: Changed, A: _int(1), B: _int(2)},
+	}
+	if len(conflicts) != 1 || conflicts[0].Ours.String() != want.Ours.String() || conflicts[0].Theirs.String() != want.Theirs.String() {
+		t.Errorf("expected %v, got %v", want, conflicts)
+	}
+}
+
+func TestMergeCycle(t *testing.T) {
+	base := _struct{"A": _int(1)}
+	base["Self"] = base
+	ours := _struct{"A": _int(2)}
+	ours["Self"] = ours
+	theirs := _struct{"A": _int(3)}
+	theirs["Self"] = theirs
+	_, conflicts := Merge(base, ours, theirs)
+	if len(conflicts) != 1 || conflicts[0].Path.String() != ".A" {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+}
+
+func toArray(s []string) _array {
+	a := _array{}
+	for _, s := range s {
+		a = append(a, s)
+	}
+	return a
+}
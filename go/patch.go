@@ -0,0 +1,289 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Operations of a patch.
+const (
+	opAdd     = "add"
+	opRemove  = "remove"
+	opReplace = "replace"
+	opMove    = "move"
+	opTest    = "test"
+)
+
+// ErrTestFailed is returned by ApplyPatch when a test operation does not hold.
+var ErrTestFailed = errors.New("test failed")
+
+// MakePatch returns a patch that transforms the decoded value a into b, for use
+// with ApplyPatch. The patch is itself a decoded value: an array of structs,
+// each describing an operation with the following fields:
+//
+//	Op      One of "add", "remove", "replace", "move", or "test".
+//	Path    The location of the operation, formatted as a Path.
+//	From    For "move", the location of the value to move.
+//	Value   For "add", "replace", and "test", the value.
+//
+// Values are compared as by Diff. A value that is removed from one field or
+// key and added to another is moved. Each operation that replaces, removes, or
+// moves a value is preceded by a test of the original value, so that applying
+// the patch fails if the value has since changed.
+//
+// Values within the patch retain their annotations, so an encoded patch should
+// be decoded with annotations enabled. MakePatch returns an empty array if the
+// values are equal.
+func MakePatch(a, b any) []any {
+	diffs := Diff(a, b)
+	orderPatch(diffs)
+	patch := []any{}
+	// Removed values that are moved, by the index of the Added difference
+	// that receives them.
+	moves := map[int]Difference{}
+	moved := make([]bool, len(diffs))
+	for i, d := range diffs {
+		if d.Kind != Removed || hasIndex(d.Path) {
+			continue
+		}
+		for j, e := range diffs {
+			if e.Kind == Added && !hasIndex(e.Path) && !moved[j] && Equal(d.A, e.B) {
+				moves[j] = d
+				moved[i], moved[j] = true, true
+				break
+			}
+		}
+	}
+	for i, d := range diffs {
+		switch {
+		case d.Kind == Added:
+			if from, ok := moves[i]; ok {
+				patch = append(patch,
+					patchOp(opTest, from.Path, from.A),
+					map[string]any{"Op": opMove, "From": from.Path.String(), "Path": d.Path.String()},
+				)
+			} else {
+				patch = append(patch, patchOp(opAdd, d.Path, d.B))
+			}
+		case moved[i]:
+		case d.Kind == Removed:
+			patch = append(patch,
+				patchOp(opTest, d.Path, d.A),
+				map[string]any{"Op": opRemove, "Path": d.Path.String()},
+			)
+		default:
+			patch = append(patch,
+				patchOp(opTest, d.Path, d.A),
+				patchOp(opReplace, d.Path, d.B),
+			)
+		}
+	}
+	return patch
+}
+
+func patchOp(op string, path Path, v any) map[string]any {
+	return map[string]any{"Op": op, "Path": path.String(), "Value": v}
+}
+
+// Reports whether p has an index. Only values outside of arrays are moved, since
+// an index may be shifted by other operations on the array.
+func hasIndex(p Path) bool {
+	for _, s := range p {
+		if _, ok := s.(Index); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the final step of p is an index.
+func isIndex(p Path) bool {
+	if len(p) == 0 {
+		return false
+	}
+	_, ok := p[len(p)-1].(Index)
+	return ok
+}
+
+// Sorts differences so that they can be applied in order. Within each array,
+// changes to existing elements are applied first, then removals from the end,
+// then additions from the start. This keeps each index valid: changes and
+// removals are located by their index in the original array, and additions by
+// their index in the resulting array.
+func orderPatch(diffs []Difference) {
+	// Returns the order in which d is applied among the differences within
+	// an array, where the element of the array is located by the first n steps
+	// of the path of d.
+	phase := func(d Difference, n int) int {
+		switch {
+		case len(d.Path) > n:
+			return 0
+		case d.Kind == Removed:
+			return 1
+		case d.Kind == Added:
+			return 2
+		default:
+			return 0
+		}
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+		for k := 0; k < len(a.Path) && k < len(b.Path); k++ {
+			x, xok := a.Path[k].(Index)
+			y, yok := b.Path[k].(Index)
+			if xok && yok {
+				pa, pb := phase(a, k+1), phase(b, k+1)
+				switch {
+				case pa != pb:
+					return pa < pb
+				case x == y:
+					continue
+				case pa == 1:
+					return x > y
+				default:
+					return x < y
+				}
+			}
+			if c := compareValues(stepValue(a.Path[k]), stepValue(b.Path[k])); c != 0 {
+				return c < 0
+			}
+		}
+		return len(a.Path) < len(b.Path)
+	})
+}
+
+// Returns the field, index, or key of a step.
+func stepValue(s Step) any {
+	switch s := s.(type) {
+	case Field:
+		return string(s)
+	case Index:
+		return int64(s)
+	case Key:
+		return s.Value
+	default:
+		return nil
+	}
+}
+
+// ApplyPatch applies patch, as produced by MakePatch, to the decoded value doc,
+// and returns the result. The operations are applied in order:
+//
+//	add       Sets the value at Path. An index inserts an element into an
+//	          array, and may equal the length of the array to append.
+//	remove    Removes the value at Path.
+//	replace   Replaces the value at Path, which must exist.
+//	move      Removes the value at From, and adds it at Path.
+//	test      Fails unless the value at Path is equal to Value.
+//
+// doc is not modified. If an operation fails, then an error is returned that
+// refers to the operation by its index in patch. A failed test operation
+// returns an error that wraps ErrTestFailed.
+func ApplyPatch(doc, patch any) (any, error) {
+	ops, ok := unannotate(patch).([]any)
+	if !ok {
+		return nil, fmt.Errorf("patch must be an array, got %s", typeName(patch))
+	}
+	doc, err := Apply(doc, func(path Path, v any) (any, bool) { return v, true })
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if doc, err = applyOp(doc, op); err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// Applies a single patch operation to doc.
+func applyOp(doc, op any) (any, error) {
+	fields, ok := unannotate(op).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("operation must be a struct, got %s", typeName(op))
+	}
+	path := func(name string) (Path, error) {
+		s, ok := unannotate(fields[name]).(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", name)
+		}
+		p, err := ParsePath(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return p, nil
+	}
+	name, _ := unannotate(fields["Op"]).(string)
+	p, err := path("Path")
+	if err != nil {
+		return nil, err
+	}
+	value, hasValue := fields["Value"]
+	switch name {
+	case opAdd, opReplace, opTest:
+		if !hasValue {
+			return nil, fmt.Errorf("%s requires Value", name)
+		}
+	}
+	switch name {
+	case opAdd:
+		return insert(doc, p, value)
+	case opRemove:
+		return p.Delete(doc)
+	case opReplace:
+		if _, err := p.Get(doc); err != nil {
+			return nil, err
+		}
+		return p.Set(doc, value)
+	case opMove:
+		from, err := path("From")
+		if err != nil {
+			return nil, err
+		}
+		if len(p) > len(from) && matchPath(from.with(anySteps{}), p) {
+			return nil, fmt.Errorf("cannot move %s into itself", from)
+		}
+		v, err := from.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = from.Delete(doc); err != nil {
+			return nil, err
+		}
+		return insert(doc, p, v)
+	case opTest:
+		v, err := p.Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, value) {
+			return nil, &PathError{Path: p, Err: fmt.Errorf("%w: expected %s, got %s", ErrTestFailed, formatInline(value), formatInline(v))}
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+}
+
+// Sets the value located by p within v to x, inserting x if the final step of p
+// is an index.
+func insert(v any, p Path, x any) (any, error) {
+	if !isIndex(p) {
+		return p.Set(v, x)
+	}
+	return p.update(v, func(parent any, s Step) (any, error) {
+		i := s.(Index)
+		a, ok := parent.([]any)
+		if !ok {
+			_, err := selectStep(parent, s)
+			return nil, err
+		}
+		if i < 0 || int(i) > len(a) {
+			return nil, ErrNotFound
+		}
+		r := make([]any, 0, len(a)+1)
+		r = append(append(append(r, a[:i]...), x), a[i:]...)
+		return r, nil
+	}, x)
+}
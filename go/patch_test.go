@@ -0,0 +1,140 @@
+package rod
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMakePatch(t *testing.T) {
+	tests := []struct{ a, b any }{
+		{_int(1), _int(1)},
+		{_int(1), "1"},
+		{_struct{"A": _int(1)}, _struct{"A": _int(2), "B": _int(3)}},
+		{_struct{"Old": _array{_int(1)}, "C": true}, _struct{"New": _array{_int(1)}, "C": true}},
+		{_map{"x": _int(1), math.NaN(): _int(2)}, _map{math.NaN(): _int(3), _int(1): nil}},
+		{_array{_int(1), _int(2), _int(3)}, _array{_int(0), _int(1), _int(3), _int(4)}},
+		{_array{_int(1), _int(2), _int(3), _int(4)}, _array{_int(4), _int(3), _int(2), _int(1)}},
+		{_array{_int(1), _int(2), _int(3)}, _array{}},
+		{_array{}, _array{_int(1), _int(2)}},
+		{
+			_array{_struct{"A": _array{_int(1), _int(2)}}, _int(5), _struct{"B": _int(1)}},
+			_array{_int(6), _struct{"A": _array{_int(2)}}, _struct{"B": _int(2)}, _int(7)},
+		},
+		{
+			_struct{"A": _struct{"X": _blob{0x01}}, "B": _struct{}},
+			_struct{"A": _struct{}, "B": _struct{"Y": _blob{0x01}}},
+		},
+		{Annotated{"a", _int(1)}, Annotated{"b", _int(1)}},
+		{
+			_struct{"M": _map{Annotated{"int32", _int(1)}: "a", Annotated{"int32", _int(2)}: "b"}},
+			_struct{"M": _map{Annotated{"int32", _int(1)}: "c", Annotated{"int32", _int(3)}: "b"}},
+		},
+	}
+	for _, test := range tests {
+		a := formatInline(test.a)
+		patch := MakePatch(test.a, test.b)
+
+		// The patch survives encoding.
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(patch); err != nil {
+			t.Errorf("%s -> %s: encode: %s", a, formatInline(test.b), err)
+			continue
+		}
+		var decoded any
+		d := NewDecoder(&buf)
+		d.SetAnnotations(true)
+		if err := d.Decode(&decoded); err != nil {
+			t.Errorf("%s -> %s: decode: %s", a, formatInline(test.b), err)
+			continue
+		}
+
+		got, err := ApplyPatch(test.a, decoded)
+		if err != nil {
+			t.Errorf("%s -> %s: %s\npatch: %s", a, formatInline(test.b), err, formatInline(patch))
+			continue
+		}
+		if !Equal(got, test.b) {
+			t.Errorf("%s -> %s: got %s\npatch: %s", a, formatInline(test.b), formatInline(got), formatInline(patch))
+		}
+		if formatInline(test.a) != a {
+			t.Errorf("%s: modified to %s", a, formatInline(test.a))
+		}
+	}
+
+	patch := MakePatch(_struct{"A": _int(1)}, _struct{"B": _int(1)})
+	want := _array{
+		_struct{"Op": "test", "Path": ".A", "Value": _int(1)},
+		_struct{"Op": "move", "From": ".A", "Path": ".B"},
+	}
+	if !Equal(_array(patch), want) {
+		t.Errorf("expected %s, got %s", formatInline(want), formatInline(_array(patch)))
+	}
+	if patch := MakePatch(_int(1), _int(1)); patch == nil || len(patch) != 0 {
+		t.Errorf("expected empty patch, got %v", patch)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	var patch any
+	NewDecoder(strings.NewReader(`[
+		{Op: "add", Path: ".Items[0]", Value: "first"},
+		{Op: "add", Path: ".Items[3]", Value: "last"},
+		{Op: "replace", Path: ".Name", Value: "new"},
+		{Op: "remove", Path: ".Extra"},
+		{Op: "move", From: ".Items[1]", Path: ".Moved"},
+		{Op: "test", Path: ".Moved", Value: "a"},
+		{Op: "add", Path: ".Table(1)", Value: true},
+	]`)).Decode(&patch)
+	doc := _struct{
+		"Name":  "old",
+		"Extra": nil,
+		"Items": _array{"a", "b"},
+		"Table": _map{},
+	}
+	got, err := ApplyPatch(doc, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := _struct{
+		"Name":  "new",
+		"Items": _array{"first", "b", "last"},
+		"Moved": "a",
+		"Table": _map{_int(1): true},
+	}
+	if !Equal(got, want) {
+		t.Errorf("expected %s, got %s", formatInline(want), formatInline(got))
+	}
+
+	base := _struct{"A": _int(1), "B": _int(2)}
+	patch = MakePatch(base, _struct{"A": _int(3), "B": _int(2)})
+	_, err = ApplyPatch(_struct{"A": _int(2), "B": _int(2)}, patch)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("expected ErrTestFailed, got %v", err)
+	}
+	if err != nil && err.Error() != "patch operation 0: .A: test failed: expected 1, got 2" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, s := range []string{
+		`{}`,
+		`[1]`,
+		`[{Op: "copy", Path: "."}]`,
+		`[{Op: "add", Path: ".A"}]`,
+		`[{Op: "add", Path: "A", Value: 1}]`,
+		`[{Op: "add", Path: ".B[1]", Value: 1}]`,
+		`[{Op: "add", Path: ".A.X", Value: 1}]`,
+		`[{Op: "remove", Path: ".X"}]`,
+		`[{Op: "replace", Path: ".X", Value: 1}]`,
+		`[{Op: "move", Path: ".X"}]`,
+		`[{Op: "move", From: ".B", Path: ".B[0]"}]`,
+		`[{Op: "test", Path: ".A", Value: 2}]`,
+	} {
+		NewDecoder(strings.NewReader(s)).Decode(&patch)
+		if _, err := ApplyPatch(_struct{"A": _int(1), "B": _array{}}, patch); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
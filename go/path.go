@@ -0,0 +1,708 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Path locates a value within a document, as a sequence of steps from the root
+// value. Paths are formatted and parsed using ROD syntax:
+//
+//	.Field      Field of a struct.
+//	[3]         Element of an array.
+//	(42)        Entry of a map, with the key as a primitive literal, such as
+//	            ("k"), (true), (-1.5), or (|00 01|).
+//
+// For example, ".Blobs(3)[2]" locates the third element of the array at key 3
+// of the map at field Blobs of the root struct. An empty path refers to the
+// root value, and is formatted as ".".
+type Path []Step
+
+// Step is a single step of a Path. It is one of Field, Index, or Key.
+type Step interface {
+	appendStep(b *strings.Builder)
+}
+
+// Field selects a field of a struct by name.
+type Field string
+
+// Index selects an element of an array by position.
+type Index int
+
+// Key selects an entry of a map by key, which is a primitive value. If the map
+// has no key equal to Value, a key equal to Value without annotations is
+// selected instead.
+type Key struct {
+	Value any
+}
+
+func (s Field) appendStep(b *strings.Builder) {
+	b.WriteRune(rDecimal)
+	b.WriteString(string(s))
+}
+
+func (s Index) appendStep(b *strings.Builder) {
+	b.WriteRune(rArrayOpen)
+	b.WriteString(strconv.Itoa(int(s)))
+	b.WriteRune(rArrayClose)
+}
+
+func (s Key) appendStep(b *strings.Builder) {
+	b.WriteRune(rMapOpen)
+	b.WriteString(formatInline(s.Value))
+	b.WriteRune(rMapClose)
+}
+
+// String returns the path formatted in ROD syntax.
+func (p Path) String() string {
+	if len(p) == 0 {
+		return string(rDecimal)
+	}
+	var b strings.Builder
+	for _, s := range p {
+		s.appendStep(&b)
+	}
+	return b.String()
+}
+
+// ParsePath parses a path formatted in ROD syntax.
+func ParsePath(s string) (Path, error) {
+	p, err := parsePath(s, false)
+	if err != nil {
+		return nil, fmt.Errorf("path %q: %w", s, err)
+	}
+	return p, nil
+}
+
+// Returns a copy of p with s appended. The result never shares memory with p.
+func (p Path) with(s Step) Path {
+	q := make(Path, len(p), len(p)+1)
+	copy(q, p)
+	return append(q, s)
+}
+
+// Returns v formatted as ROD on a single line. Composites are formatted
+// compactly, strings never use the block form, and blobs omit the hex dump. A
+// composite that contains itself is elided as "...".
+func formatInline(v any) string {
+	e := Encoder{visiting: map[identity]bool{}}
+	e.encodeInline(v)
+	return e.w.String()
+}
+
+func (e *Encoder) encodeInline(v any) {
+	if id, ok := identityOf(v); ok {
+		if e.visiting[id] {
+			e.w.WriteString("...")
+			return
+		}
+		e.visiting[id] = true
+		defer delete(e.visiting, id)
+	}
+	switch v := v.(type) {
+	case Annotated:
+		e.w.WriteRune(rAnnotation)
+		e.w.WriteString(v.Annotation)
+		e.w.WriteRune(rAnnotationEnd)
+		e.w.WriteByte(rSpace)
+		e.encodeInline(v.Value)
+	case string:
+		e.w.WriteRune(rString)
+		var c Encoder
+		c.encodeStringContent(v, false)
+		// Line feeds are the only characters written literally that would
+		// break the line.
+		e.w.WriteString(strings.ReplaceAll(c.w.String(), "\n", `\n`))
+		e.w.WriteRune(rString)
+	case []byte:
+		e.w.WriteRune(rBlob)
+		for i, b := range v {
+			if i > 0 {
+				e.w.WriteByte(rSpace)
+			}
+			e.w.WriteString(strconv.FormatUint(uint64(b)>>4, 16))
+			e.w.WriteString(strconv.FormatUint(uint64(b)&0xF, 16))
+		}
+		e.w.WriteRune(rBlob)
+	case []any:
+		e.w.WriteRune(rArrayOpen)
+		for i, v := range v {
+			if i > 0 {
+				e.w.WriteRune(rSep)
+				e.w.WriteByte(rSpace)
+			}
+			e.encodeInline(v)
+		}
+		e.w.WriteRune(rArrayClose)
+	case map[any]any:
+		e.w.WriteRune(rMapOpen)
+		i := 0
+		mapForEach(v, func(k, v any) error {
+			if i > 0 {
+				e.w.WriteRune(rSep)
+				e.w.WriteByte(rSpace)
+			}
+			i++
+			e.encodeInline(k)
+			e.w.WriteRune(rAssoc)
+			e.w.WriteByte(rSpace)
+			e.encodeInline(v)
+			return nil
+		})
+		e.w.WriteRune(rMapClose)
+	case map[string]any:
+		e.w.WriteRune(rStructOpen)
+		i := 0
+		structForEach(v, func(k string, v any) error {
+			if i > 0 {
+				e.w.WriteRune(rSep)
+				e.w.WriteByte(rSpace)
+			}
+			i++
+			e.w.WriteString(k)
+			e.w.WriteRune(rAssoc)
+			e.w.WriteByte(rSpace)
+			e.encodeInline(v)
+			return nil
+		})
+		e.w.WriteRune(rStructClose)
+	default:
+		if ok, _ := e.encodePrimitive(v); !ok {
+			e.w.WriteRune(rAnnotation)
+			e.w.WriteString(typeName(v))
+			e.w.WriteRune(rAnnotationEnd)
+		}
+	}
+}
+
+// Returns the name of the ROD type of v, or the Go type if v is not a ROD
+// value. The type of an annotated value is the type of the value.
+func typeName(v any) string {
+	switch v := v.(type) {
+	case Annotated:
+		return typeName(v.Value)
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []byte:
+		return "blob"
+	case []any:
+		return "array"
+	case map[any]any:
+		return "map"
+	case map[string]any:
+		return "struct"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Wildcard steps, which appear only in patterns.
+type (
+	anyField struct{} // Matches any field.
+	anyIndex struct{} // Matches any index.
+	anyKey   struct{} // Matches any key.
+	anySteps struct{} // Matches any number of steps.
+)
+
+const rWildcard = '*'
+
+func (anyField) appendStep(b *strings.Builder) {
+	b.WriteRune(rDecimal)
+	b.WriteRune(rWildcard)
+}
+
+func (anyIndex) appendStep(b *strings.Builder) {
+	b.WriteRune(rArrayOpen)
+	b.WriteRune(rWildcard)
+	b.WriteRune(rArrayClose)
+}
+
+func (anyKey) appendStep(b *strings.Builder) {
+	b.WriteRune(rMapOpen)
+	b.WriteRune(rWildcard)
+	b.WriteRune(rMapClose)
+}
+
+func (anySteps) appendStep(b *strings.Builder) {
+	b.WriteRune(rWildcard)
+	b.WriteRune(rWildcard)
+}
+
+// Parses a path formatted in ROD syntax. If glob is true, then wildcards are
+// permitted.
+func parsePath(s string, glob bool) (Path, error) {
+	if s == string(rDecimal) {
+		return Path{}, nil
+	}
+	if s == "" {
+		return nil, errors.New("empty path")
+	}
+	path := Path{}
+	for i := 0; i < len(s); {
+		switch c := rune(s[i]); {
+		case glob && strings.HasPrefix(s[i:], "**"):
+			path = append(path, anySteps{})
+			i += 2
+		case c == rDecimal:
+			i++
+			if glob && i < len(s) && rune(s[i]) == rWildcard {
+				path = append(path, anyField{})
+				i++
+				break
+			}
+			j := i
+			for j < len(s) {
+				r, n := utf8.DecodeRuneInString(s[j:])
+				if !isIdent(r) {
+					break
+				}
+				j += n
+			}
+			if r, _ := utf8.DecodeRuneInString(s[i:]); j == i || !isLetter(r) {
+				return nil, fmt.Errorf("expected identifier at offset %d", i)
+			}
+			path = append(path, Field(s[i:j]))
+			i = j
+		case c == rArrayOpen:
+			end := strings.IndexRune(s[i:], rArrayClose)
+			if end < 0 {
+				return nil, fmt.Errorf("expected %q at offset %d", rArrayClose, len(s))
+			}
+			inner := s[i+1 : i+end]
+			if glob && inner == string(rWildcard) {
+				path = append(path, anyIndex{})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 || strings.TrimLeftFunc(inner, isDigit) != "" {
+					return nil, fmt.Errorf("expected index at offset %d", i+1)
+				}
+				path = append(path, Index(n))
+			}
+			i += end + 1
+		case c == rMapOpen:
+			end := keyEnd(s, i+1)
+			if end < 0 {
+				return nil, fmt.Errorf("expected %q at offset %d", rMapClose, len(s))
+			}
+			inner := s[i+1 : end]
+			if glob && inner == string(rWildcard) {
+				path = append(path, anyKey{})
+			} else {
+				var k any
+				d := NewDecoder(strings.NewReader(inner))
+				d.SetAnnotations(true)
+				if err := d.Decode(&k); err != nil {
+					return nil, fmt.Errorf("key at offset %d: %w", i+1, err)
+				}
+				if typeIndex(unannotate(k)) == 0 {
+					return nil, fmt.Errorf("key at offset %d must be a primitive", i+1)
+				}
+				path = append(path, Key{k})
+			}
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	return path, nil
+}
+
+// Returns the offset of the map close that ends a key beginning at offset i of
+// s, skipping over strings, blobs, and annotations. Returns -1 if there is
+// none.
+func keyEnd(s string, i int) int {
+	for ; i < len(s); i++ {
+		switch rune(s[i]) {
+		case rMapClose:
+			return i
+		case rString:
+			for i++; i < len(s) && rune(s[i]) != rString; i++ {
+				if rune(s[i]) == rEscape {
+					i++
+				}
+			}
+		case rBlob:
+			for i++; i < len(s) && rune(s[i]) != rBlob; i++ {
+			}
+		case rAnnotation:
+			for i++; i < len(s) && rune(s[i]) != rAnnotationEnd; i++ {
+			}
+		}
+	}
+	return -1
+}
+
+// Reports whether path matches the steps of pattern.
+func matchPath(pattern, path Path) bool {
+	for len(pattern) > 0 {
+		if _, ok := pattern[0].(anySteps); ok {
+			for i := 0; i <= len(path); i++ {
+				if matchPath(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 || !matchStep(pattern[0], path[0]) {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func matchStep(p, s Step) bool {
+	switch p := p.(type) {
+	case anyField:
+		_, ok := s.(Field)
+		return ok
+	case anyIndex:
+		_, ok := s.(Index)
+		return ok
+	case anyKey:
+		_, ok := s.(Key)
+		return ok
+	case Key:
+		k, ok := s.(Key)
+		return ok && Equal(p.Value, k.Value)
+	default:
+		return p == s
+	}
+}
+
+// MatchPattern reports whether path matches the pattern. A pattern is a path
+// formatted in ROD syntax that may contain the following wildcards:
+//
+//	.*     Any field.
+//	[*]    Any index.
+//	(*)    Any key.
+//	**     Any number of steps, including none.
+//
+// For example, the pattern "**.Password" matches a Password field anywhere
+// within a document, while ".Users[*].Name" matches the Name field of each
+// element of the Users array. The pattern "." matches only the root value.
+func MatchPattern(pattern string, path Path) (bool, error) {
+	p, err := parsePath(pattern, true)
+	if err != nil {
+		return false, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	return matchPath(p, path), nil
+}
+
+// ErrNotFound is returned when a path refers to a value that does not exist.
+var ErrNotFound = errors.New("value not found")
+
+// PathError records an error that occurred at a location within a document.
+type PathError struct {
+	Path Path
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Path.String() + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Returns err located at s within a parent value. If err is already a
+// PathError, s is prepended to its path.
+func prependStep(s Step, err error) error {
+	var pe *PathError
+	if errors.As(err, &pe) {
+		pe.Path = append(Path{s}, pe.Path...)
+		return pe
+	}
+	return &PathError{Path: Path{s}, Err: err}
+}
+
+// Returns the element of composite v selected by s. Returns an error if v
+// cannot be selected by s, or if the element does not exist.
+func selectStep(v any, s Step) (any, error) {
+	switch s := s.(type) {
+	case Field:
+		if m, ok := v.(map[string]any); ok {
+			if e, ok := m[string(s)]; ok {
+				return e, nil
+			}
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("cannot select field of %s", typeName(v))
+	case Index:
+		if a, ok := v.([]any); ok {
+			if 0 <= s && int(s) < len(a) {
+				return a[s], nil
+			}
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("cannot select index of %s", typeName(v))
+	case Key:
+		if m, ok := v.(map[any]any); ok {
+			if _, e, ok := findKey(m, s.Value); ok {
+				return e, nil
+			}
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("cannot select key of %s", typeName(v))
+	default:
+		return nil, fmt.Errorf("cannot select %s", Path{s})
+	}
+}
+
+// Get returns the value located by p within v. An annotated composite is
+// traversed as its value, but an annotated value located by p is returned as
+// is. Returns a PathError if the value does not exist.
+func (p Path) Get(v any) (any, error) {
+	for i, s := range p {
+		var err error
+		if v, err = selectStep(unannotate(v), s); err != nil {
+			return nil, &PathError{Path: p[:i+1], Err: err}
+		}
+	}
+	return v, nil
+}
+
+// Set sets the value located by p within v to x, and returns the resulting
+// root value. Composites within v are modified in place, except that a map is
+// copied when the value of a NaN key is replaced, since Go maps cannot replace
+// NaN keys. The final step of p may add a field to a struct, add an entry to a
+// map, or append an element to an array by using an index equal to the length
+// of the array. Otherwise, returns a PathError if the value does not exist. If
+// p is the root, x is returned.
+func (p Path) Set(v, x any) (any, error) {
+	return p.update(v, func(parent any, s Step) (any, error) {
+		switch s := s.(type) {
+		case Field:
+			if m, ok := parent.(map[string]any); ok {
+				m[string(s)] = x
+				return m, nil
+			}
+		case Index:
+			if a, ok := parent.([]any); ok {
+				switch {
+				case 0 <= s && int(s) < len(a):
+					a[s] = x
+					return a, nil
+				case int(s) == len(a):
+					return append(a, x), nil
+				}
+				return nil, ErrNotFound
+			}
+		case Key:
+			if m, ok := parent.(map[any]any); ok {
+				if s.Value != nil && !reflect.TypeOf(s.Value).Comparable() {
+					return nil, fmt.Errorf("cannot use %s as key", typeName(s.Value))
+				}
+				return storeKey(m, resolveKey(m, s.Value), x), nil
+			}
+		}
+		_, err := selectStep(parent, s)
+		return nil, err
+	}, x)
+}
+
+// Delete removes the value located by p within v, and returns the resulting
+// root value. Composites within v are modified in place, except that an array
+// is copied when an element is removed, and a map is copied when a NaN key is
+// removed. Returns a PathError if the value does not exist. If p is the root,
+// nil is returned.
+func (p Path) Delete(v any) (any, error) {
+	return p.update(v, func(parent any, s Step) (any, error) {
+		if _, err := selectStep(parent, s); err != nil {
+			return nil, err
+		}
+		switch s := s.(type) {
+		case Field:
+			m := parent.(map[string]any)
+			delete(m, string(s))
+			return m, nil
+		case Index:
+			a := parent.([]any)
+			r := make([]any, 0, len(a)-1)
+			return append(append(r, a[:s]...), a[s+1:]...), nil
+		case Key:
+			m := parent.(map[any]any)
+			return deleteKey(m, resolveKey(m, s.Value)), nil
+		}
+		return nil, fmt.Errorf("cannot select %s", Path{s})
+	}, nil)
+}
+
+// Applies f to the parent of the value located by p within v, and stores the
+// resulting parent within its own parent. Returns root if p is empty.
+func (p Path) update(v any, f func(parent any, s Step) (any, error), root any) (any, error) {
+	if len(p) == 0 {
+		return root, nil
+	}
+	var set func(v any, i int) (any, error)
+	set = func(v any, i int) (any, error) {
+		if x, ok := v.(Annotated); ok {
+			r, err := set(x.Value, i)
+			return Annotated{Annotation: x.Annotation, Value: r}, err
+		}
+		if i == len(p)-1 {
+			r, err := f(v, p[i])
+			if err != nil {
+				return nil, &PathError{Path: p[:i+1], Err: err}
+			}
+			return r, nil
+		}
+		child, err := selectStep(v, p[i])
+		if err != nil {
+			return nil, &PathError{Path: p[:i+1], Err: err}
+		}
+		child, err = set(child, i+1)
+		if err != nil {
+			return nil, err
+		}
+		// Store the child, which may have been replaced.
+		switch s := p[i].(type) {
+		case Field:
+			v.(map[string]any)[string(s)] = child
+		case Index:
+			v.([]any)[s] = child
+		case Key:
+			m := v.(map[any]any)
+			return storeKey(m, resolveKey(m, s.Value), child), nil
+		}
+		return v, nil
+	}
+	return set(v, 0)
+}
+
+// Returns the key of m selected by a Key step with value k, along with its
+// value. A key equal to k is preferred, and otherwise a key equal to k without
+// annotations is selected, such that a step parsed without the annotation of a
+// key still selects the key.
+func findKey(m map[any]any, k any) (key, v any, ok bool) {
+	if v, ok := lookupKey(m, k); ok {
+		return k, v, true
+	}
+	return lookupUnannotated(m, unannotate(k))
+}
+
+// Returns the key of m selected by a Key step with value k, or k if there is no
+// such key.
+func resolveKey(m map[any]any, k any) any {
+	if key, _, ok := findKey(m, k); ok {
+		return key
+	}
+	return k
+}
+
+// Returns whether k is a NaN key, which cannot be replaced or deleted from a Go
+// map. An annotated NaN is also a NaN key.
+func isNaNKey(k any) bool {
+	f, ok := unannotate(k).(float64)
+	return ok && f != f
+}
+
+// Associates k with v in m, and returns the resulting map. m is modified in
+// place, unless k is NaN, in which case a copy is returned.
+func storeKey(m map[any]any, k, v any) map[any]any {
+	if isNaNKey(k) {
+		m = deleteKey(m, k)
+	}
+	m[k] = v
+	return m
+}
+
+// Removes k from m, and returns the resulting map. m is modified in place,
+// unless k is NaN, in which case a copy without k is returned.
+func deleteKey(m map[any]any, k any) map[any]any {
+	if !isNaNKey(k) {
+		delete(m, k)
+		return m
+	}
+	a, annotated := annotationOf(k)
+	c := make(map[any]any, len(m))
+	for mk, v := range m {
+		if b, ok := annotationOf(mk); !isNaNKey(mk) || b != a || ok != annotated {
+			c[mk] = v
+		}
+	}
+	return c
+}
+
+// Get returns the value located by path within v, as by Path.Get.
+func Get(v any, path string) (any, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(v)
+}
+
+// Set sets the value located by path within v to x, as by Path.Set.
+func Set(v any, path string, x any) (any, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Set(v, x)
+}
+
+// Delete removes the value located by path within v, as by Path.Delete.
+func Delete(v any, path string) (any, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Delete(v)
+}
+
+// GetAs returns the value located by path within v as type T. An annotated
+// value is returned without its annotation. An int may be returned as any
+// integer type that can represent it, and a float may be returned as any
+// float type. Returns an error if the value cannot be represented as T.
+func GetAs[T any](v any, path string) (T, error) {
+	var t T
+	p, err := ParsePath(path)
+	if err != nil {
+		return t, err
+	}
+	x, err := p.Get(v)
+	if err != nil {
+		return t, err
+	}
+	x = unannotate(x)
+	if r, ok := x.(T); ok {
+		return r, nil
+	}
+	rt := reflect.ValueOf(&t).Elem()
+	switch x := x.(type) {
+	case int64:
+		switch rt.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !rt.OverflowInt(x) {
+				rt.SetInt(x)
+				return t, nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if x >= 0 && !rt.OverflowUint(uint64(x)) {
+				rt.SetUint(uint64(x))
+				return t, nil
+			}
+		}
+	case float64:
+		switch rt.Kind() {
+		case reflect.Float32, reflect.Float64:
+			rt.SetFloat(x)
+			return t, nil
+		}
+	}
+	return t, &PathError{Path: p, Err: fmt.Errorf("cannot get %s as %T", typeName(x), t)}
+}
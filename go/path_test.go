@@ -0,0 +1,228 @@
+package rod
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := map[string]Path{
+		`.`:                     {},
+		`.Blobs(3)[2]`:          {Field("Blobs"), Key{_int(3)}, Index(2)},
+		`("k")(|00 01|)(-1.5)`:  {Key{"k"}, Key{_blob{0x00, 0x01}}, Key{_float(-1.5)}},
+		`(true)(null)(nan)`:     {Key{true}, Key{nil}, Key{math.NaN()}},
+		`("a\nb)")._x1`:         {Key{"a\nb)"}, Field("_x1")},
+		`(<int32> 1)(<a)> "x")`: {Key{Annotated{"int32", _int(1)}}, Key{Annotated{"a)", "x"}}},
+	}
+	for s, want := range tests {
+		got, err := ParsePath(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if !Equal(pathValue(got), pathValue(want)) {
+			t.Errorf("%s: expected %s, got %s", s, want, got)
+		}
+		if got.String() != s {
+			t.Errorf("%s: formatted as %s", s, got)
+		}
+	}
+	for _, s := range []string{``, `A`, `.` + `.`, `[*]`, `.*`, `**`, `(*)`, `([])`, `(1`, `[01x]`, `(<a> [])`} {
+		if _, err := ParsePath(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+// Returns p as a value that can be compared.
+func pathValue(p Path) any {
+	a := _array{}
+	for _, s := range p {
+		switch s := s.(type) {
+		case Field:
+			a = append(a, _struct{"Field": string(s)})
+		case Index:
+			a = append(a, _struct{"Index": _int(s)})
+		case Key:
+			a = append(a, _struct{"Key": s.Value})
+		}
+	}
+	return a
+}
+
+func testDocument() any {
+	return _struct{
+		"Users": _array{
+			_struct{"Name": "a", "Age": _int(30)},
+			Annotated{"user", _struct{"Name": "b", "Age": _int(300)}},
+		},
+		"Map":   _map{_int(42): "answer", "k": _blob{1}, _float(math.NaN()): "nan"},
+		"Score": _float(0.5),
+	}
+}
+
+func TestGet(t *testing.T) {
+	v := testDocument()
+	tests := map[string]any{
+		`.`:              v,
+		`.Users[0].Name`: "a",
+		`.Users[1].Name`: "b",
+		`.Users[1]`:      Annotated{"user", _struct{"Name": "b", "Age": _int(300)}},
+		`.Map(42)`:       "answer",
+		`.Map("k")`:      _blob{1},
+		`.Map(nan)`:      "nan",
+	}
+	for path, want := range tests {
+		got, err := Get(v, path)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		if !Equal(got, want) {
+			t.Errorf("%s: expected %s, got %s", path, formatInline(want), formatInline(got))
+		}
+	}
+
+	errs := map[string]string{
+		`.Users[2].Name`: `.Users[2]: value not found`,
+		`.Missing`:       `.Missing: value not found`,
+		`.Users.Name`:    `.Users.Name: cannot select field of array`,
+		`.Score[0]`:      `.Score[0]: cannot select index of float`,
+		`.Map(42.0)`:     `.Map(42.0): value not found`,
+		`.Users(1)`:      `.Users(1): cannot select key of array`,
+	}
+	for path, want := range errs {
+		_, err := Get(v, path)
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: expected error %q, got %v", path, want, err)
+		}
+	}
+	if _, err := Get(v, ".Missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	v := testDocument()
+	if age, err := GetAs[int](v, ".Users[0].Age"); err != nil || age != 30 {
+		t.Errorf("expected 30, got %v, %v", age, err)
+	}
+	if age, err := GetAs[int64](v, ".Users[1].Age"); err != nil || age != 300 {
+		t.Errorf("expected 300, got %v, %v", age, err)
+	}
+	if _, err := GetAs[uint8](v, ".Users[1].Age"); err == nil {
+		t.Errorf("expected overflow error")
+	}
+	if score, err := GetAs[float32](v, ".Score"); err != nil || score != 0.5 {
+		t.Errorf("expected 0.5, got %v, %v", score, err)
+	}
+	if user, err := GetAs[map[string]any](v, ".Users[1]"); err != nil || user["Name"] != "b" {
+		t.Errorf("expected user b, got %v, %v", user, err)
+	}
+	_, err := GetAs[string](v, ".Score")
+	if err == nil || err.Error() != ".Score: cannot get float as string" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	v := testDocument()
+	sets := map[string]any{
+		`.Users[0].Name`: "c",
+		`.Users[1].Name`: "d",
+		`.Users[2]`:      _struct{"Name": "e"},
+		`.Map(nan)`:      "NaN",
+		`.Map(null)`:     "null",
+		`.New`:           _int(1),
+	}
+	var err error
+	for _, path := range keysOf(sets) {
+		if v, err = Set(v, path, sets[path]); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+	}
+	for path, want := range sets {
+		if got, _ := Get(v, path); !Equal(got, want) {
+			t.Errorf("%s: expected %s, got %s", path, formatInline(want), formatInline(got))
+		}
+	}
+
+	if r, err := Set(v, ".", _int(1)); err != nil || r != _int(1) {
+		t.Errorf("expected root to be replaced, got %v, %v", r, err)
+	}
+	for path, want := range map[string]string{
+		`.Users[4]`:    `.Users[4]: value not found`,
+		`.Missing.A`:   `.Missing: value not found`,
+		`.Score.A`:     `.Score.A: cannot select field of float`,
+		`.Users[0][0]`: `.Users[0][0]: cannot select index of struct`,
+		`.Map(|00|)`:   `.Map(|00|): cannot use blob as key`,
+	} {
+		_, err := Set(v, path, nil)
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: expected error %q, got %v", path, want, err)
+		}
+	}
+}
+
+func TestAnnotatedKey(t *testing.T) {
+	var v any = _struct{"M": _map{Annotated{"int32", _int(1)}: "a", Annotated{"x", _float(math.NaN())}: "nan"}}
+	if got, err := Get(v, ".M(1)"); err != nil || got != "a" {
+		t.Errorf("Get: expected \"a\", got %v, %v", got, err)
+	}
+	v, err := Set(v, ".M(1)", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = Set(v, ".M(nan)", "NaN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := _struct{"M": _map{Annotated{"int32", _int(1)}: "b", Annotated{"x", _float(math.NaN())}: "NaN"}}
+	if !Equal(v, want) {
+		t.Errorf("Set: expected %s, got %s", formatInline(want), formatInline(v))
+	}
+	for _, path := range []string{".M(1)", ".M(nan)"} {
+		if v, err = Delete(v, path); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+	}
+	want = _struct{"M": _map{}}
+	if !Equal(v, want) {
+		t.Errorf("Delete: expected %s, got %s", formatInline(want), formatInline(v))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	v := testDocument()
+	var err error
+	for _, path := range []string{`.Users[0]`, `.Users[0].Age`, `.Map(nan)`, `.Score`} {
+		if v, err = Delete(v, path); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+	}
+	want := _struct{
+		"Users": _array{Annotated{"user", _struct{"Name": "b"}}},
+		"Map":   _map{_int(42): "answer", "k": _blob{1}},
+	}
+	if diffs := Diff(v, want); len(diffs) > 0 {
+		for _, d := range diffs {
+			t.Log(d)
+		}
+		t.Errorf("value not equal to control")
+	}
+	if _, err := Delete(v, ".Score"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if r, err := Delete(v, "."); err != nil || r != nil {
+		t.Errorf("expected nil root, got %v, %v", r, err)
+	}
+}
+
+func TestEncodeErrorPath(t *testing.T) {
+	err := NewEncoder(&strings.Builder{}).Encode(_struct{"A": _array{nil, _map{"k": 1}}})
+	if err == nil || err.Error() != `.A[1]("k"): cannot encode type int` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,1146 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryExpr is a parsed query expression. A query is evaluated against an
+// input value, and produces zero or more output values. The syntax resembles
+// jq, with selectors written as ROD paths:
+//
+//	.                  The input.
+//	.Name              The field Name of a struct.
+//	[n]                The element at index n of an array or blob, counting
+//	                   back from the end if n is negative.
+//	("key")            The value of a map at a key.
+//	[]                 Each element of an array, map, struct, or blob.
+//	[i:j]              The elements of an array, bytes of a blob, or
+//	                   characters of a string from i up to j.
+//	..                 The input and every value within it, excluding the
+//	                   bytes of blobs.
+//	a | b              Each output of b applied to each output of a.
+//	a, b               The outputs of a followed by the outputs of b.
+//	[a]                An array of the outputs of a.
+//	{Name: a, Other}   A struct of fields. Other is short for Other: .Other.
+//	a == b, a != b     Comparisons. Values are ordered as by Sort.
+//	a < b, a <= b
+//	a > b, a >= b
+//	a and b, a or b    Logical operators. Only null and false are false.
+//	null, 1, "s", ...  Literal primitives.
+//
+// Selectors follow an expression, such as .Items[0]("id"). The operand of a
+// selector is itself a query applied to the input of the expression. Selecting
+// a field, index, or key that does not exist, or selecting from null, produces
+// null. Otherwise, selecting from a value of the wrong type is an error. Map
+// keys are selected as by Key, so ("key") finds an annotated key with the same
+// value.
+//
+// The following functions are available:
+//
+//	keys            The sorted keys of a map, field names of a struct, or
+//	                indices of an array.
+//	length          The number of elements of a composite, bytes of a blob,
+//	                or characters of a string. The length of null is 0.
+//	type            The name of the type of the input, as given to OfType.
+//	annotation      The annotation of the input, or null.
+//	not             Whether the input is false.
+//	empty           Produces no outputs.
+//	sort            The elements of an array, as sorted by Sort.
+//	has(k)          Whether a struct has field k, a map has key k, or an
+//	                array has index k.
+//	select(f)       The input if f is true.
+//	map(f)          An array of the outputs of f applied to each element.
+//	map_values(f)   The input with each element replaced by the first output
+//	                of f, or removed if f produces no outputs.
+//	nulls, bools, ints, floats, strings, blobs, arrays, maps, structs
+//	                The input if it has the named type.
+//
+// Annotations are retained by selection, and otherwise ignored.
+type QueryExpr struct {
+	src  string
+	root queryNode
+}
+
+// ParseQuery parses a query expression.
+func ParseQuery(s string) (*QueryExpr, error) {
+	p := queryParser{s: s}
+	root, err := p.parsePipe()
+	if err == nil {
+		p.skipSpace()
+		if p.pos < len(p.s) {
+			err = p.errorf("unexpected %q", p.rest())
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return &QueryExpr{src: s, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (q *QueryExpr) String() string {
+	return q.src
+}
+
+// Run evaluates the expression against v, which is a decoded value, and
+// returns the outputs.
+func (q *QueryExpr) Run(v any) ([]any, error) {
+	return q.root.eval(v)
+}
+
+// Query parses the expression expr, and evaluates it against v.
+func Query(expr string, v any) ([]any, error) {
+	q, err := ParseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(v)
+}
+
+// A node of a parsed query.
+type queryNode interface {
+	// Returns the outputs of the node applied to in.
+	eval(in any) ([]any, error)
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %w", p.pos, fmt.Errorf(format, args...))
+}
+
+func (p *queryParser) rest() string {
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return string(r)
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.s) {
+		r, n := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.pos += n
+	}
+}
+
+// Reports whether the remaining input begins with tok, after spaces.
+func (p *queryParser) peek(tok string) bool {
+	p.skipSpace()
+	return strings.HasPrefix(p.s[p.pos:], tok)
+}
+
+// Consumes tok if the remaining input begins with it.
+func (p *queryParser) accept(tok string) bool {
+	if p.peek(tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(tok string) error {
+	if !p.accept(tok) {
+		if p.pos == len(p.s) {
+			return p.errorf("expected %q", tok)
+		}
+		return p.errorf("expected %q, got %q", tok, p.rest())
+	}
+	return nil
+}
+
+// Returns the identifier at the current position, after spaces, without
+// consuming it.
+func (p *queryParser) peekIdent() string {
+	p.skipSpace()
+	return p.identAt(p.pos)
+}
+
+// Returns the identifier at offset i.
+func (p *queryParser) identAt(i int) string {
+	start := i
+	for i < len(p.s) {
+		r, n := utf8.DecodeRuneInString(p.s[i:])
+		if !isIdent(r) || i == start && !isLetter(r) {
+			break
+		}
+		i += n
+	}
+	return p.s[start:i]
+}
+
+// Consumes the keyword word if it is the next identifier.
+func (p *queryParser) acceptKeyword(word string) bool {
+	if p.peekIdent() == word {
+		p.pos += len(word)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parsePipe() (queryNode, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseComma() (queryNode, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = commaNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// Comparison operators, with longer operators first.
+var compareOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *queryParser) parseCompare() (queryNode, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range compareOps {
+		if p.accept(op) {
+			right, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parsePostfix() (node queryNode, err error) {
+	if node, err = p.parsePrimary(); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek(string(rDecimal)) && !p.peek(".."):
+			p.pos++
+			switch {
+			case p.peek(string(rArrayOpen)), p.peek(string(rMapOpen)):
+				// A selector may be preceded by a dot, as in jq.
+			default:
+				name := p.identAt(p.pos)
+				if name == "" {
+					return nil, p.errorf("expected identifier")
+				}
+				p.pos += len(name)
+				node = fieldNode{node, name}
+			}
+		case p.peek(string(rArrayOpen)):
+			if node, err = p.parseBrackets(node); err != nil {
+				return nil, err
+			}
+		case p.peek(string(rMapOpen)):
+			p.pos++
+			key, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(string(rMapClose)); err != nil {
+				return nil, err
+			}
+			node = keyNode{node, key}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// Parses an iteration, index, or slice following target.
+func (p *queryParser) parseBrackets(target queryNode) (node queryNode, err error) {
+	p.pos++
+	if p.accept(string(rArrayClose)) {
+		return iterateNode{target}, nil
+	}
+	var from, to queryNode
+	if !p.peek(":") {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.accept(":") {
+		if err := p.expect(string(rArrayClose)); err != nil {
+			return nil, err
+		}
+		return indexNode{target, from}, nil
+	}
+	if !p.peek(string(rArrayClose)) {
+		if to, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(string(rArrayClose)); err != nil {
+		return nil, err
+	}
+	return sliceNode{target, from, to}, nil
+}
+
+// Matches the beginning of a number literal.
+var numberPrefix = regexp.MustCompile(`^[+-]?(inf|[0-9]+(\.[0-9]+([eE][+-]?[0-9]+)?)?)`)
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return nil, p.errorf("expected expression")
+	}
+	switch c := rune(p.s[p.pos]); {
+	case strings.HasPrefix(p.s[p.pos:], ".."):
+		p.pos += 2
+		return recurseNode{}, nil
+	case c == rDecimal:
+		p.pos++
+		if name := p.identAt(p.pos); name != "" {
+			p.pos += len(name)
+			return fieldNode{identityNode{}, name}, nil
+		}
+		return identityNode{}, nil
+	case c == rArrayOpen:
+		p.pos++
+		if p.accept(string(rArrayClose)) {
+			return arrayNode{}, nil
+		}
+		inner, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(string(rArrayClose)); err != nil {
+			return nil, err
+		}
+		return arrayNode{inner}, nil
+	case c == rStructOpen:
+		return p.parseStruct()
+	case c == rMapOpen:
+		p.pos++
+		inner, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(string(rMapClose)); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case c == rString, c == rBlob:
+		return p.parseLiteral(p.literalEnd(c))
+	case numberPrefix.MatchString(p.s[p.pos:]):
+		return p.parseLiteral(p.pos + len(numberPrefix.FindString(p.s[p.pos:])))
+	}
+	name := p.peekIdent()
+	switch name {
+	case "":
+		return nil, p.errorf("unexpected %q", p.rest())
+	case "null", "true", "false", "inf", "nan":
+		return p.parseLiteral(p.pos + len(name))
+	}
+	p.pos += len(name)
+	f, ok := queryFuncs[name]
+	if !ok {
+		p.pos -= len(name)
+		return nil, p.errorf("unknown function %q", name)
+	}
+	call := callNode{name: name, f: f}
+	if f.arg {
+		if err := p.expect(string(rMapOpen)); err != nil {
+			return nil, err
+		}
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(string(rMapClose)); err != nil {
+			return nil, err
+		}
+		call.arg = arg
+	}
+	return call, nil
+}
+
+// Returns the offset following the string or blob that begins with delim at
+// the current position.
+func (p *queryParser) literalEnd(delim rune) int {
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch rune(p.s[i]) {
+		case delim:
+			return i + 1
+		case rEscape:
+			if delim == rString {
+				i++
+			}
+		}
+	}
+	return len(p.s)
+}
+
+// Decodes the primitive from the current position up to end.
+func (p *queryParser) parseLiteral(end int) (queryNode, error) {
+	var v any
+	if err := NewDecoder(strings.NewReader(p.s[p.pos:end])).Decode(&v); err != nil {
+		return nil, p.errorf("literal: %w", err)
+	}
+	p.pos = end
+	return literalNode{v}, nil
+}
+
+func (p *queryParser) parseStruct() (queryNode, error) {
+	p.pos++
+	var node structNode
+	for !p.accept(string(rStructClose)) {
+		name := p.peekIdent()
+		if name == "" {
+			return nil, p.errorf("expected field name")
+		}
+		p.pos += len(name)
+		var value queryNode = fieldNode{identityNode{}, name}
+		if p.accept(":") {
+			var err error
+			if value, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+		}
+		node.names = append(node.names, name)
+		node.values = append(node.values, value)
+		if !p.accept(",") {
+			if err := p.expect(string(rStructClose)); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return node, nil
+}
+
+// Reports whether v is true in a condition.
+func truthy(v any) bool {
+	switch v := unannotate(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	default:
+		return true
+	}
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(in any) ([]any, error) {
+	return []any{in}, nil
+}
+
+type recurseNode struct{}
+
+func (recurseNode) eval(in any) ([]any, error) {
+	var out []any
+	seen := map[identity]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		out = append(out, v)
+		if id, ok := identityOf(unannotate(v)); ok {
+			if seen[id] {
+				return
+			}
+			seen[id] = true
+		}
+		if _, ok := unannotate(v).([]byte); ok {
+			return
+		}
+		elems, _ := elementsOf(unannotate(v))
+		for _, e := range elems {
+			walk(e)
+		}
+	}
+	walk(in)
+	return out, nil
+}
+
+// Returns the elements of a composite or blob in order.
+func elementsOf(v any) ([]any, error) {
+	var elems []any
+	switch v := v.(type) {
+	case []any:
+		elems = v
+	case map[any]any:
+		mapForEach(v, func(k, v any) error {
+			elems = append(elems, v)
+			return nil
+		})
+	case map[string]any:
+		structForEach(v, func(k string, v any) error {
+			elems = append(elems, v)
+			return nil
+		})
+	case []byte:
+		for _, b := range v {
+			elems = append(elems, int64(b))
+		}
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+	}
+	return elems, nil
+}
+
+// Evaluates target against in, and calls f with each output, with annotations
+// removed. The results of f are concatenated.
+func evalEach(target queryNode, in any, f func(v any) ([]any, error)) ([]any, error) {
+	vs, err := target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, v := range vs {
+		r, err := f(unannotate(v))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r...)
+	}
+	return out, nil
+}
+
+type fieldNode struct {
+	target queryNode
+	name   string
+}
+
+func (n fieldNode) eval(in any) ([]any, error) {
+	return evalEach(n.target, in, func(v any) ([]any, error) {
+		if v == nil {
+			return []any{nil}, nil
+		}
+		e, err := selectStep(v, Field(n.name))
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return []any{e}, nil
+	})
+}
+
+type indexNode struct {
+	target queryNode
+	index  queryNode
+}
+
+func (n indexNode) eval(in any) ([]any, error) {
+	indices, err := n.index.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	return evalEach(n.target, in, func(v any) ([]any, error) {
+		var out []any
+		for _, index := range indices {
+			i, ok := unannotate(index).(int64)
+			if !ok {
+				return nil, fmt.Errorf("cannot index with %s", typeName(index))
+			}
+			var length int
+			switch v := v.(type) {
+			case nil:
+			case []any:
+				length = len(v)
+			case []byte:
+				length = len(v)
+			default:
+				return nil, fmt.Errorf("cannot select index of %s", typeName(v))
+			}
+			if i < 0 {
+				i += int64(length)
+			}
+			if i < 0 || i >= int64(length) {
+				out = append(out, nil)
+				continue
+			}
+			switch v := v.(type) {
+			case []any:
+				out = append(out, v[i])
+			case []byte:
+				out = append(out, int64(v[i]))
+			}
+		}
+		return out, nil
+	})
+}
+
+type keyNode struct {
+	target queryNode
+	key    queryNode
+}
+
+func (n keyNode) eval(in any) ([]any, error) {
+	keys, err := n.key.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	return evalEach(n.target, in, func(v any) ([]any, error) {
+		var out []any
+		for _, k := range keys {
+			if v == nil {
+				out = append(out, nil)
+				continue
+			}
+			e, err := selectStep(v, Key{unannotate(k)})
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			out = append(out, e)
+		}
+		return out, nil
+	})
+}
+
+type iterateNode struct {
+	target queryNode
+}
+
+func (n iterateNode) eval(in any) ([]any, error) {
+	return evalEach(n.target, in, elementsOf)
+}
+
+type sliceNode struct {
+	target   queryNode
+	from, to queryNode // Nil if omitted.
+}
+
+// Returns the bounds of a slice of length n, from the outputs of the from and
+// to nodes.
+func sliceBounds(from, to any, n int) (i, j int, err error) {
+	bound := func(v any, def int) (int, error) {
+		switch v := unannotate(v).(type) {
+		case nil:
+			return def, nil
+		case int64:
+			if v < 0 {
+				v += int64(n)
+			}
+			if v < 0 {
+				return 0, nil
+			} else if v > int64(n) {
+				return n, nil
+			}
+			return int(v), nil
+		default:
+			return 0, fmt.Errorf("cannot slice with %s", typeName(v))
+		}
+	}
+	if i, err = bound(from, 0); err != nil {
+		return 0, 0, err
+	}
+	if j, err = bound(to, n); err != nil {
+		return 0, 0, err
+	}
+	if j < i {
+		j = i
+	}
+	return i, j, nil
+}
+
+func (n sliceNode) eval(in any) ([]any, error) {
+	bounds := func(node queryNode) ([]any, error) {
+		if node == nil {
+			return []any{nil}, nil
+		}
+		return node.eval(in)
+	}
+	froms, err := bounds(n.from)
+	if err != nil {
+		return nil, err
+	}
+	tos, err := bounds(n.to)
+	if err != nil {
+		return nil, err
+	}
+	return evalEach(n.target, in, func(v any) ([]any, error) {
+		var out []any
+		for _, from := range froms {
+			for _, to := range tos {
+				switch v := v.(type) {
+				case nil:
+					out = append(out, nil)
+				case []any:
+					i, j, err := sliceBounds(from, to, len(v))
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, append([]any{}, v[i:j]...))
+				case []byte:
+					i, j, err := sliceBounds(from, to, len(v))
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, append([]byte{}, v[i:j]...))
+				case string:
+					r := []rune(v)
+					i, j, err := sliceBounds(from, to, len(r))
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, string(r[i:j]))
+				default:
+					return nil, fmt.Errorf("cannot slice %s", typeName(v))
+				}
+			}
+		}
+		return out, nil
+	})
+}
+
+type pipeNode struct {
+	left, right queryNode
+}
+
+func (n pipeNode) eval(in any) ([]any, error) {
+	vs, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, v := range vs {
+		r, err := n.right.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r...)
+	}
+	return out, nil
+}
+
+type commaNode struct {
+	left, right queryNode
+}
+
+func (n commaNode) eval(in any) ([]any, error) {
+	l, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	return append(l, r...), nil
+}
+
+type literalNode struct {
+	v any
+}
+
+func (n literalNode) eval(in any) ([]any, error) {
+	return []any{n.v}, nil
+}
+
+type arrayNode struct {
+	inner queryNode // Nil if empty.
+}
+
+func (n arrayNode) eval(in any) ([]any, error) {
+	a := []any{}
+	if n.inner != nil {
+		vs, err := n.inner.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, vs...)
+	}
+	return []any{a}, nil
+}
+
+type structNode struct {
+	names  []string
+	values []queryNode
+}
+
+// Produces a struct for each combination of the outputs of the fields.
+func (n structNode) eval(in any) ([]any, error) {
+	outs := []map[string]any{{}}
+	for i, name := range n.names {
+		vs, err := n.values[i].eval(in)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]map[string]any, 0, len(outs)*len(vs))
+		for _, s := range outs {
+			for _, v := range vs {
+				c := make(map[string]any, len(s)+1)
+				for k, e := range s {
+					c[k] = e
+				}
+				c[name] = v
+				next = append(next, c)
+			}
+		}
+		outs = next
+	}
+	out := make([]any, len(outs))
+	for i, s := range outs {
+		out[i] = s
+	}
+	return out, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right queryNode
+}
+
+func (n compareNode) eval(in any) ([]any, error) {
+	ls, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, l := range ls {
+		for _, r := range rs {
+			var b bool
+			switch n.op {
+			case "==":
+				b = Equal(l, r, IgnoreAnnotations())
+			case "!=":
+				b = !Equal(l, r, IgnoreAnnotations())
+			case "<":
+				b = compareValues(l, r) < 0
+			case "<=":
+				b = compareValues(l, r) <= 0
+			case ">":
+				b = compareValues(l, r) > 0
+			case ">=":
+				b = compareValues(l, r) >= 0
+			}
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right queryNode
+}
+
+func (n logicNode) eval(in any) ([]any, error) {
+	ls, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, l := range ls {
+		// The right operand is not evaluated if the left operand decides the
+		// result.
+		if truthy(l) != n.and {
+			out = append(out, !n.and)
+			continue
+		}
+		rs, err := n.right.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			out = append(out, truthy(r))
+		}
+	}
+	return out, nil
+}
+
+// A function callable from a query.
+type queryFunc struct {
+	// Whether the function receives an argument.
+	arg bool
+	// Returns the outputs of the function applied to in, which has no
+	// annotation. v is the original input.
+	call func(n callNode, in, v any) ([]any, error)
+}
+
+type callNode struct {
+	name string
+	f    queryFunc
+	arg  queryNode // Nil if the function has no argument.
+}
+
+func (n callNode) eval(in any) ([]any, error) {
+	out, err := n.f.call(n, unannotate(in), in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return out, nil
+}
+
+var queryFuncs map[string]queryFunc
+
+func init() {
+	queryFuncs = map[string]queryFunc{
+		"keys":       {call: queryKeys},
+		"length":     {call: queryLength},
+		"type":       {call: queryType},
+		"annotation": {call: queryAnnotation},
+		"not":        {call: queryNot},
+		"empty":      {call: queryEmpty},
+		"sort":       {call: querySort},
+		"has":        {arg: true, call: queryHas},
+		"select":     {arg: true, call: querySelect},
+		"map":        {arg: true, call: queryMap},
+		"map_values": {arg: true, call: queryMapValues},
+	}
+	for _, t := range []string{"null", "bool", "int", "float", "string", "blob", "array", "map", "struct"} {
+		isType := OfType(t)
+		queryFuncs[t+"s"] = queryFunc{call: func(n callNode, in, v any) ([]any, error) {
+			if isType(in) {
+				return []any{v}, nil
+			}
+			return nil, nil
+		}}
+	}
+}
+
+func queryKeys(n callNode, in, v any) ([]any, error) {
+	var keys []any
+	switch in := in.(type) {
+	case []any:
+		keys = make([]any, len(in))
+		for i := range in {
+			keys[i] = int64(i)
+		}
+	case map[any]any:
+		for k := range in {
+			keys = append(keys, k)
+		}
+		sortKeys(keys)
+	case map[string]any:
+		structForEach(in, func(k string, v any) error {
+			keys = append(keys, k)
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("%s has no keys", typeName(in))
+	}
+	if keys == nil {
+		keys = []any{}
+	}
+	return []any{keys}, nil
+}
+
+func queryLength(n callNode, in, v any) ([]any, error) {
+	var length int
+	switch in := in.(type) {
+	case nil:
+	case string:
+		length = utf8.RuneCountInString(in)
+	case []byte:
+		length = len(in)
+	case []any:
+		length = len(in)
+	case map[any]any:
+		length = len(in)
+	case map[string]any:
+		length = len(in)
+	default:
+		return nil, fmt.Errorf("%s has no length", typeName(in))
+	}
+	return []any{int64(length)}, nil
+}
+
+func queryType(n callNode, in, v any) ([]any, error) {
+	return []any{typeName(in)}, nil
+}
+
+func queryAnnotation(n callNode, in, v any) ([]any, error) {
+	if a, ok := annotationOf(v); ok {
+		return []any{a}, nil
+	}
+	return []any{nil}, nil
+}
+
+func queryNot(n callNode, in, v any) ([]any, error) {
+	return []any{!truthy(in)}, nil
+}
+
+func queryEmpty(n callNode, in, v any) ([]any, error) {
+	return nil, nil
+}
+
+func querySort(n callNode, in, v any) ([]any, error) {
+	if _, ok := in.([]any); !ok {
+		return nil, fmt.Errorf("cannot sort %s", typeName(in))
+	}
+	s, _ := Sort(nil, in)
+	return []any{s}, nil
+}
+
+func queryHas(n callNode, in, v any) ([]any, error) {
+	keys, err := n.arg.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, k := range keys {
+		k = unannotate(k)
+		var s Step
+		switch in.(type) {
+		case map[any]any:
+			s = Key{k}
+		case map[string]any:
+			if f, ok := k.(string); ok {
+				s = Field(f)
+			}
+		case []any:
+			if i, ok := k.(int64); ok {
+				s = Index(i)
+			}
+		default:
+			return nil, fmt.Errorf("%s has no keys", typeName(in))
+		}
+		if s == nil {
+			return nil, fmt.Errorf("%s cannot have %s key", typeName(in), typeName(k))
+		}
+		_, err := selectStep(in, s)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		out = append(out, err == nil)
+	}
+	return out, nil
+}
+
+func querySelect(n callNode, in, v any) ([]any, error) {
+	conds, err := n.arg.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, c := range conds {
+		if truthy(c) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func queryMap(n callNode, in, v any) ([]any, error) {
+	a, ok := in.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot map over %s", typeName(in))
+	}
+	r := []any{}
+	for _, e := range a {
+		vs, err := n.arg.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, vs...)
+	}
+	return []any{r}, nil
+}
+
+func queryMapValues(n callNode, in, v any) ([]any, error) {
+	first := func(e any) (any, bool, error) {
+		vs, err := n.arg.eval(e)
+		if err != nil || len(vs) == 0 {
+			return nil, false, err
+		}
+		return vs[0], true, nil
+	}
+	var r any
+	switch in := in.(type) {
+	case []any:
+		c := make([]any, 0, len(in))
+		for _, e := range in {
+			x, ok, err := first(e)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				c = append(c, x)
+			}
+		}
+		r = c
+	case map[any]any:
+		c := make(map[any]any, len(in))
+		for k, e := range in {
+			x, ok, err := first(e)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				c[k] = x
+			}
+		}
+		r = c
+	case map[string]any:
+		c := make(map[string]any, len(in))
+		for k, e := range in {
+			x, ok, err := first(e)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				c[k] = x
+			}
+		}
+		r = c
+	default:
+		return nil, fmt.Errorf("cannot map over %s", typeName(in))
+	}
+	if a, ok := annotationOf(v); ok {
+		r = Annotated{Annotation: a, Value: r}
+	}
+	return []any{r}, nil
+}
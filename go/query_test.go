@@ -0,0 +1,101 @@
+package rod
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	v := _struct{
+		"Name": "héllo",
+		"Data": _blob{0x00, 0x01, 0x02, 0x03},
+		"Users": _array{
+			_struct{"Name": "b", "Age": _int(30), "Admin": true},
+			_struct{"Name": "a", "Age": _int(20)},
+		},
+		"Scores": _map{"x": _float(1.5), _int(2): _int(4), math.NaN(): nil},
+		"Note":   Annotated{"int32", _int(7)},
+		"Sized":  _map{Annotated{"int32", _int(1)}: "a"},
+	}
+	tests := []struct {
+		expr string
+		want _array
+	}{
+		{`.`, _array{v}},
+		{`.Users[0].Name`, _array{"b"}},
+		{`.Users[-1].Name`, _array{"a"}},
+		{`.Users.[1].Age`, _array{_int(20)}},
+		{`.Users[5]`, _array{nil}},
+		{`.Missing.Name`, _array{nil}},
+		{`.Scores("x")`, _array{_float(1.5)}},
+		{`.Scores(2)`, _array{_int(4)}},
+		{`.Scores(nan)`, _array{nil}},
+		{`.Scores("y")`, _array{nil}},
+		{`.Sized(1)`, _array{"a"}},
+		{`.Users[].Name`, _array{"b", "a"}},
+		{`.Users[] | .Name`, _array{"b", "a"}},
+		{`.Users[0].Name, .Users[1].Name`, _array{"b", "a"}},
+		{`[.Users[].Age]`, _array{_array{_int(30), _int(20)}}},
+		{`[]`, _array{_array{}}},
+		{`.Scores[]`, _array{_int(4), nil, _float(1.5)}},
+		{`.Data[1:3]`, _array{_blob{0x01, 0x02}}},
+		{`.Data[-2:]`, _array{_blob{0x02, 0x03}}},
+		{`.Data[:1]`, _array{_blob{0x00}}},
+		{`.Data[2]`, _array{_int(2)}},
+		{`.Data[]`, _array{_int(0), _int(1), _int(2), _int(3)}},
+		{`.Name[1:3]`, _array{"él"}},
+		{`.Users[1:]`, _array{_array{v["Users"].(_array)[1]}}},
+		{`.Users[] | select(.Age > 25) | .Name`, _array{"b"}},
+		{`.Users[] | select(.Admin) | .Name`, _array{"b"}},
+		{`.Users[] | select(.Admin | not) | .Name`, _array{"a"}},
+		{`.Users[] | select(.Age >= 20 and .Name == "a") | .Name`, _array{"a"}},
+		{`.Users[] | select(.Name == "x" or has("Admin")) | .Name`, _array{"b"}},
+		{`.Users | map(.Age)`, _array{_array{_int(30), _int(20)}}},
+		{`.Users[0] | map_values(strings)`, _array{_struct{"Name": "b"}}},
+		{`.Users | map({N: .Name, Age})`, _array{_array{
+			_struct{"N": "b", "Age": _int(30)},
+			_struct{"N": "a", "Age": _int(20)},
+		}}},
+		{`{A: (1, 2)}`, _array{_struct{"A": _int(1)}, _struct{"A": _int(2)}}},
+		{`keys`, _array{_array{"Data", "Name", "Note", "Scores", "Sized", "Users"}}},
+		{`.Scores | keys`, _array{_array{_int(2), math.NaN(), "x"}}},
+		{`.Users | keys`, _array{_array{_int(0), _int(1)}}},
+		{`.Name, .Data, .Users, .Scores, null | length`, _array{_int(5), _int(4), _int(2), _int(3), _int(0)}},
+		{`.[] | type`, _array{"blob", "string", "int", "map", "map", "array"}},
+		{`.[] | ints`, _array{Annotated{"int32", _int(7)}}},
+		{`.Note`, _array{Annotated{"int32", _int(7)}}},
+		{`.Note | annotation`, _array{"int32"}},
+		{`.Name | annotation`, _array{nil}},
+		{`.Note == 7`, _array{true}},
+		{`[.. | ints]`, _array{_array{Annotated{"int32", _int(7)}, _int(4), _int(30), _int(20)}}},
+		{`[.Users[].Name] | sort`, _array{_array{"a", "b"}}},
+		{`|00 ff|, -1.5, nan, "a\"b", true`, _array{_blob{0x00, 0xff}, _float(-1.5), math.NaN(), "a\"b", true}},
+		{`1 < "a", 2 != 2, null <= false`, _array{true, false, true}},
+		{`empty`, nil},
+		{`.Scores | has(2), has("y")`, _array{true, false}},
+		{`.Sized | has(1), has(2)`, _array{true, false}},
+	}
+	for _, test := range tests {
+		got, err := Query(test.expr, v)
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+		if !Equal(_array(got), test.want) && !(len(got) == 0 && len(test.want) == 0) {
+			t.Errorf("%s: expected %s, got %s", test.expr, formatInline(test.want), formatInline(_array(got)))
+		}
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	v := _struct{"A": _int(1), "B": _array{}}
+	for _, expr := range []string{
+		``, `.A |`, `[.A`, `.1`, `foo`, `select`, `select(.A`, `{1: 2}`, `"a`, `.A )`,
+		`.A.B`, `.A[0]`, `.B("k")`, `.A[]`, `.B["x"]`, `.A[1:]`, `.A | keys`,
+		`.A | map(.)`, `.B | has("x")`, `.A | sort`,
+	} {
+		if _, err := Query(expr, v); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
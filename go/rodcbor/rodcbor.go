@@ -0,0 +1,667 @@
+// Package rodcbor converts between CBOR, as specified by RFC 8949, and the
+// decoded values of the rod package.
+//
+// CBOR data items are converted to ROD values as follows:
+//
+//	unsigned integer    int
+//	negative integer    int
+//	byte string         blob
+//	text string         string
+//	array               array
+//	map                 map
+//	false, true         bool
+//	null                null
+//	undefined           null, annotated with <undefined>
+//	simple value        int, annotated with <simple>
+//	float               float
+//
+// A tag is converted to an annotation containing the tag number, such as
+// <tag 1>. An integer outside the range of an int is converted to a string
+// containing the integer in decimal, annotated with <bigint>.
+//
+// The preferred serialization of an integer or float is the shortest form that
+// preserves its value. An integer or float that is not encoded with the
+// preferred serialization is annotated with the width of its encoding, which
+// is one of <uint8>, <uint16>, <uint32>, or <uint64> for the argument of an
+// integer, and one of <float16>, <float32>, or <float64> for a float.
+//
+// A value with multiple annotations, such as a tagged integer that is not
+// preferred, receives a single annotation that lists them separated by commas,
+// tags first:
+//
+//	<tag 1, uint32> 0
+//
+// ROD values are converted to CBOR by the reverse mapping, using the preferred
+// serialization unless annotated otherwise. A struct is converted to a map with
+// text string keys. Map keys are sorted by their encoding, as specified by
+// the core deterministic encoding requirements of RFC 8949. A NaN is encoded
+// without a payload. An annotation that is not one of the above results in an
+// error.
+//
+// Indefinite-length strings, arrays, and maps are decoded, but are encoded
+// with definite lengths. Map keys must be primitives other than blobs, and may
+// be annotated.
+package rodcbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/convert"
+)
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNint   = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Additional information of the initial byte.
+const (
+	info8          = 24
+	info16         = 25
+	info32         = 26
+	info64         = 27
+	infoIndefinite = 31
+)
+
+// The break code that terminates an indefinite-length item.
+const breakCode = 0xff
+
+// Names of annotations for the width of an integer argument, indexed by the
+// additional information minus info8.
+var intWidths = []string{"uint8", "uint16", "uint32", "uint64"}
+
+// ToROD converts a single CBOR data item to a decoded ROD value.
+func ToROD(data []byte) (any, error) {
+	d := decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos < len(d.data) {
+		return nil, fmt.Errorf("unexpected data after item at offset %d", d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// Reads the initial byte and argument of a data item. The argument of an
+// indefinite-length item is zero. Returns the width annotation of an argument
+// that is not preferred, or an empty string.
+func (d *decoder) head() (major, info byte, arg uint64, width string, err error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < info8:
+		return major, info, uint64(info), "", nil
+	case info <= info64:
+		n := 1 << (info - info8)
+		p, err := d.bytes(uint64(n))
+		if err != nil {
+			return 0, 0, 0, "", err
+		}
+		for _, c := range p {
+			arg = arg<<8 | uint64(c)
+		}
+		if major != majorSimple && argWidth(arg) < info {
+			width = intWidths[info-info8]
+		}
+		return major, info, arg, width, nil
+	case info == infoIndefinite:
+		switch major {
+		case majorBytes, majorText, majorArray, majorMap, majorSimple:
+			return major, info, 0, "", nil
+		}
+	}
+	return 0, 0, 0, "", d.errorf("invalid additional information %d", info)
+}
+
+// Returns the additional information of the preferred encoding of arg.
+func argWidth(arg uint64) byte {
+	switch {
+	case arg < info8:
+		return byte(arg)
+	case arg <= math.MaxUint8:
+		return info8
+	case arg <= math.MaxUint16:
+		return info16
+	case arg <= math.MaxUint32:
+		return info32
+	}
+	return info64
+}
+
+// Decodes a data item, returning its value with its notes joined into an
+// annotation.
+func (d *decoder) value() (any, error) {
+	v, notes, err := d.item()
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return v, nil
+	}
+	return rod.Annotated{Annotation: strings.Join(notes, ", "), Value: v}, nil
+}
+
+// Decodes a data item, returning its unannotated value and the notes of its
+// annotation.
+func (d *decoder) item() (v any, notes []string, err error) {
+	major, info, arg, width, err := d.head()
+	if err != nil {
+		return nil, nil, err
+	}
+	if width != "" {
+		notes = append(notes, width)
+	}
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return strconv.FormatUint(arg, 10), []string{"bigint"}, nil
+		}
+		return int64(arg), notes, nil
+	case majorNint:
+		if arg > math.MaxInt64 {
+			n := new(big.Int).SetUint64(arg)
+			n.Neg(n.Add(n, big.NewInt(1)))
+			return n.String(), []string{"bigint"}, nil
+		}
+		return -1 - int64(arg), notes, nil
+	case majorBytes, majorText:
+		b, err := d.string(major, info, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if major == majorBytes {
+			return b, nil, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, nil, d.errorf("invalid UTF-8 in text string")
+		}
+		return string(b), nil, nil
+	case majorArray:
+		a := []any{}
+		for i := uint64(0); info == infoIndefinite || i < arg; i++ {
+			if info == infoIndefinite && d.atBreak() {
+				break
+			}
+			e, err := d.value()
+			if err != nil {
+				return nil, nil, err
+			}
+			a = append(a, e)
+		}
+		return a, nil, nil
+	case majorMap:
+		m := map[any]any{}
+		for i := uint64(0); info == infoIndefinite || i < arg; i++ {
+			if info == infoIndefinite && d.atBreak() {
+				break
+			}
+			pos := d.pos
+			k, err := d.value()
+			if err != nil {
+				return nil, nil, err
+			}
+			if !convert.IsKey(k) {
+				d.pos = pos
+				return nil, nil, d.errorf("cannot convert %s to map key", convert.Describe(k))
+			}
+			e, err := d.value()
+			if err != nil {
+				return nil, nil, err
+			}
+			m[k] = e
+		}
+		return m, nil, nil
+	case majorTag:
+		v, inner, err := d.item()
+		if err != nil {
+			return nil, nil, err
+		}
+		return v, append([]string{"tag " + strconv.FormatUint(arg, 10)}, inner...), nil
+	}
+
+	// Major type 7.
+	switch info {
+	case 20:
+		return false, nil, nil
+	case 21:
+		return true, nil, nil
+	case 22:
+		return nil, nil, nil
+	case 23:
+		return nil, []string{"undefined"}, nil
+	case info8:
+		if arg < 32 {
+			return nil, nil, d.errorf("invalid simple value %d", arg)
+		}
+		return int64(arg), []string{"simple"}, nil
+	case info16:
+		f := halfToFloat(uint16(arg))
+		return f, floatNotes(f, 16), nil
+	case info32:
+		f := float64(math.Float32frombits(uint32(arg)))
+		return f, floatNotes(f, 32), nil
+	case info64:
+		f := math.Float64frombits(arg)
+		return f, floatNotes(f, 64), nil
+	case infoIndefinite:
+		return nil, nil, d.errorf("unexpected break")
+	}
+	return int64(arg), []string{"simple"}, nil
+}
+
+// Returns the notes of a float decoded with the given number of bits.
+func floatNotes(f float64, bits int) []string {
+	if floatBits(f) < bits {
+		return []string{"float" + strconv.Itoa(bits)}
+	}
+	return nil
+}
+
+// Reports whether the next byte is a break code, consuming it if so.
+func (d *decoder) atBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == breakCode {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// Reads the content of a byte or text string, concatenating the chunks of an
+// indefinite-length string.
+func (d *decoder) string(major, info byte, arg uint64) ([]byte, error) {
+	if info != infoIndefinite {
+		return d.bytes(arg)
+	}
+	b := []byte{}
+	for !d.atBreak() {
+		m, i, n, _, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == infoIndefinite {
+			return nil, d.errorf("invalid chunk of indefinite-length string")
+		}
+		chunk, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+	return b, nil
+}
+
+// Converts the bits of a half-precision float to a float64.
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// Returns the bits of a half-precision float equal to f, and whether f can be
+// represented exactly.
+func floatToHalf(f float64) (uint16, bool) {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+	}
+	a := math.Abs(f)
+	switch {
+	case a != a:
+		return 0x7e00, true
+	case math.IsInf(a, 0):
+		return sign | 0x7c00, true
+	case a == 0:
+		return sign, true
+	case a < math.Ldexp(1, -14):
+		// Subnormal.
+		m := math.Ldexp(a, 24)
+		if m != math.Trunc(m) {
+			return 0, false
+		}
+		return sign | uint16(m), true
+	}
+	_, e := math.Frexp(a)
+	exp := e + 14
+	m := math.Ldexp(a, 25-exp)
+	if exp >= 0x1f || m != math.Trunc(m) {
+		return 0, false
+	}
+	return sign | uint16(exp)<<10 | uint16(m-1024), true
+}
+
+// Returns the number of bits of the preferred encoding of f.
+func floatBits(f float64) int {
+	if _, ok := floatToHalf(f); ok {
+		return 16
+	}
+	if float64(float32(f)) == f {
+		return 32
+	}
+	return 64
+}
+
+// FromROD converts the decoded ROD value v to a CBOR data item.
+func FromROD(v any) ([]byte, error) {
+	e := encoder{visiting: convert.Visiting{}}
+	if err := e.value(v, nil); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	// Composites currently being encoded.
+	visiting convert.Visiting
+}
+
+// Writes the initial byte and argument of a data item. If width is not zero,
+// then the argument is written with the given additional information, if it
+// fits.
+func (e *encoder) head(major byte, arg uint64, width byte) error {
+	info := argWidth(arg)
+	if width != 0 {
+		if width < info {
+			return fmt.Errorf("%d does not fit in %s", arg, intWidths[width-info8])
+		}
+		info = width
+	}
+	e.buf.WriteByte(major<<5 | info)
+	if info >= info8 {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], arg)
+		e.buf.Write(b[8-1<<(info-info8):])
+	}
+	return nil
+}
+
+// Writes v with the given notes of its annotation.
+func (e *encoder) value(v any, notes []string) error {
+	if a, ok := v.(rod.Annotated); ok {
+		v = a.Value
+		notes = strings.Split(a.Annotation, ",")
+		for i := range notes {
+			notes[i] = strings.TrimSpace(notes[i])
+		}
+	}
+	for len(notes) > 0 && strings.HasPrefix(notes[0], "tag ") {
+		n, err := strconv.ParseUint(strings.TrimPrefix(notes[0], "tag "), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid tag %q", notes[0])
+		}
+		e.head(majorTag, n, 0)
+		notes = notes[1:]
+	}
+	var note string
+	switch len(notes) {
+	case 0:
+	case 1:
+		note = notes[0]
+	default:
+		return fmt.Errorf("cannot convert annotation %q", strings.Join(notes, ", "))
+	}
+	invalid := func() error {
+		return fmt.Errorf("cannot convert %s annotated with %q", convert.Describe(v), note)
+	}
+
+	switch v := v.(type) {
+	case nil:
+		switch note {
+		case "":
+			e.buf.WriteByte(majorSimple<<5 | 22)
+		case "undefined":
+			e.buf.WriteByte(majorSimple<<5 | 23)
+		default:
+			return invalid()
+		}
+	case bool:
+		if note != "" {
+			return invalid()
+		}
+		if v {
+			e.buf.WriteByte(majorSimple<<5 | 21)
+		} else {
+			e.buf.WriteByte(majorSimple<<5 | 20)
+		}
+	case int64:
+		if note == "simple" {
+			switch {
+			case v < 0 || v > math.MaxUint8 || 20 <= v && v < 32:
+				return fmt.Errorf("invalid simple value %d", v)
+			case v < info8:
+				e.buf.WriteByte(majorSimple<<5 | byte(v))
+			default:
+				e.buf.Write([]byte{majorSimple<<5 | info8, byte(v)})
+			}
+			return nil
+		}
+		var width byte
+		if note != "" {
+			i := indexOf(intWidths, note)
+			if i < 0 {
+				return invalid()
+			}
+			width = byte(i) + info8
+		}
+		if v < 0 {
+			return e.head(majorNint, uint64(-1-v), width)
+		}
+		return e.head(majorUint, uint64(v), width)
+	case float64:
+		bits := floatBits(v)
+		switch note {
+		case "":
+		case "float16", "float32", "float64":
+			n, _ := strconv.Atoi(strings.TrimPrefix(note, "float"))
+			if n < bits {
+				return fmt.Errorf("%v cannot be represented exactly as %s", v, note)
+			}
+			bits = n
+		default:
+			return invalid()
+		}
+		switch bits {
+		case 16:
+			h, _ := floatToHalf(v)
+			e.buf.Write([]byte{majorSimple<<5 | info16, byte(h >> 8), byte(h)})
+		case 32:
+			b := math.Float32bits(float32(v))
+			if v != v {
+				b = 0x7fc00000
+			}
+			e.buf.WriteByte(majorSimple<<5 | info32)
+			binary.Write(&e.buf, binary.BigEndian, b)
+		default:
+			b := math.Float64bits(v)
+			if v != v {
+				b = 0x7ff8000000000000
+			}
+			e.buf.WriteByte(majorSimple<<5 | info64)
+			binary.Write(&e.buf, binary.BigEndian, b)
+		}
+	case string:
+		switch note {
+		case "":
+			e.head(majorText, uint64(len(v)), 0)
+			e.buf.WriteString(v)
+		case "bigint":
+			n, ok := new(big.Int).SetString(v, 10)
+			if !ok {
+				return fmt.Errorf("invalid bigint %q", v)
+			}
+			major := byte(majorUint)
+			if n.Sign() < 0 {
+				major = majorNint
+				n.Neg(n.Add(n, big.NewInt(1)))
+			}
+			if !n.IsUint64() {
+				return fmt.Errorf("bigint %s overflows 64 bits", v)
+			}
+			e.head(major, n.Uint64(), 0)
+		default:
+			return invalid()
+		}
+	case []byte:
+		if note != "" {
+			return invalid()
+		}
+		e.head(majorBytes, uint64(len(v)), 0)
+		e.buf.Write(v)
+	case []any:
+		if note != "" {
+			return invalid()
+		}
+		if len(v) > 0 {
+			done, err := e.visiting.Visit(v)
+			if err != nil {
+				return err
+			}
+			defer done()
+		}
+		e.head(majorArray, uint64(len(v)), 0)
+		for _, x := range v {
+			if err := e.value(x, nil); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		if note != "" {
+			return invalid()
+		}
+		return e.entries(v, len(v), func(f func(k, v any) error) error {
+			for k, x := range v {
+				if err := f(k, x); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case map[string]any:
+		if note != "" {
+			return invalid()
+		}
+		return e.entries(v, len(v), func(f func(k, v any) error) error {
+			for k, x := range v {
+				if err := f(k, x); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("cannot convert type %T", v)
+	}
+	return nil
+}
+
+// Writes the n entries of the map m, visited by each, sorted by the encoding
+// of their keys.
+func (e *encoder) entries(m any, n int, each func(f func(k, v any) error) error) error {
+	done, err := e.visiting.Visit(m)
+	if err != nil {
+		return err
+	}
+	defer done()
+	type entry struct {
+		key   []byte
+		value any
+	}
+	entries := make([]entry, 0, n)
+	err = each(func(k, v any) error {
+		if !convert.IsKey(k) {
+			return fmt.Errorf("cannot convert %s to map key", convert.Describe(k))
+		}
+		c := encoder{visiting: e.visiting}
+		if err := c.value(k, nil); err != nil {
+			return err
+		}
+		entries = append(entries, entry{c.buf.Bytes(), v})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	e.head(majorMap, uint64(n), 0)
+	for _, x := range entries {
+		e.buf.Write(x.key)
+		if err := e.value(x.value, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexOf(a []string, s string) int {
+	for i, x := range a {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,234 @@
+package rodcbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/testutil"
+)
+
+// Examples from Appendix A of RFC 8949, with the equivalent ROD value, and
+// whether the encoding is reproduced from the value.
+var examples = []struct {
+	hex   string
+	rod   string
+	exact bool
+}{
+	{"00", `0`, true},
+	{"01", `1`, true},
+	{"0a", `10`, true},
+	{"17", `23`, true},
+	{"1818", `24`, true},
+	{"1819", `25`, true},
+	{"1864", `100`, true},
+	{"1903e8", `1000`, true},
+	{"1a000f4240", `1000000`, true},
+	{"1b000000e8d4a51000", `1000000000000`, true},
+	{"1bffffffffffffffff", `<bigint> "18446744073709551615"`, true},
+	{"c249010000000000000000", `<tag 2> |01 00 00 00 00 00 00 00 00|`, true},
+	{"3bffffffffffffffff", `<bigint> "-18446744073709551616"`, true},
+	{"c349010000000000000000", `<tag 3> |01 00 00 00 00 00 00 00 00|`, true},
+	{"20", `-1`, true},
+	{"29", `-10`, true},
+	{"3863", `-100`, true},
+	{"3903e7", `-1000`, true},
+	{"f90000", `0.0`, true},
+	{"f98000", `-0.0`, true},
+	{"f93c00", `1.0`, true},
+	{"fb3ff199999999999a", `1.1`, true},
+	{"f93e00", `1.5`, true},
+	{"f97bff", `65504.0`, true},
+	{"fa47c35000", `100000.0`, true},
+	{"fa7f7fffff", `3.4028234663852886e+38`, true},
+	{"fb7e37e43c8800759c", `1.0e+300`, true},
+	{"f90001", `5.960464477539063e-8`, true},
+	{"f90400", `0.00006103515625`, true},
+	{"f9c400", `-4.0`, true},
+	{"fbc010666666666666", `-4.1`, true},
+	{"f97c00", `inf`, true},
+	{"f97e00", `nan`, true},
+	{"f9fc00", `-inf`, true},
+	{"fa7f800000", `<float32> inf`, true},
+	{"fa7fc00000", `<float32> nan`, true},
+	{"faff800000", `<float32> -inf`, true},
+	{"fb7ff0000000000000", `<float64> inf`, true},
+	{"fb7ff8000000000000", `<float64> nan`, true},
+	{"fbfff0000000000000", `<float64> -inf`, true},
+	{"f4", `false`, true},
+	{"f5", `true`, true},
+	{"f6", `null`, true},
+	{"f7", `<undefined> null`, true},
+	{"f0", `<simple> 16`, true},
+	{"f8ff", `<simple> 255`, true},
+	{"c074323031332d30332d32315432303a30343a30305a", `<tag 0> "2013-03-21T20:04:00Z"`, true},
+	{"c11a514b67b0", `<tag 1> 1363896240`, true},
+	{"c1fb41d452d9ec200000", `<tag 1> 1363896240.5`, true},
+	{"d74401020304", `<tag 23> |01 02 03 04|`, true},
+	{"d818456449455446", `<tag 24> |64 49 45 54 46|`, true},
+	{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", `<tag 32> "http://www.example.com"`, true},
+	{"40", `||`, true},
+	{"4401020304", `|01 02 03 04|`, true},
+	{"60", `""`, true},
+	{"6161", `"a"`, true},
+	{"6449455446", `"IETF"`, true},
+	{"62225c", `"\"\\"`, true},
+	{"62c3bc", `"\u{fc}"`, true},
+	{"63e6b0b4", `"\u{6c34}"`, true},
+	{"64f0908591", `"\u{10151}"`, true},
+	{"80", `[]`, true},
+	{"83010203", `[1, 2, 3]`, true},
+	{"8301820203820405", `[1, [2, 3], [4, 5]]`, true},
+	{"98190102030405060708090a0b0c0d0e0f101112131415161718181819",
+		`[1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25]`, true},
+	{"a0", `()`, true},
+	{"a201020304", `(1: 2, 3: 4)`, true},
+	{"a26161016162820203", `("a": 1, "b": [2, 3])`, true},
+	{"826161a161626163", `["a", ("b": "c")]`, true},
+	{"a56161614161626142616361436164614461656145",
+		`("a": "A", "b": "B", "c": "C", "d": "D", "e": "E")`, true},
+	{"5f42010243030405ff", `|01 02 03 04 05|`, false},
+	{"7f657374726561646d696e67ff", `"streaming"`, false},
+	{"9fff", `[]`, false},
+	{"9f018202039f0405ffff", `[1, [2, 3], [4, 5]]`, false},
+	{"9f01820203820405ff", `[1, [2, 3], [4, 5]]`, false},
+	{"83018202039f0405ff", `[1, [2, 3], [4, 5]]`, false},
+	{"83019f0203ff820405", `[1, [2, 3], [4, 5]]`, false},
+	{"9f0102030405060708090a0b0c0d0e0f101112131415161718181819ff",
+		`[1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25]`, false},
+	{"bf61610161629f0203ffff", `("a": 1, "b": [2, 3])`, false},
+	{"826161bf61626163ff", `["a", ("b": "c")]`, false},
+	{"bf6346756ef563416d7421ff", `("Fun": true, "Amt": -2)`, false},
+}
+
+func TestExamples(t *testing.T) {
+	for _, x := range examples {
+		data, err := hex.DecodeString(x.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := testutil.Decode(t, x.rod)
+		got, err := ToROD(data)
+		if err != nil {
+			t.Errorf("%s: %s", x.hex, err)
+			continue
+		}
+		if !rod.Equal(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", x.hex, want, got)
+			continue
+		}
+		enc, err := FromROD(want)
+		if err != nil {
+			t.Errorf("%s: encode: %s", x.hex, err)
+			continue
+		}
+		if x.exact && !bytes.Equal(enc, data) {
+			t.Errorf("%s: encoded as %x", x.hex, enc)
+		}
+		if again, err := ToROD(enc); err != nil || !rod.Equal(again, want) {
+			t.Errorf("%s: round trip: expected %#v, got %#v (%v)", x.hex, want, again, err)
+		}
+	}
+}
+
+func TestWidths(t *testing.T) {
+	tests := map[string]string{
+		"1800":               `<uint8> 0`,
+		"190001":             `<uint16> 1`,
+		"3a000000ff":         `<uint32> -256`,
+		"1b0000000000000018": `<uint64> 24`,
+		"c11a00000000":       `<tag 1, uint32> 0`,
+		"d9d9f7c101":         `<tag 55799, tag 1> 1`,
+		"fa3fc00000":         `<float32> 1.5`,
+		"fb3ff8000000000000": `<float64> 1.5`,
+		"fb3ff0000000000000": `<float64> 1.0`,
+		"c1fa3f800000":       `<tag 1, float32> 1.0`,
+		"a1f4f6":             `(false: null)`,
+		"f93c01":             `1.0009765625`,
+		"fa33800000":         `<float32> 5.960464477539063e-8`,
+		"fa38800000":         `<float32> 0.00006103515625`,
+		"fb3e70000000000000": `<float64> 5.960464477539063e-8`,
+
+		"a1c10101":               `(<tag 1> 1: 1)`,
+		"a1180001":               `(<uint8> 0: 1)`,
+		"a1fb3ff800000000000001": `(<float64> 1.5: 1)`,
+	}
+	for h, s := range tests {
+		data, _ := hex.DecodeString(h)
+		want := testutil.Decode(t, s)
+		got, err := ToROD(data)
+		if err != nil {
+			t.Errorf("%s: %s", h, err)
+			continue
+		}
+		if !rod.Equal(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", h, want, got)
+		}
+		if enc, err := FromROD(want); err != nil || !bytes.Equal(enc, data) {
+			t.Errorf("%s: encoded as %x (%v)", h, enc, err)
+		}
+	}
+}
+
+func TestFromROD(t *testing.T) {
+	got, err := FromROD(testutil.Decode(t, `{b: 1, a: [|ff|], aa: null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a361618141ff616201626161f6"; hex.EncodeToString(got) != want {
+		t.Errorf("expected %s, got %x", want, got)
+	}
+
+	for _, s := range []string{
+		`<note> 1`,
+		`<uint8> 256`,
+		`<float16> 1.1`,
+		`<float32> 1`,
+		`<simple> 20`,
+		`<simple> 256`,
+		`<bigint> "x"`,
+		`<bigint> "18446744073709551616"`,
+		`<tag x> 1`,
+		`<tag 1, tag 2, uint8, uint16> 1`,
+		`<undefined> 1`,
+	} {
+		if _, err := FromROD(testutil.Decode(t, s)); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+
+	if _, err := FromROD(map[any]any{rod.Annotated{Annotation: "a", Value: "k"}: int64(1)}); err == nil {
+		t.Error("expected error for key with unknown annotation")
+	}
+
+	cyclic := []any{nil}
+	cyclic[0] = cyclic
+	if _, err := FromROD(cyclic); err == nil {
+		t.Error("expected error for cyclic value")
+	}
+}
+
+func TestToRODErrors(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"18",
+		"1c",
+		"0000",
+		"62c3",
+		"62ffff",
+		"5f6161ff",
+		"5f5fffff",
+		"f818",
+		"ff",
+		"a1800102",
+		"a14001",
+		"a1c18001",
+		"9f01",
+	} {
+		data, _ := hex.DecodeString(h)
+		if _, err := ToROD(data); err == nil {
+			t.Errorf("%s: expected error", h)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package rodjson converts between JSON and the decoded values of the rod
+// package.
+//
+// JSON values are converted to ROD values as follows:
+//
+//	null      null
+//	true      true
+//	false     false
+//	number    int if it has no fraction or exponent and fits in an int64,
+//	          and float otherwise
+//	string    string
+//	array     array
+//	object    struct if every key is an identifier, and map otherwise
+//
+// ROD values are converted to JSON values by the reverse mapping. In addition,
+// an annotated value is written as an object with exactly two members,
+// "$annotation" and "$value":
+//
+//	{"$annotation": "text", "$value": 1}
+//
+// Such an object is converted back to an annotated value. Values that have no
+// JSON equivalent are written as annotated values with the following
+// annotations, which are converted back to the original values:
+//
+//	base64    A blob, written as a base64 string.
+//	map       A map that would otherwise convert back to a struct or an
+//	          annotated value, or that has keys other than strings, written as
+//	          an array of [key, value] pairs, sorted by the JSON encoding of
+//	          their keys.
+//	float     An infinite or NaN float, written as "inf", "-inf", or "nan".
+//
+// A float is always written with a fraction or exponent, so that it converts
+// back to a float. Consequently, a ROD value converts to JSON and back without
+// loss, except that a string annotated with one of the above annotations is
+// converted back to the value it would represent.
+package rodjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/convert"
+)
+
+// Names of the members of an object representing an annotated value.
+const (
+	annotationKey = "$annotation"
+	valueKey      = "$value"
+)
+
+// ToROD converts a single JSON document to a decoded ROD value.
+func ToROD(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after document")
+	}
+	return toROD(v)
+}
+
+func toROD(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		s := v.String()
+		if !strings.ContainsAny(s, ".eE") {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, err
+		}
+		return f, nil
+	case []any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			r, err := toROD(e)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = r
+		}
+		return a, nil
+	case map[string]any:
+		if a, ok := v[annotationKey].(string); ok && len(v) == 2 {
+			if e, ok := v[valueKey]; ok {
+				return annotated(a, e)
+			}
+		}
+		ident := true
+		for k := range v {
+			if !isIdent(k) {
+				ident = false
+				break
+			}
+		}
+		if ident {
+			s := make(map[string]any, len(v))
+			for k, e := range v {
+				r, err := toROD(e)
+				if err != nil {
+					return nil, err
+				}
+				s[k] = r
+			}
+			return s, nil
+		}
+		m := make(map[any]any, len(v))
+		for k, e := range v {
+			r, err := toROD(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T", v)
+}
+
+// Converts the value of an object representing an annotated value.
+func annotated(annotation string, v any) (any, error) {
+	switch annotation {
+	case "base64":
+		if s, ok := v.(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+	case "map":
+		if pairs, ok := v.([]any); ok {
+			m := make(map[any]any, len(pairs))
+			for _, p := range pairs {
+				p, ok := p.([]any)
+				if !ok || len(p) != 2 {
+					return nil, errors.New("map entry must be a [key, value] pair")
+				}
+				k, err := toROD(p[0])
+				if err != nil {
+					return nil, err
+				}
+				if !convert.IsKey(k) {
+					return nil, fmt.Errorf("cannot convert %s to map key", convert.Describe(k))
+				}
+				e, err := toROD(p[1])
+				if err != nil {
+					return nil, err
+				}
+				m[k] = e
+			}
+			return m, nil
+		}
+	case "float":
+		switch v {
+		case "inf":
+			return math.Inf(1), nil
+		case "-inf":
+			return math.Inf(-1), nil
+		case "nan":
+			return math.NaN(), nil
+		}
+	}
+	r, err := toROD(v)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := r.(rod.Annotated); ok {
+		return nil, errors.New("cannot annotate an annotated value")
+	}
+	return rod.Annotated{Annotation: annotation, Value: r}, nil
+}
+
+// FromROD converts the decoded ROD value v to a JSON document. The document is
+// indented with tabs.
+func FromROD(v any) ([]byte, error) {
+	c := converter{visiting: convert.Visiting{}}
+	j, err := c.fromROD(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "\t")
+	if err := e.Encode(j); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type converter struct {
+	// Composites currently being converted.
+	visiting convert.Visiting
+}
+
+// Returns an object representing the annotated value v.
+func annotation(a string, v any) map[string]any {
+	return map[string]any{annotationKey: a, valueKey: v}
+}
+
+func (c *converter) fromROD(v any) (any, error) {
+	switch v.(type) {
+	case []any, map[any]any, map[string]any:
+		if reflect.ValueOf(v).Len() > 0 {
+			leave, err := c.visiting.Visit(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+	}
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return annotation("float", "inf"), nil
+		case math.IsInf(v, -1):
+			return annotation("float", "-inf"), nil
+		case v != v:
+			return annotation("float", "nan"), nil
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return json.Number(s), nil
+	case []byte:
+		return annotation("base64", base64.StdEncoding.EncodeToString(v)), nil
+	case rod.Annotated:
+		e, err := c.fromROD(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return annotation(v.Annotation, e), nil
+	case []any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			r, err := c.fromROD(e)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = r
+		}
+		return a, nil
+	case map[string]any:
+		s := make(map[string]any, len(v))
+		for k, e := range v {
+			r, err := c.fromROD(e)
+			if err != nil {
+				return nil, err
+			}
+			s[k] = r
+		}
+		return s, nil
+	case map[any]any:
+		return c.fromMap(v)
+	}
+	return nil, fmt.Errorf("cannot convert type %T", v)
+}
+
+// Converts a map to an object if it would convert back to a map, and to an
+// array of pairs otherwise.
+func (c *converter) fromMap(v map[any]any) (any, error) {
+	object := len(v) > 0
+	ident := true
+	for k := range v {
+		s, ok := k.(string)
+		if !ok {
+			object = false
+			break
+		}
+		ident = ident && isIdent(s)
+	}
+	// An object with the members of an annotated value would convert back to
+	// an annotated value.
+	_, hasAnnotation := v[annotationKey]
+	_, hasValue := v[valueKey]
+	if len(v) == 2 && hasAnnotation && hasValue {
+		object = false
+	}
+	if object && !ident {
+		o := make(map[string]any, len(v))
+		for k, e := range v {
+			r, err := c.fromROD(e)
+			if err != nil {
+				return nil, err
+			}
+			o[k.(string)] = r
+		}
+		return o, nil
+	}
+	// Pairs are collected by iteration, since indexing never finds a NaN key.
+	type pair struct {
+		enc  []byte // Encoding of the key.
+		pair []any
+	}
+	pairs := make([]pair, 0, len(v))
+	for k, e := range v {
+		jk, err := c.fromROD(k)
+		if err != nil {
+			return nil, err
+		}
+		je, err := c.fromROD(e)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := json.Marshal(jk)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{enc, []any{jk, je}})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if c := bytes.Compare(pairs[i].enc, pairs[j].enc); c != 0 {
+			return c < 0
+		}
+		// Keys such as NaN are encoded equally, so the order of their pairs
+		// falls back to the encoding of the whole pair.
+		pi, _ := json.Marshal(pairs[i].pair)
+		pj, _ := json.Marshal(pairs[j].pair)
+		return bytes.Compare(pi, pj) < 0
+	})
+	a := make([]any, len(pairs))
+	for i, p := range pairs {
+		a[i] = p.pair
+	}
+	return annotation("map", a), nil
+}
+
+// Reports whether s is a ROD identifier.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package rodjson
+
+import (
+	"math"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/testutil"
+)
+
+func TestToROD(t *testing.T) {
+	tests := map[string]string{
+		`null`:                    `null`,
+		`[true, false]`:           `[true, false]`,
+		`[1, -0, 1.0, 1e2, 1E-2]`: `[1, 0, 1.0, 100.0, 0.01]`,
+		`9223372036854775808`:     `9.223372036854775808e18`,
+		`1e999`:                   `inf`,
+		`"aé"`:                    `"aé"`,
+		`{"a": 1, "_b2": {}}`:     `{a: 1, _b2: {}}`,
+		`{"a": 1, "b c": []}`:     `("a": 1, "b c": [])`,
+		`{"": null}`:              `("": null)`,
+		`{"2": null}`:             `("2": null)`,
+
+		`{"$annotation": "a", "$value": 1}`:                                       `<a> 1`,
+		`{"$annotation": "a", "$value": 1, "x": 2}`:                               `("$annotation": "a", "$value": 1, "x": 2)`,
+		`{"$annotation": 1, "$value": 1}`:                                         `("$annotation": 1, "$value": 1)`,
+		`{"$annotation": "base64", "$value": "AP8="}`:                             `|00 ff|`,
+		`{"$annotation": "base64", "$value": 1}`:                                  `<base64> 1`,
+		`{"$annotation": "map", "$value": [[1, 2], [2.5, 3]]}`:                    `(1: 2, 2.5: 3)`,
+		`{"$annotation": "float", "$value": "-inf"}`:                              `-inf`,
+		`{"$annotation": "float", "$value": "x"}`:                                 `<float> "x"`,
+		`{"$annotation": "a", "$value": {"$annotation": "base64", "$value": ""}}`: `<a> ||`,
+
+		`{"$annotation": "map", "$value": [[{"$annotation": "a", "$value": 1}, 2]]}`: `(<a> 1: 2)`,
+	}
+	for in, out := range tests {
+		got, err := ToROD([]byte(in))
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+			continue
+		}
+		if want := testutil.Decode(t, out); !rod.Equal(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", in, want, got)
+		}
+	}
+
+	for _, in := range []string{
+		``,
+		`[`,
+		`1 2`,
+		`{"a":1} garbage`,
+		`{"a":1} }`,
+		`{"$annotation": "a", "$value": {"$annotation": "b", "$value": 1}}`,
+		`{"$annotation": "base64", "$value": "!"}`,
+		`{"$annotation": "map", "$value": [1]}`,
+		`{"$annotation": "map", "$value": [[{}, 1]]}`,
+		`{"$annotation": "map", "$value": [[{"$annotation": "base64", "$value": ""}, 1]]}`,
+		`{"$annotation": "map", "$value": [[{"$annotation": "a", "$value": []}, 1]]}`,
+	} {
+		if _, err := ToROD([]byte(in)); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestFromROD(t *testing.T) {
+	v := testutil.Decode(t, `{
+		Null: null,
+		Int: -1,
+		Float: 1.0,
+		Big: 1.0e100,
+		Inf: inf,
+		NaN: <x> nan,
+		Blob: |00 ff|,
+		Array: ["<&>"],
+		Struct: {a: 1},
+		Object: ("a b": 1),
+		Ident: ("a": 1),
+		Empty: (),
+		Keys: (true: 1, 2: 2, 1: 1, "a": null, <int32> 1: 3),
+		Annotated: <note> {a: 1},
+		Fake: ("$annotation": "a", "$value": 1),
+	}`)
+	got, err := FromROD(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"Annotated": {
+		"$annotation": "note",
+		"$value": {
+			"a": 1
+		}
+	},
+	"Array": [
+		"<&>"
+	],
+	"Big": 1e+100,
+	"Blob": {
+		"$annotation": "base64",
+		"$value": "AP8="
+	},
+	"Empty": {
+		"$annotation": "map",
+		"$value": []
+	},
+	"Fake": {
+		"$annotation": "map",
+		"$value": [
+			[
+				"$annotation",
+				"a"
+			],
+			[
+				"$value",
+				1
+			]
+		]
+	},
+	"Float": 1.0,
+	"Ident": {
+		"$annotation": "map",
+		"$value": [
+			[
+				"a",
+				1
+			]
+		]
+	},
+	"Inf": {
+		"$annotation": "float",
+		"$value": "inf"
+	},
+	"Int": -1,
+	"Keys": {
+		"$annotation": "map",
+		"$value": [
+			[
+				"a",
+				null
+			],
+			[
+				1,
+				1
+			],
+			[
+				2,
+				2
+			],
+			[
+				true,
+				1
+			],
+			[
+				{
+					"$annotation": "int32",
+					"$value": 1
+				},
+				3
+			]
+		]
+	},
+	"NaN": {
+		"$annotation": "x",
+		"$value": {
+			"$annotation": "float",
+			"$value": "nan"
+		}
+	},
+	"Null": null,
+	"Object": {
+		"a b": 1
+	},
+	"Struct": {
+		"a": 1
+	}
+}
+`
+	if string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	back, err := ToROD(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rod.Equal(back, v) {
+		t.Errorf("round trip: expected %#v, got %#v", v, back)
+	}
+
+	cyclic := []any{nil}
+	cyclic[0] = cyclic
+	if _, err := FromROD(cyclic); err == nil {
+		t.Error("expected error for cyclic value")
+	}
+	if _, err := FromROD(map[any]any{math.NaN(): int64(1), math.NaN(): int64(2)}); err != nil {
+		t.Error(err)
+	}
+}
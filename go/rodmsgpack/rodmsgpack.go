@@ -0,0 +1,494 @@
+// Package rodmsgpack converts between MessagePack and the decoded values of
+// the rod package.
+//
+// MessagePack values are converted to ROD values as follows:
+//
+//	nil         null
+//	bool        bool
+//	int         int
+//	float 32    float, annotated with <float32>
+//	float 64    float
+//	str         string
+//	bin         blob
+//	array       array
+//	map         map
+//	ext         blob containing the data, annotated with the type, such as
+//	            <ext 1> or <ext -1>
+//
+// An int outside the range of an int is converted to a string containing the
+// integer in decimal, annotated with <bigint>. A str that is not valid UTF-8
+// is converted to a blob annotated with <str>, except that a map key, which
+// cannot be a blob, is converted to a string annotated with <str>.
+//
+// ROD values are converted to MessagePack by the reverse mapping, using the
+// smallest format that represents each value. A struct is converted to a map
+// with str keys. Map entries are sorted by the encoding of their keys. An
+// annotation that is not one of the above results in an error.
+//
+// Map keys must be primitives other than blobs, and may be annotated.
+package rodmsgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/convert"
+)
+
+// ToROD converts a single MessagePack value to a decoded ROD value.
+func ToROD(data []byte) (any, error) {
+	d := decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos < len(d.data) {
+		return nil, fmt.Errorf("unexpected data after value at offset %d", d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// Reads a big-endian unsigned integer of n bytes.
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.bytes(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *decoder) value() (any, error) {
+	b, err := d.bytes(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c <= 0x8f:
+		return d.dict(uint64(c & 0x0f))
+	case c <= 0x9f:
+		return d.array(uint64(c & 0x0f))
+	case c <= 0xbf:
+		return d.str(uint64(c & 0x1f))
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		f := float64(math.Float32frombits(uint32(u)))
+		return rod.Annotated{Annotation: "float32", Value: f}, nil
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(u), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return rod.Annotated{Annotation: "bigint", Value: strconv.FormatUint(u, 10)}, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err := d.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from n bytes.
+		shift := 64 - 8*n
+		return int64(u<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.dict(n)
+	}
+	d.pos--
+	return nil, d.errorf("invalid format 0x%02x", c)
+}
+
+func (d *decoder) str(n uint64) (any, error) {
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return rod.Annotated{Annotation: "str", Value: append([]byte{}, b...)}, nil
+	}
+	return string(b), nil
+}
+
+// Reads the type and n bytes of data of an ext.
+func (d *decoder) ext(n uint64) (any, error) {
+	t, err := d.bytes(1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return rod.Annotated{
+		Annotation: "ext " + strconv.Itoa(int(int8(t[0]))),
+		Value:      append([]byte{}, b...),
+	}, nil
+}
+
+func (d *decoder) array(n uint64) (any, error) {
+	a := []any{}
+	for i := uint64(0); i < n; i++ {
+		e, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, e)
+	}
+	return a, nil
+}
+
+func (d *decoder) dict(n uint64) (any, error) {
+	m := map[any]any{}
+	for i := uint64(0); i < n; i++ {
+		pos := d.pos
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		// A blob cannot be a map key, so a str key that is not valid UTF-8 is
+		// kept as a string.
+		if a, ok := k.(rod.Annotated); ok && a.Annotation == "str" {
+			if b, ok := a.Value.([]byte); ok {
+				k = rod.Annotated{Annotation: "str", Value: string(b)}
+			}
+		}
+		if !convert.IsKey(k) {
+			d.pos = pos
+			return nil, d.errorf("cannot convert %s to map key", convert.Describe(k))
+		}
+		e, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = e
+	}
+	return m, nil
+}
+
+// FromROD converts the decoded ROD value v to a MessagePack value.
+func FromROD(v any) ([]byte, error) {
+	e := encoder{visiting: convert.Visiting{}}
+	if err := e.value(v); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	// Composites currently being encoded.
+	visiting convert.Visiting
+}
+
+// Writes u as a big-endian unsigned integer of n bytes.
+func (e *encoder) uint(u uint64, n int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	e.buf.Write(b[8-n:])
+}
+
+// Writes the header of a value of length n, using fix for lengths below
+// fixMax, and otherwise the first of the formats, which have lengths of 1, 2,
+// and 4 bytes, that can hold n. A zero format is skipped.
+func (e *encoder) header(n int, fix byte, fixMax int, formats [3]byte) {
+	switch {
+	case n < fixMax:
+		e.buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && formats[0] != 0:
+		e.buf.WriteByte(formats[0])
+		e.uint(uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(formats[1])
+		e.uint(uint64(n), 2)
+	default:
+		e.buf.WriteByte(formats[2])
+		e.uint(uint64(n), 4)
+	}
+}
+
+func (e *encoder) int(v int64) {
+	switch {
+	case v >= 0 && v <= 0x7f, v < 0 && v >= -32:
+		e.buf.WriteByte(byte(v))
+	case v >= 0 && v <= math.MaxUint8:
+		e.buf.WriteByte(0xcc)
+		e.uint(uint64(v), 1)
+	case v >= 0 && v <= math.MaxUint16:
+		e.buf.WriteByte(0xcd)
+		e.uint(uint64(v), 2)
+	case v >= 0 && v <= math.MaxUint32:
+		e.buf.WriteByte(0xce)
+		e.uint(uint64(v), 4)
+	case v >= 0:
+		e.buf.WriteByte(0xcf)
+		e.uint(uint64(v), 8)
+	case v >= math.MinInt8:
+		e.buf.WriteByte(0xd0)
+		e.uint(uint64(v), 1)
+	case v >= math.MinInt16:
+		e.buf.WriteByte(0xd1)
+		e.uint(uint64(v), 2)
+	case v >= math.MinInt32:
+		e.buf.WriteByte(0xd2)
+		e.uint(uint64(v), 4)
+	default:
+		e.buf.WriteByte(0xd3)
+		e.uint(uint64(v), 8)
+	}
+}
+
+func (e *encoder) str(b []byte) {
+	e.header(len(b), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb})
+	e.buf.Write(b)
+}
+
+func (e *encoder) value(v any) error {
+	if a, ok := v.(rod.Annotated); ok {
+		return e.annotated(a)
+	}
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case int64:
+		e.int(v)
+	case float64:
+		e.buf.WriteByte(0xcb)
+		e.uint(math.Float64bits(v), 8)
+	case string:
+		e.str([]byte(v))
+	case []byte:
+		e.header(len(v), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+		e.buf.Write(v)
+	case []any:
+		if len(v) > 0 {
+			done, err := e.visiting.Visit(v)
+			if err != nil {
+				return err
+			}
+			defer done()
+		}
+		e.header(len(v), 0x90, 16, [3]byte{0, 0xdc, 0xdd})
+		for _, x := range v {
+			if err := e.value(x); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		keys := make([]any, 0, len(v))
+		values := make([]any, 0, len(v))
+		for k, x := range v {
+			keys = append(keys, k)
+			values = append(values, x)
+		}
+		return e.dict(v, keys, values)
+	case map[string]any:
+		keys := make([]any, 0, len(v))
+		values := make([]any, 0, len(v))
+		for k, x := range v {
+			keys = append(keys, k)
+			values = append(values, x)
+		}
+		return e.dict(v, keys, values)
+	default:
+		return fmt.Errorf("cannot convert type %T", v)
+	}
+	return nil
+}
+
+func (e *encoder) annotated(a rod.Annotated) error {
+	invalid := func() error {
+		return fmt.Errorf("cannot convert %s annotated with %q", convert.Describe(a.Value), a.Annotation)
+	}
+	switch v := a.Value.(type) {
+	case float64:
+		if a.Annotation != "float32" {
+			return invalid()
+		}
+		if f := float32(v); float64(f) != v && v == v {
+			return fmt.Errorf("%v cannot be represented exactly as float32", v)
+		}
+		e.buf.WriteByte(0xca)
+		e.uint(uint64(math.Float32bits(float32(v))), 4)
+	case string:
+		if a.Annotation == "str" && !utf8.ValidString(v) {
+			e.str([]byte(v))
+			return nil
+		}
+		if a.Annotation != "bigint" {
+			return invalid()
+		}
+		u, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid bigint %q", v)
+		}
+		if u <= math.MaxInt64 {
+			e.int(int64(u))
+		} else {
+			e.buf.WriteByte(0xcf)
+			e.uint(u, 8)
+		}
+	case []byte:
+		if a.Annotation == "str" {
+			e.str(v)
+			return nil
+		}
+		s, ok := strings.CutPrefix(a.Annotation, "ext ")
+		if !ok {
+			return invalid()
+		}
+		t, err := strconv.ParseInt(s, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid ext type %q", s)
+		}
+		switch len(v) {
+		case 1, 2, 4, 8, 16:
+			e.buf.WriteByte(0xd4 + byte(bitsLen(len(v))))
+		default:
+			e.header(len(v), 0, 0, [3]byte{0xc7, 0xc8, 0xc9})
+		}
+		e.buf.WriteByte(byte(t))
+		e.buf.Write(v)
+	default:
+		return invalid()
+	}
+	return nil
+}
+
+// Returns the base-2 logarithm of the power of two n.
+func bitsLen(n int) int {
+	i := 0
+	for n > 1 {
+		n >>= 1
+		i++
+	}
+	return i
+}
+
+// Writes the map m with the given entries, sorted by the encoding of their
+// keys.
+func (e *encoder) dict(m any, keys, values []any) error {
+	if len(keys) > 0 {
+		done, err := e.visiting.Visit(m)
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+	type entry struct {
+		key   []byte
+		value any
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		if !convert.IsKey(k) {
+			return fmt.Errorf("cannot convert %s to map key", convert.Describe(k))
+		}
+		c := encoder{visiting: e.visiting}
+		if err := c.value(k); err != nil {
+			return err
+		}
+		entries[i] = entry{c.buf.Bytes(), values[i]}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	e.header(len(keys), 0x80, 16, [3]byte{0, 0xde, 0xdf})
+	for _, x := range entries {
+		e.buf.Write(x.key)
+		if err := e.value(x.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
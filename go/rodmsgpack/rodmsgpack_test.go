@@ -0,0 +1,166 @@
+package rodmsgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/testutil"
+)
+
+// Encoded values with the equivalent ROD value, and whether the encoding is
+// reproduced from the value.
+var examples = []struct {
+	hex   string
+	rod   string
+	exact bool
+}{
+	{"c0", `null`, true},
+	{"c2", `false`, true},
+	{"c3", `true`, true},
+	{"00", `0`, true},
+	{"7f", `127`, true},
+	{"cc80", `128`, true},
+	{"ccff", `255`, true},
+	{"cd0100", `256`, true},
+	{"ce00010000", `65536`, true},
+	{"cf0000000100000000", `4294967296`, true},
+	{"cfffffffffffffffff", `<bigint> "18446744073709551615"`, true},
+	{"ff", `-1`, true},
+	{"e0", `-32`, true},
+	{"d0df", `-33`, true},
+	{"d080", `-128`, true},
+	{"d1ff7f", `-129`, true},
+	{"d2ffff7fff", `-32769`, true},
+	{"d3ffffffff7fffffff", `-2147483649`, true},
+	{"d38000000000000001", `-9223372036854775807`, true},
+	{"cc01", `1`, false},
+	{"d0ff", `-1`, false},
+	{"d3000000000000007f", `127`, false},
+	{"ca3fc00000", `<float32> 1.5`, true},
+	{"ca7f800000", `<float32> inf`, true},
+	{"cb3ff199999999999a", `1.1`, true},
+	{"cb8000000000000000", `-0.0`, true},
+	{"a0", `""`, true},
+	{"a161", `"a"`, true},
+	{"d920" + strings.Repeat("61", 32), `"` + strings.Repeat("a", 32) + `"`, true},
+	{"da0001" + "61", `"a"`, false},
+	{"a1ff", `<str> |ff|`, true},
+	{"c400", `||`, true},
+	{"c40200ff", `|00 ff|`, true},
+	{"d40105", `<ext 1> |05|`, true},
+	{"d5ff0102", `<ext -1> |01 02|`, true},
+	{"d6ff00000001", `<ext -1> |00 00 00 01|`, true},
+	{"d70200000000000000ff", `<ext 2> |00 00 00 00 00 00 00 ff|`, true},
+	{"d803" + strings.Repeat("00", 16), `<ext 3> |` + strings.Repeat("00 ", 15) + `00|`, true},
+	{"c70003", `<ext 3> ||`, true},
+	{"c7030a010203", `<ext 10> |01 02 03|`, true},
+	{"90", `[]`, true},
+	{"93010203", `[1, 2, 3]`, true},
+	{"dc0001c0", `[null]`, false},
+	{"dc0010" + strings.Repeat("c0", 16), `[` + strings.Repeat("null, ", 16) + `]`, true},
+	{"80", `()`, true},
+	{"82a16101a162920203", `("a": 1, "b": [2, 3])`, true},
+	{"83a16101c0c2c2c3", `(null: false, false: true, "a": 1)`, true},
+	{"820102cb3ff8000000000000c0", `(1: 2, 1.5: null)`, true},
+	{"de0001c0c0", `(null: null)`, false},
+	{"81ca3f800000c0", `(<float32> 1.0: null)`, true},
+	{"81cfffffffffffffffffc0", `(<bigint> "18446744073709551615": null)`, true},
+}
+
+func TestExamples(t *testing.T) {
+	for _, x := range examples {
+		data, err := hex.DecodeString(x.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := testutil.Decode(t, x.rod)
+		got, err := ToROD(data)
+		if err != nil {
+			t.Errorf("%s: %s", x.hex, err)
+			continue
+		}
+		if !rod.Equal(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", x.hex, want, got)
+			continue
+		}
+		enc, err := FromROD(want)
+		if err != nil {
+			t.Errorf("%s: encode: %s", x.hex, err)
+			continue
+		}
+		if x.exact && !bytes.Equal(enc, data) {
+			t.Errorf("%s: encoded as %x", x.hex, enc)
+		}
+		if again, err := ToROD(enc); err != nil || !rod.Equal(again, want) {
+			t.Errorf("%s: round trip: expected %#v, got %#v (%v)", x.hex, want, again, err)
+		}
+	}
+}
+
+func TestFromROD(t *testing.T) {
+	got, err := FromROD(testutil.Decode(t, `{b: 1, a: [|ff|]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "82a16191c401ffa16201"; hex.EncodeToString(got) != want {
+		t.Errorf("expected %s, got %x", want, got)
+	}
+
+	for _, s := range []string{
+		`<note> 1`,
+		`<float32> 1.1`,
+		`<bigint> "-1"`,
+		`<ext 128> ||`,
+		`<ext x> ||`,
+		`<str> "a"`,
+	} {
+		if _, err := FromROD(testutil.Decode(t, s)); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+	if _, err := FromROD(map[any]any{rod.Annotated{Annotation: "a", Value: "k"}: int64(1)}); err == nil {
+		t.Error("expected error for key with unknown annotation")
+	}
+
+	// A str key that is not valid UTF-8 is a string rather than a blob.
+	data := []byte{0x81, 0xa1, 0xff, 0xc0}
+	v, err := ToROD(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[any]any{rod.Annotated{Annotation: "str", Value: "\xff"}: nil}; !rod.Equal(v, want) {
+		t.Errorf("expected %#v, got %#v", want, v)
+	}
+	if got, err := FromROD(v); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("expected %x, got %x (%v)", data, got, err)
+	}
+
+	cyclic := []any{nil}
+	cyclic[0] = cyclic
+	if _, err := FromROD(cyclic); err == nil {
+		t.Error("expected error for cyclic value")
+	}
+}
+
+func TestToRODErrors(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"c1",
+		"0000",
+		"cc",
+		"a2",
+		"c402",
+		"d401",
+		"91",
+		"8190",
+		"81c400c0",
+	} {
+		data, _ := hex.DecodeString(h)
+		if _, err := ToROD(data); err == nil {
+			t.Errorf("%s: expected error", h)
+		}
+	}
+}
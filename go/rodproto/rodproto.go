@@ -0,0 +1,207 @@
+// Package rodproto decodes protocol buffers in the wire format to the decoded
+// values of the rod package, without a schema.
+//
+// A message is converted to a map from each field number to the value of the
+// field. A field that appears more than once is converted to an array of its
+// values, in order of appearance. Each value is annotated with its wire type:
+//
+//	<varint>   An int. A negative int32 or int64 appears as a negative int,
+//	           while a value encoded with zigzag encoding, as by sint32 or
+//	           sint64, appears as it is encoded.
+//	<fixed64>  An int containing the 64 bits of the value.
+//	<fixed32>  An int containing the 32 bits of the value, zero-extended.
+//	<len>      A string, a message, or a blob, as described below.
+//	<group>    A message, for the deprecated group encoding.
+//
+// Because the wire format does not identify the type of a length-delimited
+// field, its content is converted heuristically. Content that is valid UTF-8
+// and consists only of printable characters and whitespace is converted to a
+// string. Otherwise, content that parses as a message with at least one field
+// is converted to a map. Otherwise, content that is valid UTF-8 is converted
+// to a string, and any other content is converted to a blob. In particular,
+// packed repeated fields appear as strings or blobs.
+//
+// For example, the following message:
+//
+//	message Test {
+//		int32 a = 1;
+//		string b = 2;
+//		Test c = 3;
+//	}
+//
+// with a of 150, b of "testing", and c containing an a of 1, is converted to:
+//
+//	(
+//		1: <varint> 150,
+//		2: <len> "testing",
+//		3: <len> (
+//			1: <varint> 1,
+//		),
+//	)
+package rodproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// Wire types.
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireLen        = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+// The largest field number.
+const maxField = 1<<29 - 1
+
+// ToROD converts the message in data to a decoded ROD value.
+func ToROD(data []byte) (any, error) {
+	p := parser{data: data}
+	m, err := p.message(0)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) varint() (uint64, error) {
+	v, n := binary.Uvarint(p.data[p.pos:])
+	switch {
+	case n == 0:
+		return 0, p.errorf("unexpected end of data")
+	case n < 0:
+		return 0, p.errorf("varint overflows 64 bits")
+	}
+	p.pos += n
+	return v, nil
+}
+
+func (p *parser) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(p.data)-p.pos) {
+		return nil, p.errorf("unexpected end of data")
+	}
+	b := p.data[p.pos : p.pos+int(n)]
+	p.pos += int(n)
+	return b, nil
+}
+
+// Parses fields until the end of the data, or, if group is not zero, until the
+// end of the group with that field number.
+func (p *parser) message(group uint64) (map[any]any, error) {
+	m := map[any]any{}
+	for {
+		if p.pos >= len(p.data) {
+			if group != 0 {
+				return nil, p.errorf("unterminated group %d", group)
+			}
+			return m, nil
+		}
+		tag, err := p.varint()
+		if err != nil {
+			return nil, err
+		}
+		field, wire := tag>>3, tag&7
+		if field == 0 || field > maxField {
+			return nil, p.errorf("invalid field number %d", field)
+		}
+		var v any
+		switch wire {
+		case wireVarint:
+			u, err := p.varint()
+			if err != nil {
+				return nil, err
+			}
+			v = rod.Annotated{Annotation: "varint", Value: int64(u)}
+		case wireFixed64:
+			b, err := p.bytes(8)
+			if err != nil {
+				return nil, err
+			}
+			v = rod.Annotated{Annotation: "fixed64", Value: int64(binary.LittleEndian.Uint64(b))}
+		case wireLen:
+			n, err := p.varint()
+			if err != nil {
+				return nil, err
+			}
+			b, err := p.bytes(n)
+			if err != nil {
+				return nil, err
+			}
+			v = rod.Annotated{Annotation: "len", Value: content(b)}
+		case wireStartGroup:
+			g, err := p.message(field)
+			if err != nil {
+				return nil, err
+			}
+			v = rod.Annotated{Annotation: "group", Value: g}
+		case wireEndGroup:
+			if field != group {
+				return nil, p.errorf("unexpected end of group %d", field)
+			}
+			return m, nil
+		case wireFixed32:
+			b, err := p.bytes(4)
+			if err != nil {
+				return nil, err
+			}
+			v = rod.Annotated{Annotation: "fixed32", Value: int64(binary.LittleEndian.Uint32(b))}
+		default:
+			return nil, p.errorf("invalid wire type %d", wire)
+		}
+		k := int64(field)
+		switch prev := m[k].(type) {
+		case nil:
+			m[k] = v
+		case []any:
+			m[k] = append(prev, v)
+		default:
+			m[k] = []any{prev, v}
+		}
+	}
+}
+
+// Converts the content of a length-delimited field.
+func content(b []byte) any {
+	valid := utf8.Valid(b)
+	if valid && printable(b) {
+		return string(b)
+	}
+	p := parser{data: b}
+	if m, err := p.message(0); err == nil && len(m) > 0 {
+		return m
+	}
+	if valid {
+		return string(b)
+	}
+	return append([]byte{}, b...)
+}
+
+// Reports whether the UTF-8 text b consists only of printable characters and
+// whitespace.
+func printable(b []byte) bool {
+	for len(b) > 0 {
+		r, n := utf8.DecodeRune(b)
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package rodproto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+	"github.com/anaminus/rod/go/internal/testutil"
+)
+
+func TestToROD(t *testing.T) {
+	tests := map[string]string{
+		``:                       `()`,
+		`089601`:                 `(1: <varint> 150)`,
+		`08ffffffffffffffffff01`: `(1: <varint> -1)`,
+		`120774657374696e67`:     `(2: <len> "testing")`,
+		`1a03089601`:             `(3: <len> (1: <varint> 150))`,
+		`1200`:                   `(2: <len> "")`,
+		`0801100208031004`:       `(1: [<varint> 1, <varint> 3], 2: [<varint> 2, <varint> 4])`,
+		`0d01000000`:             `(1: <fixed32> 1)`,
+		`0dffffffff`:             `(1: <fixed32> 4294967295)`,
+		`090100000000000080`:     `(1: <fixed64> -9223372036854775807)`,
+		`0b10010c`:               `(1: <group> (2: <varint> 1))`,
+		`0b0c`:                   `(1: <group> ())`,
+		`1202ff00`:               `(2: <len> |ff 00|)`,
+		`12020a00`:               `(2: <len> (1: <len> ""))`,
+		`12020001`:               `(2: <len> "\u{0}\u{1}")`,
+		`1204c3bc0a41`:           `(2: <len> "ü\nA")`,
+		`2206030e8ea9be02`:       `(4: <len> |03 0e 8e a9 be 02|)`,
+		`a2060568656c6c6f`:       `(100: <len> "hello")`,
+		`f8ffffff0f01`:           `(536870911: <varint> 1)`,
+	}
+	for h, s := range tests {
+		data, err := hex.DecodeString(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ToROD(data)
+		if err != nil {
+			t.Errorf("%s: %s", h, err)
+			continue
+		}
+		if want := testutil.Decode(t, s); !rod.Equal(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", h, want, got)
+		}
+	}
+
+	for _, h := range []string{
+		"08",
+		"0880",
+		"0a05",
+		"0a0501",
+		"0d0100",
+		"090100",
+		"0e00",
+		"0000",
+		"80808080800100",
+		"0b1001",
+		"0c",
+		"0b14",
+		"08ffffffffffffffffffff01",
+	} {
+		data, _ := hex.DecodeString(h)
+		if _, err := ToROD(data); err == nil {
+			t.Errorf("%s: expected error", h)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package rodtest compares values against golden files encoded as ROD.
+//
+// A golden file holds the expected value of a test. Assert encodes the actual
+// value, and compares it semantically against the golden file, so that
+// formatting, comments, and the order of entries do not matter. When the test
+// binary is run with the -rodtest.update flag, golden files that differ are
+// rewritten with the actual value instead:
+//
+//	go test -run TestName -rodtest.update
+//
+// Annotations within a golden file are interpreted as matchers, as described
+// by rod.Match, so that nondeterministic values can be expected:
+//
+//	{
+//		ID: <regex "^[0-9a-f]{8}$"> "4f0c9a1e",
+//		Created: <any> "2006-01-02T15:04:05Z",
+//		Score: <approx 1.0e-6> 0.333333,
+//		Tags: <unordered> ["a", "b"],
+//	}
+//
+// When a golden file is rewritten, its annotations are preserved, and values
+// that satisfy a matcher are left as they are.
+package rodtest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// The flag is namespaced so that it does not clash with an -update flag defined
+// by the test binary.
+var update = flag.Bool("rodtest.update", false, "rewrite golden files that do not match")
+
+// Dir is the directory in which Assert locates golden files.
+const Dir = "testdata"
+
+// Path returns the location of the golden file for a test with the given name,
+// as returned by testing.T.Name. Each subtest is placed in a directory named
+// after its parent.
+func Path(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = strings.Map(func(r rune) rune {
+			switch r {
+			case '<', '>', ':', '"', '\\', '|', '?', '*':
+				return '_'
+			}
+			return r
+		}, part)
+	}
+	return filepath.Join(Dir, filepath.Join(parts...)+".rod")
+}
+
+// Assert compares got against the golden file of t, located by Path. Values
+// are compared as by rod.Match with opts. If they are not equal, the
+// differences are reported, and the test fails. If the -rodtest.update flag is
+// set, the golden file is rewritten with got instead.
+//
+// Assert is safe to call from parallel tests.
+func Assert(t testing.TB, got any, opts ...rod.Option) {
+	t.Helper()
+	AssertFile(t, Path(t.Name()), got, opts...)
+}
+
+// AssertFile is like Assert, but compares got against the golden file at the
+// given path.
+func AssertFile(t testing.TB, path string, got any, opts ...rod.Option) {
+	t.Helper()
+	var buf bytes.Buffer
+	e := rod.NewEncoder(&buf)
+	e.SetReferences(true)
+	if err := e.Encode(got); err != nil {
+		t.Fatalf("encode %s: %s", path, err)
+	}
+	// Compare against the decoded encoding, so that got is subject to the
+	// same conversions as the golden file.
+	var actual any
+	if err := rod.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&actual); err != nil {
+		t.Fatalf("decode %s: %s", path, err)
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if *update {
+			write(t, path, buf.Bytes())
+			return
+		}
+		t.Fatalf("golden file %s does not exist; run with -rodtest.update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("read golden file: %s", err)
+	}
+	var want any
+	d := rod.NewDecoder(bytes.NewReader(b))
+	d.SetAnnotations(true)
+	if err := d.Decode(&want); err != nil {
+		t.Fatalf("decode golden file %s: %s", path, err)
+	}
+
+	diffs, err := rod.Match(want, actual, opts...)
+	if err != nil {
+		t.Fatalf("golden file %s: %s", path, err)
+	}
+	if len(diffs) == 0 {
+		// Leave the file untouched, preserving its formatting and comments.
+		return
+	}
+	if *update {
+		v, err := rod.Reannotate(want, actual, opts...)
+		if err != nil {
+			t.Fatalf("golden file %s: %s", path, err)
+		}
+		buf.Reset()
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("encode %s: %s", path, err)
+		}
+		write(t, path, buf.Bytes())
+		return
+	}
+	var msg strings.Builder
+	rod.WriteDiff(&msg, diffs)
+	t.Errorf("value does not match golden file %s:\n%s", path, msg.String())
+}
+
+// Atomically writes content to the golden file at path.
+func write(t testing.TB, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("update golden file: %s", err)
+	}
+	w, err := rod.CreateFile(path)
+	if err != nil {
+		t.Fatalf("update golden file: %s", err)
+	}
+	defer w.Close()
+	if _, err := w.Write(content); err != nil {
+		t.Fatalf("update golden file: %s", err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("update golden file: %s", err)
+	}
+	t.Logf("updated golden file %s", path)
+}
@@ -0,0 +1,180 @@
+package rodtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	rod "github.com/anaminus/rod/go"
+)
+
+// Records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Logf(format string, args ...any) {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// Calls AssertFile with r on a separate goroutine, so that Fatalf stops it as
+// it would stop a test.
+func (r *recorder) assertFile(path string, got any, opts ...rod.Option) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		AssertFile(r, path, got, opts...)
+	}()
+	<-done
+}
+
+func TestPath(t *testing.T) {
+	tests := map[string]string{
+		"TestA":          filepath.Join("testdata", "TestA.rod"),
+		"TestA/sub_test": filepath.Join("testdata", "TestA", "sub_test.rod"),
+		"TestA/a:b*c":    filepath.Join("testdata", "TestA", "a_b_c.rod"),
+	}
+	for name, want := range tests {
+		if got := Path(name); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestAssert(t *testing.T) {
+	Assert(t, map[string]any{
+		"Name":   "golden",
+		"Values": []any{int64(1), 2.0, []byte{0x00, 0xFF}},
+	})
+}
+
+func TestAssertFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "golden.rod")
+
+	r := &recorder{TB: t}
+	r.assertFile(path, int64(1))
+	if !r.failed || !strings.Contains(r.msg, "does not exist") {
+		t.Fatalf("expected missing file failure, got %q", r.msg)
+	}
+
+	*update = true
+	r = &recorder{TB: t}
+	r.assertFile(path, map[string]any{"A": int64(1)})
+	*update = false
+	if r.failed {
+		t.Fatalf("unexpected failure: %s", r.msg)
+	}
+
+	// Rewrite with a different format, which must be preserved.
+	const formatted = "{A: +1} # comment"
+	os.WriteFile(path, []byte(formatted), 0644)
+	r = &recorder{TB: t}
+	r.assertFile(path, map[string]any{"A": int64(1)})
+	if r.failed {
+		t.Fatalf("unexpected failure: %s", r.msg)
+	}
+
+	r = &recorder{TB: t}
+	r.assertFile(path, map[string]any{"A": int64(2)})
+	if !r.failed || !strings.Contains(r.msg, "~ .A: 1 -> 2") {
+		t.Fatalf("expected diff in failure, got %q", r.msg)
+	}
+	if b, _ := os.ReadFile(path); string(b) != formatted {
+		t.Fatalf("golden file modified without -rodtest.update")
+	}
+
+	r = &recorder{TB: t}
+	r.assertFile(path, map[string]any{"A": 2.0}, rod.AbsTolerance(1))
+	if !r.failed || !strings.Contains(r.msg, "! .A: int 1 -> float 2.0") {
+		t.Fatalf("expected type change in failure, got %q", r.msg)
+	}
+}
+
+func TestAssertParallel(t *testing.T) {
+	dir := t.TempDir()
+	*update = true
+	t.Cleanup(func() { *update = false })
+	for i := 0; i < 8; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(dir, Path(t.Name()))
+			r := &recorder{TB: t}
+			r.assertFile(path, []any{int64(i)})
+			if r.failed {
+				t.Fatalf("unexpected failure: %s", r.msg)
+			}
+			var v any
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := rod.NewDecoder(f).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			if !rod.Equal(v, []any{int64(i)}) {
+				t.Errorf("unexpected golden content %v", v)
+			}
+		})
+	}
+}
+
+func TestAssertMatchers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.rod")
+	os.WriteFile(path, []byte(`{
+		Created: <any> "2006-01-02T15:04:05Z",
+		Path: <regex "^/tmp/"> "/tmp/x",
+		Tags: <unordered> ["a", "b"],
+		Count: 1,
+	}`), 0644)
+
+	got := map[string]any{
+		"Created": "2024-03-04T05:06:07Z",
+		"Path":    "/tmp/run123",
+		"Tags":    []any{"b", "a"},
+		"Count":   int64(1),
+	}
+	r := &recorder{TB: t}
+	r.assertFile(path, got)
+	if r.failed {
+		t.Fatalf("unexpected failure: %s", r.msg)
+	}
+
+	got["Count"] = int64(2)
+	*update = true
+	r = &recorder{TB: t}
+	r.assertFile(path, got)
+	*update = false
+	if r.failed {
+		t.Fatalf("unexpected failure: %s", r.msg)
+	}
+	b, _ := os.ReadFile(path)
+	want := `{
+	Count: 2,
+	Created: <any> "2006-01-02T15:04:05Z",
+	Path: <regex "^/tmp/"> "/tmp/x",
+	Tags: <unordered> [
+		"a",
+		"b",
+	],
+}`
+	if string(b) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, b)
+	}
+}
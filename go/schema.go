@@ -0,0 +1,439 @@
+package rod
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation describes a value that does not conform to a schema.
+type Violation struct {
+	// Path locates the value.
+	Path Path
+	// Position is the location of the value in its document, if known.
+	Position Position
+	// Message describes how the value does not conform.
+	Message string
+}
+
+// String formats the violation as the position, if known, followed by the path
+// and message.
+func (v Violation) String() string {
+	if v.Position.Line > 0 {
+		return fmt.Sprintf("%s: %s: %s", v.Position, v.Path, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidateOption configures Validate.
+type ValidateOption func(*validator)
+
+// WithPositions causes violations to include the positions of values, as
+// recorded by a Decoder while decoding the document.
+func WithPositions(ps Positions) ValidateOption {
+	return func(v *validator) { v.positions = ps }
+}
+
+// Validate checks that the decoded value doc conforms to schema, and returns
+// each violation in the order of the canonical encoding. An error is returned
+// if the schema is malformed.
+//
+// A schema is itself a decoded ROD value: a struct that describes a value with
+// the following optional fields:
+//
+//	Type        The name of the type of the value, as given to OfType, or an
+//	            array of names that are each permitted. "any" permits every
+//	            type, as does omitting Type.
+//	Annotation  An annotation that the value must have, or an array of
+//	            permitted annotations. A null in the array permits the value to
+//	            have no annotation. If omitted, any annotation is permitted.
+//	Min, Max    The inclusive range of an int or float.
+//	MinLen      The inclusive range of the number of characters of a string,
+//	MaxLen      bytes of a blob, or elements of an array.
+//	Elem        The schema of each element of an array.
+//	Key, Value  The schemas of each key and value of a map.
+//	Fields      A struct with the schema of each field of a struct.
+//	Required    An array of the names of fields that a struct must have.
+//	            Other fields are optional.
+//	Open        Whether a struct may have fields not listed in Fields.
+//
+// For example:
+//
+//	{
+//		Type: "struct",
+//		Fields: {
+//			Name: {Type: "string", MinLen: 1},
+//			Score: {Type: ["int", "float"], Min: 0, Max: 100},
+//			Tags: {Type: "array", Elem: {Type: "string"}},
+//			ID: {Type: "int", Annotation: "uint32"},
+//		},
+//		Required: ["Name"],
+//	}
+//
+// A violation of a map key is located by the path of its value, with the
+// message prefixed by "key: ". A schema may refer to itself with anchors and
+// references to describe recursive values. Annotations within doc are checked
+// only if doc was decoded with annotations enabled.
+func Validate(doc, schema any, opts ...ValidateOption) ([]Violation, error) {
+	s, err := compileSchema(nil, schema, map[identity]*schemaNode{})
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	v := validator{visiting: map[[2]any]bool{}}
+	for _, opt := range opts {
+		opt(&v)
+	}
+	v.validate(nil, doc, s)
+	return v.violations, nil
+}
+
+// A compiled schema.
+type schemaNode struct {
+	types       []string // Permitted types, or nil to permit any.
+	annotations []any    // Permitted annotations, or nil to permit any.
+	min, max    any      // Numeric bounds, or nil if absent.
+	minLen      int64    // -1 if absent.
+	maxLen      int64    // -1 if absent.
+	elem        *schemaNode
+	key, value  *schemaNode
+	fields      map[string]*schemaNode
+	required    []string
+	open        bool
+}
+
+// Names of the types permitted by a schema.
+var schemaTypes = []string{"any", "null", "bool", "int", "float", "string", "blob", "array", "map", "struct"}
+
+// Compiles the schema v located at path within the schema document. Schemas
+// that have already been compiled are looked up in done by identity, so that
+// recursive schemas are compiled once.
+func compileSchema(path Path, v any, done map[identity]*schemaNode) (*schemaNode, error) {
+	v = unannotate(v)
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("expected struct, got %s", typeName(v))}
+	}
+	id, _ := identityOf(fields)
+	if s, ok := done[id]; ok {
+		return s, nil
+	}
+	s := &schemaNode{minLen: -1, maxLen: -1}
+	done[id] = s
+	err := structForEach(fields, func(name string, f any) error {
+		fpath := path.with(Field(name))
+		f = unannotate(f)
+		var err error
+		switch name {
+		case "Type":
+			s.types, err = compileStrings(f)
+			if err == nil {
+				for _, t := range s.types {
+					if !containsString(schemaTypes, t) {
+						err = fmt.Errorf("unknown type %q", t)
+					} else if t == "any" {
+						s.types = nil
+						break
+					}
+				}
+			}
+		case "Annotation":
+			s.annotations, err = compileAnnotations(f)
+		case "Min", "Max":
+			switch f := f.(type) {
+			case int64:
+			case float64:
+				if f != f {
+					err = fmt.Errorf("expected number, got nan")
+				}
+			default:
+				err = fmt.Errorf("expected int or float, got %s", typeName(f))
+			}
+			if name == "Min" {
+				s.min = f
+			} else {
+				s.max = f
+			}
+		case "MinLen", "MaxLen":
+			n, ok := f.(int64)
+			if !ok || n < 0 {
+				err = fmt.Errorf("expected non-negative int, got %s", formatInline(f))
+			}
+			if name == "MinLen" {
+				s.minLen = n
+			} else {
+				s.maxLen = n
+			}
+		case "Elem":
+			s.elem, err = compileSchema(fpath, f, done)
+		case "Key":
+			s.key, err = compileSchema(fpath, f, done)
+		case "Value":
+			s.value, err = compileSchema(fpath, f, done)
+		case "Fields":
+			fs, ok := f.(map[string]any)
+			if !ok {
+				err = fmt.Errorf("expected struct, got %s", typeName(f))
+				break
+			}
+			s.fields = map[string]*schemaNode{}
+			err = structForEach(fs, func(k string, v any) error {
+				var err error
+				s.fields[k], err = compileSchema(fpath.with(Field(k)), v, done)
+				return err
+			})
+		case "Required":
+			s.required, err = compileStrings(f)
+		case "Open":
+			var ok bool
+			if s.open, ok = f.(bool); !ok {
+				err = fmt.Errorf("expected bool, got %s", typeName(f))
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		var perr *PathError
+		if err != nil && !errors.As(err, &perr) {
+			return &PathError{Path: fpath, Err: err}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range s.required {
+		if _, ok := s.fields[name]; !ok && !s.open {
+			return nil, &PathError{Path: path.with(Field("Required")), Err: fmt.Errorf("field %s is not in Fields", name)}
+		}
+	}
+	return s, nil
+}
+
+// Returns v as a list of strings. v may be a string or an array of strings.
+func compileStrings(v any) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	a, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected string or array, got %s", typeName(v))
+	}
+	strs := make([]string, len(a))
+	for i, e := range a {
+		s, ok := unannotate(e).(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string at %s, got %s", Path{Index(i)}, typeName(e))
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+// Returns v as a list of permitted annotations. v may be a string or an array
+// of strings and nulls.
+func compileAnnotations(v any) ([]any, error) {
+	if s, ok := v.(string); ok {
+		return []any{s}, nil
+	}
+	a, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected string or array, got %s", typeName(v))
+	}
+	annotations := make([]any, len(a))
+	for i, e := range a {
+		switch e := unannotate(e).(type) {
+		case string, nil:
+			annotations[i] = e
+		default:
+			return nil, fmt.Errorf("expected string or null at %s, got %s", Path{Index(i)}, typeName(e))
+		}
+	}
+	return annotations, nil
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+type validator struct {
+	positions  Positions
+	violations []Violation
+	// Pairs of composites and schemas currently being validated.
+	visiting map[[2]any]bool
+}
+
+func (v *validator) add(path Path, format string, args ...any) {
+	pos, _ := v.positions.Lookup(path)
+	v.violations = append(v.violations, Violation{
+		Path:     path,
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate(path Path, x any, s *schemaNode) {
+	if s.annotations != nil {
+		a, ok := annotationOf(x)
+		found := false
+		for _, want := range s.annotations {
+			if want == nil && !ok || ok && want == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.add(path, "%s, got %s", describeAnnotations(s.annotations), describeAnnotation(a, ok))
+		}
+	}
+	x = unannotate(x)
+	t := typeName(x)
+	if s.types != nil && !containsString(s.types, t) {
+		v.add(path, "expected %s, got %s", strings.Join(s.types, " or "), t)
+		return
+	}
+	if id, ok := identityOf(x); ok {
+		pair := [2]any{id, s}
+		if v.visiting[pair] {
+			return
+		}
+		v.visiting[pair] = true
+		defer delete(v.visiting, pair)
+	}
+	switch x := x.(type) {
+	case int64, float64:
+		if f, ok := x.(float64); ok && f != f {
+			if s.min != nil || s.max != nil {
+				v.add(path, "nan is outside of range")
+			}
+			break
+		}
+		if s.min != nil && compareNumbers(x, s.min) < 0 {
+			v.add(path, "%s is less than minimum %s", formatInline(x), formatInline(s.min))
+		}
+		if s.max != nil && compareNumbers(x, s.max) > 0 {
+			v.add(path, "%s is greater than maximum %s", formatInline(x), formatInline(s.max))
+		}
+	case string:
+		v.validateLen(path, utf8.RuneCountInString(x), s)
+	case []byte:
+		v.validateLen(path, len(x), s)
+	case []any:
+		v.validateLen(path, len(x), s)
+		if s.elem != nil {
+			for i, e := range x {
+				v.validate(path.with(Index(i)), e, s.elem)
+			}
+		}
+	case map[any]any:
+		mapForEach(x, func(k, e any) error {
+			if s.key != nil {
+				v.validateKey(path.with(Key{k}), k, s.key)
+			}
+			if s.value != nil {
+				v.validate(path.with(Key{k}), e, s.value)
+			}
+			return nil
+		})
+	case map[string]any:
+		missing := []string{}
+		for _, name := range s.required {
+			if _, ok := x[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		sort.Strings(missing)
+		for _, name := range missing {
+			v.add(path, "missing required field %s", name)
+		}
+		if s.fields == nil && s.open {
+			return
+		}
+		structForEach(x, func(name string, e any) error {
+			fs, ok := s.fields[name]
+			switch {
+			case ok:
+				v.validate(path.with(Field(name)), e, fs)
+			case !s.open:
+				v.add(path.with(Field(name)), "unexpected field")
+			}
+			return nil
+		})
+	}
+}
+
+// Validates a map key, reporting violations with the message prefixed, since
+// the path locates the value of the key.
+func (v *validator) validateKey(path Path, k any, s *schemaNode) {
+	sub := validator{positions: v.positions, visiting: v.visiting}
+	sub.validate(path, k, s)
+	for _, x := range sub.violations {
+		x.Message = "key: " + x.Message
+		v.violations = append(v.violations, x)
+	}
+}
+
+func (v *validator) validateLen(path Path, n int, s *schemaNode) {
+	if s.minLen >= 0 && int64(n) < s.minLen {
+		v.add(path, "length %d is less than minimum %d", n, s.minLen)
+	}
+	if s.maxLen >= 0 && int64(n) > s.maxLen {
+		v.add(path, "length %d is greater than maximum %d", n, s.maxLen)
+	}
+}
+
+func describeAnnotation(a string, ok bool) string {
+	if !ok {
+		return "no annotation"
+	}
+	return string(rAnnotation) + a + string(rAnnotationEnd)
+}
+
+func describeAnnotations(annotations []any) string {
+	names := make([]string, len(annotations))
+	for i, a := range annotations {
+		s, ok := a.(string)
+		names[i] = describeAnnotation(s, ok)
+	}
+	return "expected " + strings.Join(names, " or ")
+}
+
+// Compares numbers a and b, each an int64 or float64 other than NaN. Ints are
+// compared exactly.
+func compareNumbers(a, b any) int {
+	x, xok := a.(int64)
+	y, yok := b.(int64)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	f, g := toFloat(a), toFloat(b)
+	switch {
+	case f < g:
+		return -1
+	case f > g:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return math.NaN()
+}
@@ -0,0 +1,123 @@
+package rod
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var schema any
+	err := NewDecoder(strings.NewReader(`{
+		Type: "struct",
+		Fields: {
+			Name: {Type: "string", MinLen: 1, MaxLen: 4},
+			Score: {Type: ["int", "float"], Min: 0, Max: 100},
+			Tags: {Type: "array", Elem: {Type: "string"}, MaxLen: 2},
+			Data: {Type: "blob", MinLen: 2},
+			ID: {Type: "int", Annotation: ["uint32", null]},
+			Table: {Type: "map", Key: {Type: "int", Min: 0}, Value: {Type: "bool"}},
+			Extra: {Type: "struct", Open: true},
+			Tree: &1 {Type: ["struct", "null"], Fields: {Next: *1}},
+		},
+		Required: ["Name", "ID"],
+	}`)).Decode(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := []string{
+		`{Name: "a", ID: 1}`,
+		`{Name: "abcd", ID: <uint32> 1, Score: 100, Tags: ["x", "y"]}`,
+		`{Name: "é", ID: 1, Score: 0.5, Data: |00 01|, Table: (0: true, 5: false)}`,
+		`{Name: "a", ID: 1, Extra: {Any: 1}, Tree: {Next: {Next: null}}}`,
+	}
+	for _, s := range valid {
+		d := NewDecoder(strings.NewReader(s))
+		d.SetAnnotations(true)
+		var doc any
+		if err := d.Decode(&doc); err != nil {
+			t.Fatalf("%s: %s", s, err)
+		}
+		vs, err := Validate(doc, schema)
+		if err != nil {
+			t.Fatalf("%s: %s", s, err)
+		}
+		if len(vs) > 0 {
+			t.Errorf("%s: unexpected violations %v", s, vs)
+		}
+	}
+
+	d := NewDecoder(strings.NewReader(`{
+	Name: "",
+	Score: 101,
+	Tags: ["x", 1, "z"],
+	Data: |00|,
+	Table: (-1: true, 2: "no"),
+	Tree: {Next: {Next: 1}},
+	Extra: [],
+	Other: nan,
+	ID: <int32> 1.5,
+}`))
+	d.SetAnnotations(true)
+	d.SetPositions(true)
+	var doc any
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	vs, err := Validate(doc, schema, WithPositions(d.Positions()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.String())
+	}
+	want := []string{
+		`5:8: .Data: length 1 is less than minimum 2`,
+		`8:9: .Extra: expected struct, got array`,
+		`10:6: .ID: expected <uint32> or no annotation, got <int32>`,
+		`10:6: .ID: expected int, got float`,
+		`2:8: .Name: length 0 is less than minimum 1`,
+		`9:9: .Other: unexpected field`,
+		`3:9: .Score: 101 is greater than maximum 100`,
+		`6:14: .Table(-1): key: -1 is less than minimum 0`,
+		`6:23: .Table(2): expected bool, got string`,
+		`4:8: .Tags: length 3 is greater than maximum 2`,
+		`4:14: .Tags[1]: expected string, got int`,
+		`7:22: .Tree.Next.Next: expected struct or null, got int`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	vs, _ = Validate(_struct{"ID": _int(1)}, schema)
+	if len(vs) != 1 || vs[0].String() != ".: missing required field Name" {
+		t.Errorf("unexpected violations %v", vs)
+	}
+	vs, _ = Validate(_struct{"Name": "a", "ID": _int(1), "Score": math.NaN()}, schema)
+	if len(vs) != 1 || vs[0].String() != ".Score: nan is outside of range" {
+		t.Errorf("unexpected violations %v", vs)
+	}
+
+	for _, s := range []string{
+		`1`,
+		`{Type: "integer"}`,
+		`{Type: 1}`,
+		`{Min: "1"}`,
+		`{Min: nan}`,
+		`{MinLen: -1}`,
+		`{Elem: []}`,
+		`{Fields: {A: 1}}`,
+		`{Required: ["A"]}`,
+		`{Annotation: [1]}`,
+		`{Open: 1}`,
+		`{Unknown: 1}`,
+	} {
+		var schema any
+		NewDecoder(strings.NewReader(s)).Decode(&schema)
+		if _, err := Validate(nil, schema); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
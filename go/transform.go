@@ -0,0 +1,317 @@
+package rod
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transform rewrites a value located at path within a document. It returns the
+// value that replaces v, and whether the value is kept. If keep is false, then
+// the value is removed from its containing array, map, or struct.
+//
+// Transforms are composed with Chain, and restricted to certain values with At
+// and When.
+type Transform func(path Path, v any) (result any, keep bool)
+
+// Apply returns the result of applying t to each value within v. The value is
+// walked depth-first, such that the elements of a composite are transformed
+// before the composite itself. v is not modified; composites are copied. If the
+// root value is removed, Apply returns nil.
+//
+// An annotated value is transformed without its annotation, which is then
+// applied to the result. A composite that appears more than once within v is
+// copied once for each location, except that a map or struct that contains
+// itself remains cyclic. An error is returned if an array contains itself.
+func Apply(v any, t Transform) (any, error) {
+	a := applier{t: t, copies: map[identity]any{}}
+	r, keep, err := a.apply(Path{}, v)
+	if !keep {
+		return nil, err
+	}
+	return r, err
+}
+
+type applier struct {
+	t Transform
+	// Copies of composites that are currently being transformed. An array is
+	// nil until it has been copied.
+	copies map[identity]any
+}
+
+func (a *applier) apply(path Path, v any) (any, bool, error) {
+	if x, ok := v.(Annotated); ok {
+		r, keep, err := a.apply(path, x.Value)
+		return Annotated{Annotation: x.Annotation, Value: r}, keep, err
+	}
+	id, hasID := identityOf(v)
+	if hasID {
+		if c, ok := a.copies[id]; ok {
+			if c == nil {
+				return nil, false, errors.New("cannot transform array that contains itself")
+			}
+			return c, true, nil
+		}
+		a.copies[id] = nil
+		defer delete(a.copies, id)
+	}
+	switch v := v.(type) {
+	case []any:
+		c := make([]any, 0, len(v))
+		for i, e := range v {
+			r, keep, err := a.apply(path.with(Index(i)), e)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				c = append(c, r)
+			}
+		}
+		return a.finish(path, c)
+	case map[any]any:
+		c := make(map[any]any, len(v))
+		a.copies[id] = c
+		for k, e := range v {
+			r, keep, err := a.apply(path.with(Key{k}), e)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				c[k] = r
+			}
+		}
+		return a.finish(path, c)
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		a.copies[id] = c
+		for k, e := range v {
+			r, keep, err := a.apply(path.with(Field(k)), e)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				c[k] = r
+			}
+		}
+		return a.finish(path, c)
+	default:
+		return a.finish(path, v)
+	}
+}
+
+func (a *applier) finish(path Path, v any) (any, bool, error) {
+	r, keep := a.t(path, v)
+	return r, keep, nil
+}
+
+// Chain returns a Transform that applies each transform in order. If a
+// transform removes the value, then the remaining transforms are skipped.
+func Chain(ts ...Transform) Transform {
+	return func(path Path, v any) (any, bool) {
+		for _, t := range ts {
+			var keep bool
+			if v, keep = t(path, v); !keep {
+				return nil, false
+			}
+		}
+		return v, true
+	}
+}
+
+// At returns a Transform that applies t only to values located at a path that
+// matches pattern, as described by MatchPattern. At panics if pattern is
+// invalid.
+func At(pattern string, t Transform) Transform {
+	p, err := parsePath(pattern, true)
+	if err != nil {
+		panic("rod: pattern " + strconv.Quote(pattern) + ": " + err.Error())
+	}
+	return func(path Path, v any) (any, bool) {
+		if !matchPath(p, path) {
+			return v, true
+		}
+		return t(path, v)
+	}
+}
+
+// When returns a Transform that applies t only to values that satisfy pred.
+func When(pred func(v any) bool, t Transform) Transform {
+	return func(path Path, v any) (any, bool) {
+		if !pred(v) {
+			return v, true
+		}
+		return t(path, v)
+	}
+}
+
+// OfType returns a predicate that reports whether a value has one of the given
+// types, named as in the specification: "null", "bool", "int", "float",
+// "string", "blob", "array", "map", or "struct".
+func OfType(types ...string) func(v any) bool {
+	return func(v any) bool {
+		name := typeName(v)
+		for _, t := range types {
+			if t == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Drop is a Transform that removes every value.
+func Drop(path Path, v any) (any, bool) {
+	return nil, false
+}
+
+// Replace returns a Transform that replaces every value with r.
+func Replace(r any) Transform {
+	return func(path Path, v any) (any, bool) {
+		return r, true
+	}
+}
+
+// ReplaceStrings returns a Transform that replaces matches of re within
+// strings with repl, which is expanded as by regexp.Regexp.ReplaceAllString.
+// Other values are unchanged.
+func ReplaceStrings(re *regexp.Regexp, repl string) Transform {
+	return func(path Path, v any) (any, bool) {
+		if s, ok := v.(string); ok {
+			return re.ReplaceAllString(s, repl), true
+		}
+		return v, true
+	}
+}
+
+// Layouts of timestamps recognized by RedactTimes.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.DateTime,
+}
+
+// RedactTimes returns a Transform that replaces each string that is entirely a
+// timestamp with repl. Recognized timestamps include RFC 3339 and RFC 1123
+// formats.
+func RedactTimes(repl string) Transform {
+	return func(path Path, v any) (any, bool) {
+		if s, ok := v.(string); ok {
+			for _, layout := range timeLayouts {
+				if _, err := time.Parse(layout, s); err == nil {
+					return repl, true
+				}
+			}
+		}
+		return v, true
+	}
+}
+
+// MaskPaths returns a Transform that replaces each string that is an absolute
+// file path with repl, followed by the base name of the path. For example,
+// with a repl of "<path>", "/tmp/run123/out.txt" becomes "<path>/out.txt".
+func MaskPaths(repl string) Transform {
+	return func(path Path, v any) (any, bool) {
+		if s, ok := v.(string); ok && filepath.IsAbs(s) {
+			return repl + "/" + filepath.Base(s), true
+		}
+		return v, true
+	}
+}
+
+// RoundFloats returns a Transform that rounds each finite float to the given
+// number of significant digits. Other values are unchanged.
+func RoundFloats(digits int) Transform {
+	return func(path Path, v any) (any, bool) {
+		if f, ok := v.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			r, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', digits, 64), 64)
+			return r, true
+		}
+		return v, true
+	}
+}
+
+// Sort is a Transform that sorts the elements of each array. Elements are
+// ordered first by type, in the order listed by OfType, then by value.
+// Primitives are compared as map keys are sorted, and composites are compared
+// by their encoding. Other values are unchanged.
+func Sort(path Path, v any) (any, bool) {
+	a, ok := v.([]any)
+	if !ok {
+		return v, true
+	}
+	type entry struct {
+		v   any
+		enc string // Encoding of a composite.
+	}
+	entries := make([]entry, len(a))
+	for i, v := range a {
+		entries[i] = entry{v, compositeEncoding(v)}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return compareEncoded(entries[i].v, entries[j].v, entries[i].enc, entries[j].enc) < 0
+	})
+	s := make([]any, len(a))
+	for i, e := range entries {
+		s[i] = e.v
+	}
+	return s, true
+}
+
+// Compares a and b in the order used by Sort, ignoring annotations. Returns a
+// negative number if a sorts before b, a positive number if a sorts after b,
+// and zero otherwise.
+func compareValues(a, b any) int {
+	return compareEncoded(a, b, compositeEncoding(a), compositeEncoding(b))
+}
+
+// Like compareValues, with ea and eb as the encodings of a and b returned by
+// compositeEncoding.
+func compareEncoded(a, b any, ea, eb string) int {
+	a, b = unannotate(a), unannotate(b)
+	ti, tj := sortIndex(a), sortIndex(b)
+	switch {
+	case ti != tj:
+		return ti - tj
+	case typeIndex(a) != 0:
+		if typeCmp(a, b) {
+			return -1
+		} else if typeCmp(b, a) {
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(ea, eb)
+	}
+}
+
+// Returns the encoding of v without its annotation if v is not a primitive, or
+// an empty string otherwise.
+func compositeEncoding(v any) string {
+	if v = unannotate(v); typeIndex(v) != 0 {
+		return ""
+	}
+	return formatInline(v)
+}
+
+// Returns the position of the type of v in the order used by Sort.
+func sortIndex(v any) int {
+	if i := typeIndex(v); i != 0 {
+		return i
+	}
+	switch v.(type) {
+	case []any:
+		return 7
+	case map[any]any:
+		return 8
+	case map[string]any:
+		return 9
+	default:
+		return 10
+	}
+}
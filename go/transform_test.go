@@ -0,0 +1,138 @@
+package rod
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	path := Path{Field("Users"), Index(3), Key{"k"}, Field("Name")}
+	tests := map[string]bool{
+		`.Users[3]("k").Name`: true,
+		`.Users[*](*).*`:      true,
+		`**.Name`:             true,
+		`**`:                  true,
+		`.Users**`:            true,
+		`.Users**.Name`:       true,
+		`.Users[*]**("k")**`:  true,
+		`.Users[2]**`:         false,
+		`.Users`:              false,
+		`("k")`:               false,
+		`.`:                   false,
+		`**.Users[3]("j")**`:  false,
+	}
+	for pattern, want := range tests {
+		got, err := MatchPattern(pattern, path)
+		if err != nil {
+			t.Errorf("%s: %s", pattern, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: expected %v, got %v", pattern, want, got)
+		}
+	}
+	if ok, _ := MatchPattern(".", Path{}); !ok {
+		t.Errorf("expected root pattern to match root")
+	}
+	if ok, _ := MatchPattern(`(|00 29|)(")")(nan)`, Path{Key{_blob{0x00, 0x29}}, Key{")"}, Key{math.NaN()}}); !ok {
+		t.Errorf("expected keys to match")
+	}
+	for _, pattern := range []string{``, `Users`, `.1A`, `[-1]`, `[x]`, `[1`, `(1`, `([1])`, `.Users*`} {
+		if _, err := MatchPattern(pattern, path); err == nil {
+			t.Errorf("%s: expected error", pattern)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	v := _struct{
+		"Created": "2006-01-02T15:04:05Z",
+		"Dir":     "/tmp/run123/out.txt",
+		"Score":   _float(0.333333333),
+		"Secret":  "hunter2",
+		"Users": _array{
+			_struct{"Name": "b", "Password": "x"},
+			_struct{"Name": "a", "Password": "y"},
+		},
+		"Tags": _array{"c", _int(1), "a", nil},
+		"Note": Annotated{"int32", _int(7)},
+	}
+	got, err := Apply(v, Chain(
+		RedactTimes("<time>"),
+		MaskPaths("<path>"),
+		When(OfType("float"), RoundFloats(3)),
+		At(".Secret", Drop),
+		At("**.Password", Replace("<redacted>")),
+		At(".Tags", Sort),
+		At(".Note", func(path Path, v any) (any, bool) { return v.(int64) + 1, true }),
+	))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	want := _struct{
+		"Created": "<time>",
+		"Dir":     "<path>/out.txt",
+		"Score":   _float(0.333),
+		"Users": _array{
+			_struct{"Name": "b", "Password": "<redacted>"},
+			_struct{"Name": "a", "Password": "<redacted>"},
+		},
+		"Tags": _array{nil, _int(1), "a", "c"},
+		"Note": Annotated{"int32", _int(8)},
+	}
+	if diffs := Diff(got, want); len(diffs) > 0 {
+		for _, d := range diffs {
+			t.Log(d)
+		}
+		t.Errorf("transformed value not equal to control")
+	}
+	if v["Secret"] != "hunter2" || v["Users"].([]any)[0].(map[string]any)["Password"] != "x" {
+		t.Errorf("original value was modified")
+	}
+}
+
+func TestApplyCycle(t *testing.T) {
+	m := _map{"n": _int(1)}
+	m["self"] = m
+	got, err := Apply(m, ReplaceStrings(regexp.MustCompile(".*"), "x"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	c := got.(map[any]any)
+	if _, ok := c["self"].(map[any]any)["self"]; !ok {
+		t.Errorf("expected cycle to be preserved")
+	}
+
+	a := make(_array, 1)
+	a[0] = a
+	if _, err := Apply(a, Sort); err == nil {
+		t.Errorf("expected error for array that contains itself")
+	}
+}
+
+func TestSort(t *testing.T) {
+	v, _ := Sort(nil, _array{
+		_struct{"A": _int(2)}, _array{}, "b", _float(1), _int(2), _struct{"A": _int(1)}, false, _map{}, nil, _blob{1}, "a",
+	})
+	want := _array{
+		nil, false, _int(2), _float(1), "a", "b", _blob{1}, _array{}, _map{}, _struct{"A": _int(1)}, _struct{"A": _int(2)},
+	}
+	if !Equal(v, want) {
+		t.Errorf("expected %s, got %s", formatInline(want), formatInline(v))
+	}
+}
+
+func TestEncoderTransform(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetTransform(At("**.ID", Replace("<id>")))
+	if err := e.Encode(_struct{"A": _struct{"ID": _int(123)}, "ID": _int(4)}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	want := "{\n\tA: {\n\t\tID: \"<id>\",\n\t},\n\tID: \"<id>\",\n}"
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}